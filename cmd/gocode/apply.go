@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+
+	"github.com/d0sbit/gocode/codeplan"
+	"github.com/d0sbit/gocode/srcedit"
+)
+
+// runApply is called for `gocode apply plan.json`, it applies a plan written by
+// running a tool with -plan, as long as the files it was made from haven't changed.
+func runApply(args []string) int {
+
+	flagSet := flag.NewFlagSet("gocode apply", flag.ContinueOnError)
+	flagSet.Usage = func() {
+		fmt.Fprintf(flagSet.Output(), "Usage: gocode apply plan.json\n\nApplies a plan written by running a tool with -plan.\n")
+	}
+	err := flagSet.Parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return 2
+	}
+	planFile := flagSet.Arg(0)
+
+	pl, err := codeplan.ReadFile(planFile)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	rootFS, modDir, _, modPath, err := srcedit.FindOSWdModuleDir("")
+	if err != nil {
+		log.Printf("error finding module directory: %v", err)
+		return 1
+	}
+	moduleFS, err := fs.Sub(rootFS, modDir)
+	if err != nil {
+		log.Printf("fs.Sub error while constructing module fs: %v", err)
+		return 1
+	}
+
+	conflicts, err := pl.Apply(moduleFS, modPath)
+	if err != nil {
+		if errors.Is(err, codeplan.ErrChanged) {
+			log.Printf("not applying %s: %v, run the tool with -plan again", planFile, err)
+			return 1
+		}
+		log.Printf("error applying %s: %v", planFile, err)
+		return 1
+	}
+
+	for _, c := range conflicts {
+		log.Print(c)
+	}
+	fmt.Fprintf(os.Stderr, "Applied %s\n", pl)
+	return 0
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/d0sbit/gocode/config"
+	"github.com/d0sbit/gocode/srcedit"
+)
+
+// inference is the result of working out which tool to run for a file.
+type inference struct {
+	Tool       string      // tool to run, empty if it could not be determined (see Candidates)
+	Candidates []string    // tools to choose from when Tool is empty
+	Settings   [][2]string // name/value pairs describing what was inferred, in display order
+	Args       []string    // arguments to pass to the tool
+	MkdirList  []string    // directories (relative to the module) that do not exist yet and will be needed
+}
+
+func (inf *inference) setting(name, value string) {
+	inf.Settings = append(inf.Settings, [2]string{name, value})
+}
+
+// storeDriverTools maps import path prefixes found in store.go to the tool that generated it.
+var storeDriverTools = []struct {
+	prefix string
+	tool   string
+}{
+	{"go.mongodb.org/mongo-driver", "mongocrud"},
+	{"database/sql", "sqlcrud"},
+	{"github.com/jmoiron/sqlx", "sqlcrud"},
+	{"github.com/go-sql-driver/mysql", "sqlcrud"},
+	{"github.com/lib/pq", "sqlcrud"},
+	{"github.com/jackc/pgx", "sqlcrud"},
+	{"github.com/mattn/go-sqlite3", "sqlcrud"},
+}
+
+// storeTool looks at the imports in store.go in pkgDir and returns the tool
+// that matches the driver used, or empty string if store.go is not there
+// or does not import anything we recognize.
+func storeTool(moduleFS fs.FS, pkgDir string) (string, error) {
+
+	f, err := moduleFS.Open(path.Join(pkgDir, "store.go"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+
+	af, err := parser.ParseFile(token.NewFileSet(), "store.go", b, parser.ImportsOnly)
+	if err != nil {
+		return "", fmt.Errorf("parsing store.go: %w", err)
+	}
+
+	for _, imp := range af.Imports {
+		importPath, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			return "", err
+		}
+		for _, dt := range storeDriverTools {
+			if strings.HasPrefix(importPath, dt.prefix) {
+				return dt.tool, nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// dirExists returns true if dir exists in fsys ("" means the root).
+func dirExists(fsys fs.FS, dir string) bool {
+	if dir == "" {
+		dir = "."
+	}
+	st, err := fs.Stat(fsys, dir)
+	return err == nil && st.IsDir()
+}
+
+// inferTool works out which tool should be run for fileArg, which is a Go file (which may not exist yet)
+// in package directory pkgDir in the module.  The store_dir and handlers_dir settings from cfg
+// are used to tell whether the file belongs to the store or to the handlers.
+func inferTool(moduleFS fs.FS, cfg *config.Config, modPath, pkgDir, fileArg string) (*inference, error) {
+
+	storeDir := cfg.GetString("store_dir", "store")
+	handlersDir := cfg.GetString("handlers_dir", "handlers")
+	fileName := filepath.Base(fileArg)
+
+	inf := &inference{}
+
+	switch {
+
+	case srcedit.DirHasSuffix(pkgDir, handlersDir):
+
+		storePkgDir, err := srcedit.DirResolveTo(pkgDir, handlersDir, storeDir)
+		if err != nil {
+			return nil, err
+		}
+
+		inf.Tool = "handlercrud"
+		inf.setting("handlers_dir", handlersDir)
+		inf.setting("store_dir", storeDir)
+		inf.setting("handlers package", pkgDir)
+		inf.setting("store package", storePkgDir)
+
+		if !dirExists(moduleFS, storePkgDir) {
+			return nil, fmt.Errorf("store package directory %q does not exist (handlers are generated for types in the store, try running gocode on %s first)",
+				storePkgDir, path.Join(storePkgDir, fileName))
+		}
+		if !dirExists(moduleFS, pkgDir) {
+			inf.MkdirList = append(inf.MkdirList, pkgDir)
+		}
+
+		inf.Args = []string{fileArg}
+
+	case srcedit.DirHasSuffix(pkgDir, storeDir):
+
+		tool, err := storeTool(moduleFS, pkgDir)
+		if err != nil {
+			return nil, err
+		}
+		inf.Tool = tool
+		if tool == "" {
+			inf.Candidates = []string{"sqlcrud", "mongocrud"}
+		}
+
+		inf.setting("store_dir", storeDir)
+		inf.setting("package", pkgDir)
+
+		if !dirExists(moduleFS, pkgDir) {
+			return nil, fmt.Errorf("store package directory %q does not exist (the type to generate code for must be declared there)", pkgDir)
+		}
+
+		pkg := srcedit.NewPackage(moduleFS, moduleFS, modPath, pkgDir)
+		typeSearch := strings.TrimSuffix(fileName, ".go")
+		typeInfo, err := pkg.FindTypeLoose(typeSearch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find type for %q in %q: %w", typeSearch, pkgDir, err)
+		}
+		inf.setting("type", typeInfo.Name())
+		inf.setting("file", fileName)
+
+		inf.Args = []string{"-package", pkgDir, "-type", typeInfo.Name(), "-file", fileName}
+
+		// sqlcrud also needs a migrations package
+		if tool == "sqlcrud" || tool == "" {
+			migrationsDir := cfg.GetString("migrations_dir", "")
+			if migrationsDir != "" {
+				inf.Args = append(inf.Args, "-migrations-package", migrationsDir)
+			} else {
+				migrationsDir = strings.TrimPrefix(path.Join(pkgDir, "../migrations"), "/")
+			}
+			inf.setting("migrations package", migrationsDir)
+			if !dirExists(moduleFS, migrationsDir) {
+				inf.MkdirList = append(inf.MkdirList, migrationsDir)
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unable to tell which tool to use for %q: package directory %q is not in store_dir %q or handlers_dir %q (see .gocode/gocode.toml)",
+			fileArg, pkgDir, storeDir, handlersDir)
+	}
+
+	return inf, nil
+}
+
+// runInferred is called for `gocode path/to/file.go [args]`, it infers the tool and
+// its flags from the file path and runs it.  Any extra args are passed to the tool
+// before the inferred ones.
+func runInferred(fileArg string, extraArgs []string) int {
+
+	rootFS, modDir, pkgDir, modPath, err := srcedit.FindOSWdModuleDir(filepath.Dir(fileArg))
+	if err != nil {
+		log.Printf("error finding module directory: %v", err)
+		return 1
+	}
+	moduleFS, err := fs.Sub(rootFS, modDir)
+	if err != nil {
+		log.Printf("fs.Sub error while constructing module fs: %v", err)
+		return 1
+	}
+
+	cfg, err := config.LoadFS(moduleFS, true)
+	if err != nil {
+		log.Printf("config.LoadFS failed: %v", err)
+		return 1
+	}
+
+	inf, err := inferTool(moduleFS, cfg, modPath, pkgDir, fileArg)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	if inf.Tool == "" {
+		fmt.Fprintf(promptOut, "Could not tell which tool generated the store in %q (no recognized imports in store.go).\n", pkgDir)
+		for {
+			ans, err := ask("Which tool should be used ("+strings.Join(inf.Candidates, ", ")+")?", inf.Candidates[0])
+			if err != nil {
+				log.Print(err)
+				return 1
+			}
+			for _, c := range inf.Candidates {
+				if ans == c {
+					inf.Tool = c
+				}
+			}
+			if inf.Tool != "" {
+				break
+			}
+		}
+		// migrations only apply to sqlcrud
+		if inf.Tool != "sqlcrud" {
+			inf.Args = withoutFlag(inf.Args, "-migrations-package")
+			inf.MkdirList = nil
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Using %s for %s:\n", inf.Tool, fileArg)
+	for _, s := range inf.Settings {
+		if s[0] == "migrations package" && inf.Tool != "sqlcrud" {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "\t%s: %s\n", s[0], s[1])
+	}
+
+	for _, dir := range inf.MkdirList {
+		ok, err := confirm(fmt.Sprintf("Directory %q does not exist, create it?", dir), true)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		if !ok {
+			log.Printf("aborted, directory %q not created", dir)
+			return 1
+		}
+		mda, ok := moduleFS.(srcedit.MkdirAller)
+		if !ok {
+			log.Printf("module filesystem does not support MkdirAll")
+			return 1
+		}
+		err = mda.MkdirAll(dir, 0755)
+		if err != nil {
+			log.Printf("MkdirAll for %q: %v", dir, err)
+			return 1
+		}
+	}
+
+	args := append(append([]string(nil), extraArgs...), inf.Args...)
+	fmt.Fprintf(os.Stderr, "Running: %s%s %s\n", toolPrefix, inf.Tool, strings.Join(args, " "))
+
+	return runTool(inf.Tool, args)
+}
+
+// withoutFlag returns args with the named flag and its value removed.
+func withoutFlag(args []string, name string) []string {
+	ret := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		if args[i] == name {
+			i++ // skip value too
+			continue
+		}
+		ret = append(ret, args[i])
+	}
+	return ret
+}
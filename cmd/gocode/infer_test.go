@@ -0,0 +1,125 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/psanford/memfs"
+
+	"github.com/d0sbit/gocode/config"
+)
+
+func TestInferTool(t *testing.T) {
+
+	mfs := memfs.New()
+	must(t, mfs.WriteFile("go.mod", []byte("module test1\n"), 0644))
+	must(t, mfs.MkdirAll("sqlstore", 0755))
+	must(t, mfs.WriteFile("sqlstore/types.go", []byte("package sqlstore\n\ntype Widget struct {\n\tID string `db:\"id\"`\n}\n"), 0644))
+	must(t, mfs.WriteFile("sqlstore/store.go", []byte("package sqlstore\n\nimport \"github.com/jmoiron/sqlx\"\n\ntype Store struct { dbx *sqlx.DB }\n"), 0644))
+	must(t, mfs.MkdirAll("mstore", 0755))
+	must(t, mfs.WriteFile("mstore/types.go", []byte("package mstore\n\ntype SomeThing struct {\n\tID string `bson:\"_id\"`\n}\n"), 0644))
+	must(t, mfs.WriteFile("mstore/store.go", []byte("package mstore\n\nimport \"go.mongodb.org/mongo-driver/mongo\"\n\ntype Store struct { client *mongo.Client }\n"), 0644))
+	must(t, mfs.MkdirAll("newstore", 0755))
+	must(t, mfs.WriteFile("newstore/types.go", []byte("package newstore\n\ntype Widget struct {\n\tID string\n}\n"), 0644))
+
+	type tcase struct {
+		name       string
+		settings   map[string]interface{}
+		pkgDir     string
+		fileArg    string
+		tool       string
+		candidates []string
+		args       []string
+		mkdirList  []string
+		errtxt     string
+	}
+
+	tcaseList := []tcase{
+		{
+			name:      "sqlcrud",
+			settings:  map[string]interface{}{"store_dir": "sqlstore"},
+			pkgDir:    "sqlstore",
+			fileArg:   "sqlstore/widget.go",
+			tool:      "sqlcrud",
+			args:      []string{"-package", "sqlstore", "-type", "Widget", "-file", "widget.go"},
+			mkdirList: []string{"migrations"},
+		},
+		{
+			name:     "sqlcrud_migrations_dir",
+			settings: map[string]interface{}{"store_dir": "sqlstore", "migrations_dir": "sqlstore"},
+			pkgDir:   "sqlstore",
+			fileArg:  "sqlstore/widget.go",
+			tool:     "sqlcrud",
+			args:     []string{"-package", "sqlstore", "-type", "Widget", "-file", "widget.go", "-migrations-package", "sqlstore"},
+		},
+		{
+			name:     "mongocrud",
+			settings: map[string]interface{}{"store_dir": "mstore"},
+			pkgDir:   "mstore",
+			fileArg:  "mstore/some-thing.go",
+			tool:     "mongocrud",
+			args:     []string{"-package", "mstore", "-type", "SomeThing", "-file", "some-thing.go"},
+		},
+		{
+			name:       "no_store_go",
+			settings:   map[string]interface{}{"store_dir": "newstore"},
+			pkgDir:     "newstore",
+			fileArg:    "newstore/widget.go",
+			tool:       "",
+			candidates: []string{"sqlcrud", "mongocrud"},
+			args:       []string{"-package", "newstore", "-type", "Widget", "-file", "widget.go"},
+			mkdirList:  []string{"migrations"},
+		},
+		{
+			name:      "handlercrud",
+			settings:  map[string]interface{}{"store_dir": "sqlstore", "handlers_dir": "handlers"},
+			pkgDir:    "handlers",
+			fileArg:   "handlers/widget.go",
+			tool:      "handlercrud",
+			args:      []string{"handlers/widget.go"},
+			mkdirList: []string{"handlers"},
+		},
+		{
+			name:     "handlercrud_no_store",
+			settings: map[string]interface{}{},
+			pkgDir:   "handlers",
+			fileArg:  "handlers/widget.go",
+			errtxt:   `store package directory "store" does not exist (handlers are generated for types in the store, try running gocode on store/widget.go first)`,
+		},
+		{
+			name:     "unknown_dir",
+			settings: map[string]interface{}{},
+			pkgDir:   "other",
+			fileArg:  "other/widget.go",
+			errtxt:   `unable to tell which tool to use for "other/widget.go": package directory "other" is not in store_dir "store" or handlers_dir "handlers" (see .gocode/gocode.toml)`,
+		},
+	}
+
+	for _, tc := range tcaseList {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{Settings: tc.settings}
+			inf, err := inferTool(mfs, cfg, "test1", tc.pkgDir, tc.fileArg)
+			if tc.errtxt != "" {
+				if err == nil || err.Error() != tc.errtxt {
+					t.Fatalf("unexpected error: expected=%q, got=%v", tc.errtxt, err)
+				}
+				return
+			}
+			must(t, err)
+			t.Logf("settings: %v", inf.Settings)
+			if inf.Tool != tc.tool {
+				t.Errorf("unexpected tool: expected=%q, got=%q", tc.tool, inf.Tool)
+			}
+			if !reflect.DeepEqual(inf.Candidates, tc.candidates) {
+				t.Errorf("unexpected candidates: %v", inf.Candidates)
+			}
+			if !reflect.DeepEqual(inf.Args, tc.args) {
+				t.Errorf("unexpected args: %q", inf.Args)
+			}
+			if !reflect.DeepEqual(inf.MkdirList, tc.mkdirList) {
+				t.Errorf("unexpected mkdir list: %q", inf.MkdirList)
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+
+	"github.com/d0sbit/gocode/config"
+)
+
+// initSetting is a config setting suggested by `gocode init`.
+type initSetting struct {
+	Key    string // setting name in .gocode/gocode.toml
+	Flag   string // gocode init flag which sets it
+	Value  string
+	Reason string // why this value was suggested
+}
+
+// sqlTemplateSets maps the module/import path prefixes of SQL drivers to the sqlcrud
+// template_set setting they indicate.
+var sqlTemplateSets = []struct {
+	prefix string
+	set    string
+}{
+	{"github.com/go-sql-driver/mysql", "sqlx"},
+	{"github.com/lib/pq", "database-sql"},
+	{"github.com/jackc/pgx", "pgx"},
+	{"github.com/mattn/go-sqlite3", "database-sql"},
+}
+
+// initDir is what `gocode init` found out about one package directory.
+type initDir struct {
+	dir       string
+	imports   map[string]bool
+	storeTool string // from storeTool(), empty if no store.go or not recognized
+	goose     bool   // has goose .sql migrations
+}
+
+func (d *initDir) base() string {
+	return path.Base(d.dir)
+}
+
+// scanInitDirs returns info for every package directory in the module, sorted
+// so shallower directories come first.
+func scanInitDirs(moduleFS fs.FS) ([]*initDir, error) {
+
+	var ret []*initDir
+
+	err := walkPackageDirs(moduleFS, func(dir string) error {
+
+		dirEntryList, err := fs.ReadDir(moduleFS, dir)
+		if err != nil {
+			return err
+		}
+
+		d := &initDir{dir: dir, imports: make(map[string]bool)}
+		fset := token.NewFileSet()
+		for _, de := range dirEntryList {
+			name := de.Name()
+			if de.IsDir() {
+				continue
+			}
+			if strings.HasSuffix(strings.ToLower(name), ".sql") {
+				b, err := fs.ReadFile(moduleFS, path.Join(dir, name))
+				if err != nil {
+					return err
+				}
+				if strings.Contains(string(b), "+goose Up") {
+					d.goose = true
+				}
+				continue
+			}
+			if !strings.HasSuffix(name, ".go") {
+				continue
+			}
+			b, err := fs.ReadFile(moduleFS, path.Join(dir, name))
+			if err != nil {
+				return err
+			}
+			f, err := parser.ParseFile(fset, name, b, parser.ImportsOnly)
+			if err != nil {
+				continue
+			}
+			for _, imp := range f.Imports {
+				if p, err := strconv.Unquote(imp.Path.Value); err == nil {
+					d.imports[p] = true
+				}
+			}
+		}
+
+		d.storeTool, err = storeTool(moduleFS, dir)
+		if err != nil {
+			return fmt.Errorf("checking %q: %w", dir, err)
+		}
+
+		ret = append(ret, d)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return strings.Count(ret[i].dir, "/") < strings.Count(ret[j].dir, "/")
+	})
+
+	return ret, nil
+}
+
+// suggestConfig looks through the module and suggests values for store_dir, handlers_dir,
+// migrations_dir and template_set.  The latter two are left out if the store uses MongoDB.
+func suggestConfig(moduleFS fs.FS) ([]initSetting, error) {
+
+	dirs, err := scanInitDirs(moduleFS)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []initSetting
+
+	// store_dir: a store.go we recognize, otherwise a directory named like a store
+	store := initSetting{Key: "store_dir", Flag: "store-dir", Value: "store", Reason: "default, no store package found"}
+	storeTool := ""
+	for _, d := range dirs {
+		if d.storeTool != "" {
+			store.Value, store.Reason = d.dir, fmt.Sprintf("%s/store.go was generated by %s", d.dir, d.storeTool)
+			storeTool = d.storeTool
+			break
+		}
+	}
+	if storeTool == "" {
+		for _, d := range dirs {
+			if d.dir != "." && strings.HasSuffix(strings.ToLower(d.base()), "store") {
+				store.Value, store.Reason = d.dir, "package directory named like a store"
+				break
+			}
+		}
+	}
+	ret = append(ret, store)
+
+	// handlers_dir: a directory named like handlers, preferably one that uses net/http
+	handlers := initSetting{Key: "handlers_dir", Flag: "handlers-dir", Value: "handlers", Reason: "default, no handlers package found"}
+	found := false
+	for _, useHTTP := range []bool{true, false} {
+		for _, d := range dirs {
+			if !strings.Contains(strings.ToLower(d.base()), "handler") || (useHTTP && !d.imports["net/http"]) {
+				continue
+			}
+			handlers.Value, handlers.Reason = d.dir, "package directory named like handlers"
+			if useHTTP {
+				handlers.Reason += " which uses net/http"
+			}
+			found = true
+			break
+		}
+		if found {
+			break
+		}
+	}
+	if !found && store.Value != "store" {
+		// same place as the store, e.g. internal/store means internal/handlers
+		handlers.Value = path.Join(path.Dir(store.Value), "handlers")
+		handlers.Reason = "next to the store package"
+	}
+	ret = append(ret, handlers)
+
+	if storeTool == "mongocrud" {
+		return ret, nil
+	}
+
+	// migrations_dir: goose migrations, otherwise a directory named migrations, otherwise next to the store
+	migrations := initSetting{Key: "migrations_dir", Flag: "migrations-dir",
+		Value:  strings.TrimPrefix(path.Join(store.Value, "../migrations"), "/"),
+		Reason: "default, ../migrations relative to the store package"}
+	found = false
+	for _, d := range dirs {
+		if d.goose {
+			migrations.Value, migrations.Reason = d.dir, "has goose migration files"
+			found = true
+			break
+		}
+	}
+	for _, d := range dirs {
+		if found {
+			break
+		}
+		if d.base() == "migrations" {
+			migrations.Value, migrations.Reason = d.dir, "package directory named migrations"
+			break
+		}
+	}
+	ret = append(ret, migrations)
+
+	// template_set: from the SQL driver in go.mod requirements, then imports
+	set := initSetting{Key: "template_set", Flag: "template-set", Value: "sqlx", Reason: "default, no SQL driver found in go.mod or imports"}
+	found = false
+	if b, err := fs.ReadFile(moduleFS, "go.mod"); err == nil {
+		mf, err := modfile.ParseLax("go.mod", b, nil)
+		if err != nil {
+			return nil, fmt.Errorf("parsing go.mod: %w", err)
+		}
+		for _, req := range mf.Require {
+			if ts := templateSetFor(req.Mod.Path); ts != "" {
+				set.Value, set.Reason = ts, "go.mod requires "+req.Mod.Path
+				found = true
+				break
+			}
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if !found {
+	dirLoop:
+		for _, d := range dirs {
+			for imp := range d.imports {
+				if ts := templateSetFor(imp); ts != "" {
+					set.Value, set.Reason = ts, fmt.Sprintf("%s imports %s", d.dir, imp)
+					break dirLoop
+				}
+			}
+		}
+	}
+	ret = append(ret, set)
+
+	return ret, nil
+}
+
+// templateSetFor returns the template_set setting for the SQL driver with an import or module
+// path, or empty string if not a driver.
+func templateSetFor(p string) string {
+	for _, ts := range sqlTemplateSets {
+		if strings.HasPrefix(p, ts.prefix) {
+			return ts.set
+		}
+	}
+	return ""
+}
+
+// runInit is called for `gocode init`, it suggests settings based on what is in the
+// module, confirms them and writes .gocode/gocode.toml.
+func runInit(args []string) int {
+
+	flagSet := flag.NewFlagSet("gocode init", flag.ContinueOnError)
+	flagValues := map[string]*string{
+		"store-dir":      flagSet.String("store-dir", "", "Value for store_dir, directory of the store package"),
+		"handlers-dir":   flagSet.String("handlers-dir", "", "Value for handlers_dir, directory of the HTTP handlers package"),
+		"migrations-dir": flagSet.String("migrations-dir", "", "Value for migrations_dir, directory of the SQL migrations package"),
+		"template-set":   flagSet.String("template-set", "", "Value for template_set, the sqlcrud templates: sqlx, database-sql or pgx"),
+	}
+	yesF := flagSet.Bool("yes", false, "Accept the suggested settings and write the file without asking")
+	err := flagSet.Parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	setFlags := make(map[string]bool)
+	flagSet.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+
+	moduleFS, _, err := osModuleFS()
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	cfg, err := config.LoadFS(moduleFS, true)
+	if err != nil {
+		log.Printf("config.LoadFS failed: %v", err)
+		return 1
+	}
+	if len(cfg.Settings) > 0 {
+		fmt.Fprintf(promptOut, "Updating existing .gocode/gocode.toml, current settings are used as the defaults.\n")
+	}
+
+	settings, err := suggestConfig(moduleFS)
+	if err != nil {
+		log.Printf("error scanning module: %v", err)
+		return 1
+	}
+
+	for _, s := range settings {
+		value := s.Value
+		switch {
+		case setFlags[s.Flag]:
+			cfg.Settings[s.Key] = *flagValues[s.Flag]
+			continue
+		case cfg.Settings[s.Key] != nil:
+			value = cfg.GetString(s.Key, value)
+			s.Reason = "current setting"
+		}
+		if *yesF {
+			cfg.Settings[s.Key] = value
+			continue
+		}
+		ans, err := ask(fmt.Sprintf("%s (%s)", s.Key, s.Reason), value)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		cfg.Settings[s.Key] = ans
+	}
+
+	fmt.Fprintf(promptOut, "\n")
+	_, err = cfg.WriteTo(promptOut)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	fmt.Fprintf(promptOut, "\n")
+
+	if !*yesF {
+		ok, err := confirm("Write these settings to .gocode/gocode.toml?", true)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		if !ok {
+			log.Printf("aborted, nothing written")
+			return 1
+		}
+	}
+
+	err = config.StoreFS(moduleFS, cfg)
+	if err != nil {
+		log.Printf("error writing config: %v", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Wrote .gocode/gocode.toml\n")
+
+	return 0
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/psanford/memfs"
+)
+
+func TestSuggestConfig(t *testing.T) {
+
+	type tcase struct {
+		name     string
+		files    map[string]string
+		expected map[string]string
+	}
+
+	tcaseList := []tcase{
+		{
+			name: "empty",
+			files: map[string]string{
+				"go.mod": "module test1\n",
+			},
+			expected: map[string]string{
+				"store_dir":      "store",
+				"handlers_dir":   "handlers",
+				"migrations_dir": "migrations",
+				"template_set":   "sqlx",
+			},
+		},
+		{
+			name: "sqlx_pgx",
+			files: map[string]string{
+				"go.mod":                              "module test1\n\nrequire github.com/jackc/pgx/v4 v4.13.0\n",
+				"internal/db/store.go":                "package db\n\nimport \"github.com/jmoiron/sqlx\"\n\ntype Store struct{ db *sqlx.DB }\n",
+				"internal/api/handlers.go":            "package api\n\nimport \"net/http\"\n\nvar _ http.Handler\n",
+				"internal/webhandlers/handlers.go":    "package webhandlers\n\nimport \"net/http\"\n\nvar _ http.Handler\n",
+				"db/schema/20210101000000_start.sql":  "-- +goose Up\nCREATE TABLE a (id int);\n",
+				"internal/migrations/migrations.go":   "package migrations\n",
+				"internal/migrations/testdata/x.sql":  "-- +goose Up\n",
+				"internal/db/testdata/store.go":       "package x\n\nimport \"go.mongodb.org/mongo-driver/mongo\"\n",
+				"internal/db/.hidden/ignore_me.go":    "package x\n",
+				"internal/webhandlers/handlers_ex.go": "package webhandlers\n",
+			},
+			expected: map[string]string{
+				"store_dir":      "internal/db",
+				"handlers_dir":   "internal/webhandlers",
+				"migrations_dir": "db/schema",
+				"template_set":   "pgx",
+			},
+		},
+		{
+			name: "named_dirs",
+			files: map[string]string{
+				"go.mod":                  "module test1\n",
+				"app/sqlstore/types.go":   "package sqlstore\n\nimport _ \"github.com/lib/pq\"\n",
+				"app/migrations/empty.go": "package migrations\n",
+			},
+			expected: map[string]string{
+				"store_dir":      "app/sqlstore",
+				"handlers_dir":   "app/handlers",
+				"migrations_dir": "app/migrations",
+				"template_set":   "database-sql",
+			},
+		},
+		{
+			name: "mongo",
+			files: map[string]string{
+				"go.mod":          "module test1\n",
+				"mstore/store.go": "package mstore\n\nimport \"go.mongodb.org/mongo-driver/mongo\"\n\ntype Store struct{ client *mongo.Client }\n",
+			},
+			expected: map[string]string{
+				"store_dir":    "mstore",
+				"handlers_dir": "handlers",
+			},
+		},
+	}
+
+	for _, tc := range tcaseList {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mfs := memfs.New()
+			for name, content := range tc.files {
+				must(t, mfs.MkdirAll(path.Dir(name), 0755))
+				must(t, mfs.WriteFile(name, []byte(content), 0644))
+			}
+			settings, err := suggestConfig(mfs)
+			must(t, err)
+			got := make(map[string]string)
+			for _, s := range settings {
+				t.Logf("%s = %q (%s)", s.Key, s.Value, s.Reason)
+				got[s.Key] = s.Value
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("unexpected settings: %v", got)
+			}
+		})
+	}
+}
@@ -1,5 +1,206 @@
-package main
-
-func main() {
-
-}
+// Command gocode is the entry point to the gocode tools.  Each tool is a
+// separate executable named gocode_<tool> (e.g. gocode_sqlcrud), and
+// `gocode <tool> [args]` finds it and runs it with the arguments passed through.
+// Plugins named gocodeplugin_<name> are run the same way, except gocode
+// talks to them using the protocol in the plugin package.
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/d0sbit/gocode/srcedit"
+)
+
+func main() {
+	os.Exit(maine(os.Args[1:]))
+}
+
+// maine is broken out so it can be tested separately
+func maine(args []string) int {
+
+	if len(args) < 1 {
+		usage(os.Stderr)
+		return 2
+	}
+
+	switch args[0] {
+
+	case "help", "-h", "-help", "--help":
+		if len(args) > 1 {
+			// `gocode help sqlcrud` is the same as `gocode sqlcrud -h`
+			return runTool(args[1], []string{"-h"})
+		}
+		usage(os.Stdout)
+		return 0
+
+	case "regen":
+		return runRegen(args[1:])
+
+	case "init":
+		return runInit(args[1:])
+
+	case "apply":
+		return runApply(args[1:])
+
+	case "ui":
+		return runUI(args[1:])
+
+	case "templates":
+		return runTemplates(args[1:])
+
+	case "list":
+		err := listTools(os.Stdout)
+		if err != nil {
+			log.Printf("error listing tools: %v", err)
+			return 1
+		}
+		return 0
+
+	}
+
+	// `gocode path/to/file.go` works out the tool from where the file is
+	if strings.HasSuffix(args[0], ".go") {
+		return runInferred(args[0], args[1:])
+	}
+
+	return runTool(args[0], args[1:])
+}
+
+// runTool executes the named tool with args, connecting it to our stdin, stdout and stderr,
+// and returns the exit code it returned.
+func runTool(name string, args []string) int {
+
+	toolPath, err := findTool(name)
+	if err != nil {
+		log.Printf("%v (try `gocode list` to see the installed tools)", err)
+		return 1
+	}
+
+	if isPlugin(toolPath) {
+		return runPlugin(name, toolPath, args)
+	}
+
+	cmd := exec.Command(toolPath, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode()
+		}
+		log.Printf("error running %q: %v", toolPath, err)
+		return 1
+	}
+
+	return 0
+}
+
+// osModuleFS finds the module the working directory is in and returns
+// an FS for it along with its directory on disk.
+func osModuleFS() (fs.FS, string, error) {
+	rootFS, modDir, _, _, err := srcedit.FindOSWdModuleDir("")
+	if err != nil {
+		return nil, "", fmt.Errorf("error finding module directory: %w", err)
+	}
+	moduleFS, err := fs.Sub(rootFS, modDir)
+	if err != nil {
+		return nil, "", fmt.Errorf("fs.Sub error while constructing module fs: %w", err)
+	}
+	dirFS, ok := moduleFS.(srcedit.DirFS)
+	if !ok {
+		return nil, "", fmt.Errorf("module filesystem is not a directory")
+	}
+	return moduleFS, string(dirFS), nil
+}
+
+func usage(w io.Writer) {
+	fmt.Fprint(w, `gocode generates Go code following common patterns.
+
+Usage:
+
+	gocode <tool> [arguments]
+	gocode path/to/file.go [arguments]
+	gocode help [tool]
+	gocode list
+	gocode init [-yes]
+	gocode regen [-type X] [-tool Y] [-dry-run=term]
+	gocode ui [-addr host:port]
+	gocode templates upgrade [-tool X] [-yes]
+	gocode apply plan.json
+
+Each tool is a separate gocode_<tool> executable, found next to the gocode
+executable or on your PATH.  Plugins (gocodeplugin_<name> executables) are
+found the same way and run like tools.  Run "gocode list" to see which are installed
+and "gocode help <tool>" for the arguments a tool accepts.
+
+Given a file name instead of a tool, gocode works out the tool and its
+flags from the directory the file is in, using the store_dir and
+handlers_dir settings in .gocode/gocode.toml (default "store" and "handlers").
+
+"gocode init" looks through the module and writes .gocode/gocode.toml with
+suggested settings, asking you to confirm each one.
+
+Each successful tool run is recorded in .gocode/manifest.toml, and
+"gocode regen" runs them again with -replace, e.g. after upgrading gocode
+or changing a template.
+
+"gocode apply" applies a plan file written by running a tool with
+-plan plan.json, so the changes can be reviewed before they are made.  It
+refuses if any of the files the plan was made from have changed since.
+
+"gocode templates upgrade" merges changes to the built-in templates into the
+copies a tool's -install-templates put in .gocode/templates, showing the
+result and asking before writing it.
+
+"gocode ui" serves a page on localhost for picking a struct and a tool,
+setting its options, previewing the changes and applying them.
+
+`)
+	tools, err := findTools()
+	if err != nil || len(tools) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Installed tools:")
+	fmt.Fprintln(w)
+	writeToolList(w, tools)
+	fmt.Fprintln(w)
+}
+
+// listTools writes the name and description of each installed tool to w.
+func listTools(w io.Writer) error {
+	tools, err := findTools()
+	if err != nil {
+		return err
+	}
+	writeToolList(w, tools)
+	return nil
+}
+
+func writeToolList(w io.Writer, tools map[string]string) {
+
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, name := range names {
+		// tools that don't support -describe just show where they are
+		desc := "(" + tools[name] + ")"
+		if d, err := describeTool(name, tools[name]); err == nil && d.Description != "" {
+			desc = d.Description
+		}
+		fmt.Fprintf(tw, "\t%s\t%s\n", name, desc)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeTools creates a temp dir with shell script tools in it and points PATH at it.
+func fakeTools(t *testing.T, tools map[string]string) string {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+
+	dir := t.TempDir()
+	for name, script := range tools {
+		must(t, os.WriteFile(filepath.Join(dir, toolPrefix+name), []byte("#!/bin/sh\n"+script+"\n"), 0755))
+	}
+
+	oldPath := os.Getenv("PATH")
+	must(t, os.Setenv("PATH", dir+string(filepath.ListSeparator)+oldPath))
+	t.Cleanup(func() { os.Setenv("PATH", oldPath) })
+
+	return dir
+}
+
+func TestFindTools(t *testing.T) {
+
+	dir := fakeTools(t, map[string]string{
+		"sqlcrud": `echo '{"tool":"sqlcrud","description":"SQL CRUD test description","flags":[]}'`,
+		"example": "exit 1",
+	})
+	// not executable, should be ignored
+	must(t, os.WriteFile(filepath.Join(dir, toolPrefix+"noexec"), []byte("#!/bin/sh\n"), 0644))
+
+	tools, err := findTools()
+	must(t, err)
+
+	if tools["example"] != filepath.Join(dir, "gocode_example") {
+		t.Errorf("unexpected path for example tool: %q", tools["example"])
+	}
+	if _, ok := tools["noexec"]; ok {
+		t.Errorf("non-executable file reported as tool")
+	}
+
+	var buf bytes.Buffer
+	writeToolList(&buf, tools)
+	t.Logf("tool list:\n%s", buf.String())
+	if !strings.Contains(buf.String(), "SQL CRUD test description") {
+		t.Errorf("tool list missing sqlcrud description")
+	}
+	// no -describe support, shows the path instead
+	if !strings.Contains(buf.String(), "("+tools["example"]+")") {
+		t.Errorf("tool list missing example path")
+	}
+
+	_, err = findTool("doesnotexist")
+	if err == nil {
+		t.Errorf("expected error for missing tool")
+	}
+	_, err = findTool("../example")
+	if err == nil {
+		t.Errorf("expected error for invalid tool name")
+	}
+}
+
+func TestRunToolExitCode(t *testing.T) {
+
+	dir := fakeTools(t, map[string]string{
+		"exit3": "exit 3",
+		"args":  `echo "$@" > "$(dirname "$0")/args.txt"`,
+	})
+
+	if ret := maine([]string{"exit3"}); ret != 3 {
+		t.Errorf("expected exit code 3, got %d", ret)
+	}
+
+	if ret := maine([]string{"args", "-type", "A", "a.go"}); ret != 0 {
+		t.Errorf("expected exit code 0, got %d", ret)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "args.txt"))
+	must(t, err)
+	if strings.TrimSpace(string(b)) != "-type A a.go" {
+		t.Errorf("unexpected args passed through: %q", b)
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/psanford/memfs"
+
+	"github.com/d0sbit/gocode/codeflag"
+	"github.com/d0sbit/gocode/codeplan"
+	"github.com/d0sbit/gocode/config"
+	"github.com/d0sbit/gocode/plugin"
+	"github.com/d0sbit/gocode/srcedit"
+	"github.com/d0sbit/gocode/srcedit/diff"
+	"github.com/d0sbit/gocode/srcedit/model"
+)
+
+// isPlugin returns true if the executable at p is a plugin rather than a tool.
+func isPlugin(p string) bool {
+	return strings.HasPrefix(filepath.Base(p), plugin.Prefix)
+}
+
+// pluginFlags is the flags for running a plugin, the ones gocode handles
+// itself plus whatever the plugin declares in its description.
+type pluginFlags struct {
+	*codeflag.FlagSet
+
+	typeF, packageF, fileF, dryRunF, planF *string
+	noGofmtF, replaceF, jsonF, vF          *bool
+
+	pluginFlagNames []string
+}
+
+// newPluginFlags asks the plugin at pluginPath to describe itself and returns its flags.
+func newPluginFlags(name, pluginPath string) (*pluginFlags, error) {
+
+	res, err := plugin.Call(pluginPath, &plugin.Request{Op: "describe"}, os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	pd := res.Describe
+
+	flagSet := flag.NewFlagSet("gocode "+name, flag.ContinueOnError)
+	pf := &pluginFlags{FlagSet: codeflag.New(flagSet, name, pd.Description)}
+	pf.typeF = pf.String("type", "", "Type name of Go struct to generate code for", codeflag.Required())
+	pf.packageF = pf.String("package", "", "Package directory within module to analyze/edit")
+	pf.fileF = pf.String("file", "", "Filename for the main generated code, defaults to one based on the type name")
+	pf.dryRunF = pf.String("dry-run", "off", "Do not apply changes, only output diff of what would change. Value specifies format, 'term' for terminal pretty text, 'html' for HTML, or 'off' to disable.", codeflag.Transient())
+	pf.planF = pf.String("plan", "", "Write the changes to this file as a plan to review and apply later with `gocode apply`, instead of applying them", codeflag.Transient())
+	pf.noGofmtF = pf.Bool("no-gofmt", false, "Do not gofmt the output")
+	pf.replaceF = pf.Bool("replace", false, "Replace existing declarations with the newly generated ones instead of leaving them as-is", codeflag.Transient())
+	pf.jsonF = pf.Bool("json", false, "Write output as JSON", codeflag.Transient())
+	pf.vF = pf.Bool("v", false, "Verbose output", codeflag.Transient())
+
+	for _, fi := range pd.Flags {
+		if fi.Name == "describe" || flagSet.Lookup(fi.Name) != nil {
+			return nil, fmt.Errorf("plugin %q declares flag -%s which is handled by gocode", name, fi.Name)
+		}
+		var opts []codeflag.Option
+		if fi.Required {
+			opts = append(opts, codeflag.Required())
+		}
+		if fi.ConfigKey != "" {
+			opts = append(opts, codeflag.ConfigKey(fi.ConfigKey))
+		}
+		if fi.Transient {
+			opts = append(opts, codeflag.Transient())
+		}
+		// defaults come back from JSON as string, bool or float64
+		switch fi.Type {
+		case "string":
+			def, _ := fi.Default.(string)
+			pf.String(fi.Name, def, fi.Usage, opts...)
+		case "bool":
+			def, _ := fi.Default.(bool)
+			pf.Bool(fi.Name, def, fi.Usage, opts...)
+		case "int":
+			def, _ := fi.Default.(float64)
+			pf.Int(fi.Name, int(def), fi.Usage, opts...)
+		default:
+			return nil, fmt.Errorf("plugin %q flag -%s has unsupported type %q", name, fi.Name, fi.Type)
+		}
+		pf.pluginFlagNames = append(pf.pluginFlagNames, fi.Name)
+	}
+
+	for _, ex := range pd.Examples {
+		pf.Example(ex.Command, ex.Description)
+	}
+
+	return pf, nil
+}
+
+// pluginValues returns the value of each flag the plugin declared.
+func (pf *pluginFlags) pluginValues() map[string]string {
+	ret := make(map[string]string, len(pf.pluginFlagNames))
+	for _, name := range pf.pluginFlagNames {
+		ret[name] = pf.Lookup(name).Value.String()
+	}
+	return ret
+}
+
+// runPlugin is called for `gocode <name>` when name is a plugin, it does the
+// work a tool would do with the transforms the plugin responds with.
+func runPlugin(name, pluginPath string, args []string) int {
+
+	pf, err := newPluginFlags(name, pluginPath)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	err = pf.Parse(args, os.Stdout)
+	if err != nil {
+		return codeflag.ExitCode(err)
+	}
+	if pf.NArg() > 0 {
+		log.Printf("unexpected arguments: %q", pf.Args())
+		return 2
+	}
+
+	rootFS, modDir, packagePath, modPath, err := srcedit.FindOSWdModuleDir(*pf.packageF)
+	if err != nil {
+		log.Printf("error finding module directory: %v", err)
+		return 1
+	}
+	if *pf.vF {
+		log.Printf("rootFS=%v; modDir=%q, packagePath=%q, modPath=%q", rootFS, modDir, packagePath, modPath)
+	}
+
+	inFS, err := fs.Sub(rootFS, modDir)
+	if err != nil {
+		log.Printf("fs.Sub error while construct input fs: %v", err)
+		return 1
+	}
+
+	cfg, err := config.LoadFS(inFS, true)
+	if err != nil {
+		log.Printf("error loading config: %v", err)
+		return 1
+	}
+	err = pf.ApplyConfig(cfg)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if missing := pf.Missing(); len(missing) > 0 {
+		log.Printf("missing required flags: -%s", strings.Join(missing, ", -"))
+		return 2
+	}
+
+	// output is either same as input or memory for dry-run, and a plan isn't applied
+	var outFS fs.FS
+	var dryRunFS *memfs.FS
+	if *pf.dryRunF == "off" && *pf.planF == "" {
+		outFS = inFS
+	} else {
+		dryRunFS = memfs.New()
+		if packagePath != "" {
+			dryRunFS.MkdirAll(packagePath, 0755)
+		}
+		outFS = dryRunFS
+	}
+
+	pkg := srcedit.NewPackage(inFS, outFS, modPath, packagePath)
+
+	typeInfo, err := pkg.FindType(*pf.typeF)
+	if err != nil {
+		log.Printf("failed to find type %q: %v", *pf.typeF, err)
+		return 1
+	}
+	s, err := model.NewStruct(typeInfo, "")
+	if err != nil {
+		log.Printf("failed to find type %q: %v", *pf.typeF, err)
+		return 1
+	}
+
+	fileName := *pf.fileF
+	if fileName == "" {
+		fileName = srcedit.LowerForType(s.LocalName(), "-") + ".go"
+	}
+
+	req := &plugin.Request{
+		Op:          "generate",
+		ModulePath:  modPath,
+		Package:     packagePath,
+		PackageName: pkg.LocalName(),
+		ImportPath:  path.Join(modPath, packagePath),
+		File:        fileName,
+		Type:        plugin.NewStruct(s),
+		Flags:       pf.pluginValues(),
+		Config:      cfg.Settings,
+	}
+	res, err := plugin.Call(pluginPath, req, os.Stderr)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	trs, err := plugin.Transforms(res.Transforms)
+	if err != nil {
+		log.Printf("invalid response from plugin %q: %v", name, err)
+		return 1
+	}
+
+	// each Go file the plugin touched gets cleaned up like the tools do
+	fmtt := &srcedit.GofmtTransform{}
+	seen := make(map[string]bool)
+	for _, t := range res.Transforms {
+		if !strings.HasSuffix(t.File, ".go") || seen[t.File] {
+			continue
+		}
+		seen[t.File] = true
+		fmtt.FilenameList = append(fmtt.FilenameList, t.File)
+	}
+	trs = append(trs, &srcedit.FixImportsTransform{FilenameList: fmtt.FilenameList})
+	if !*pf.noGofmtF {
+		trs = append(trs, fmtt)
+	}
+
+	srcedit.SetOwner(trs, name)
+	srcedit.SetMerge(trs)
+	if *pf.replaceF {
+		srcedit.SetReplace(trs)
+	}
+
+	fileList := make([]string, 0, len(seen))
+	for _, fn := range fmtt.FilenameList {
+		fileList = append(fileList, path.Join(packagePath, fn))
+	}
+	for _, t := range res.Transforms {
+		if strings.HasSuffix(t.File, ".go") || seen[t.File] {
+			continue
+		}
+		seen[t.File] = true
+		fileList = append(fileList, path.Join(packagePath, t.File))
+	}
+
+	flags := pf.Values()
+	flags["package"] = packagePath
+	entry := config.ManifestEntry{
+		Tool:    name,
+		Type:    s.LocalName(),
+		Package: packagePath,
+		Files:   fileList,
+		Flags:   flags,
+	}
+
+	if *pf.planF != "" {
+		pl := codeplan.New(name, modPath)
+		err := pl.AddPackage(pkg, trs)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		pl.Manifest = &entry
+		err = pl.WriteFile(*pf.planF)
+		if err != nil {
+			log.Printf("error writing plan: %v", err)
+			return 1
+		}
+		log.Printf("wrote %s to %s", pl, *pf.planF)
+		return 0
+	}
+
+	err = pkg.ApplyTransforms(trs...)
+	if err != nil {
+		log.Printf("apply transform error: %v", err)
+		return 1
+	}
+	for _, c := range pkg.Conflicts() {
+		log.Print(c)
+	}
+	for _, a := range pkg.ImportAliases() {
+		log.Print(a)
+	}
+
+	if *pf.dryRunF == "off" {
+		err := config.RecordRunFS(inFS, entry)
+		if err != nil {
+			log.Printf("error recording run in %s: %v", config.ManifestPath, err)
+			return 1
+		}
+		return 0
+	}
+
+	diffMap, err := diff.Run(inFS, outFS, ".", *pf.dryRunF)
+	if err != nil {
+		log.Printf("error running diff: %v", err)
+		return 1
+	}
+	if *pf.jsonF {
+		enc := json.NewEncoder(os.Stdout)
+		enc.Encode(map[string]interface{}{
+			"diff": diffMap,
+		})
+	} else {
+		klist := make([]string, 0, len(diffMap))
+		for k := range diffMap {
+			klist = append(klist, k)
+		}
+		sort.Strings(klist)
+		for _, k := range klist {
+			fmt.Printf("### %s\n", k)
+			fmt.Println(diffMap[k])
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/d0sbit/gocode/config"
+	"github.com/d0sbit/gocode/plugin"
+	"github.com/d0sbit/gocode/srcedit"
+)
+
+func TestRunPlugin(t *testing.T) {
+
+	dir := fakeTools(t, nil)
+	must(t, os.WriteFile(filepath.Join(dir, plugin.Prefix+"example"), []byte(`#!/bin/sh
+req=$(cat)
+case "$req" in
+*'"op":"describe"'*)
+	echo '{"describe":{"tool":"example","description":"Example plugin","flags":[{"name":"suffix","type":"string","default":"Store","usage":"Type name suffix"}]}}'
+	;;
+*)
+	printf '%s\n' "$req" > "$(dirname "$0")/req.json"
+	printf '%s\n' '{"transforms":[{"kind":"type","file":"widget.go","name":"WidgetX","text":"type WidgetX struct{}"},{"kind":"file","file":"widget.sql","text":"SELECT 1;\n"}]}'
+	;;
+esac
+`), 0755))
+
+	modDir := t.TempDir()
+	must(t, os.WriteFile(filepath.Join(modDir, "go.mod"), []byte("module test1\n"), 0644))
+	must(t, os.Mkdir(filepath.Join(modDir, "store"), 0755))
+	must(t, os.WriteFile(filepath.Join(modDir, "store", "types.go"), []byte("package store\n\ntype Widget struct {\n\tWidgetID string `db:\"widget_id\"`\n}\n"), 0644))
+
+	wd, err := os.Getwd()
+	must(t, err)
+	must(t, os.Chdir(modDir))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	tools, err := findTools()
+	must(t, err)
+	d, err := describeTool("example", tools["example"])
+	must(t, err)
+	if d.Description != "Example plugin" || len(d.Flags) < 2 || d.Flags[0].Name != "type" || d.Flags[len(d.Flags)-1].Name != "suffix" {
+		t.Errorf("unexpected description: %#v", d)
+	}
+
+	if ret := maine([]string{"example", "-type", "Widget", "-package", "store", "-suffix", "X"}); ret != 0 {
+		t.Fatalf("unexpected exit code %d", ret)
+	}
+
+	var req plugin.Request
+	b, err := os.ReadFile(filepath.Join(dir, "req.json"))
+	must(t, err)
+	must(t, json.Unmarshal(b, &req))
+	if req.Protocol != plugin.Protocol || req.Op != "generate" || req.ImportPath != "test1/store" ||
+		req.PackageName != "store" || req.File != "widget.go" || req.Flags["suffix"] != "X" ||
+		req.Type == nil || req.Type.Name != "Widget" || len(req.Type.Fields) != 1 || !req.Type.Fields[0].PK {
+		t.Errorf("unexpected request: %s", b)
+	}
+
+	b, err = os.ReadFile(filepath.Join(modDir, "store", "widget.go"))
+	must(t, err)
+	if !strings.Contains(string(b), "type WidgetX struct{}") {
+		t.Errorf("unexpected widget.go: %s", b)
+	}
+	b, err = os.ReadFile(filepath.Join(modDir, "store", "widget.sql"))
+	must(t, err)
+	if string(b) != "SELECT 1;\n" {
+		t.Errorf("unexpected widget.sql: %s", b)
+	}
+
+	m, err := config.LoadManifestFS(srcedit.DirFS(modDir))
+	must(t, err)
+	if l := m.Find("example", "Widget"); len(l) != 1 || l[0].Flags["suffix"] != "X" || len(l[0].Files) != 2 {
+		t.Errorf("unexpected manifest: %#v", m)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// promptIn and promptOut are where interactive questions are read from and written to.
+var (
+	promptIn            = bufio.NewReader(os.Stdin)
+	promptOut io.Writer = os.Stderr
+)
+
+// ask writes the question and returns the line the user entered,
+// or def if they just pressed enter.
+func ask(question, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(promptOut, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(promptOut, "%s: ", question)
+	}
+	line, err := promptIn.ReadString('\n')
+	if err != nil && !(err == io.EOF && line != "") {
+		return "", fmt.Errorf("reading answer: %w", err)
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return def, nil
+	}
+	return line, nil
+}
+
+// confirm asks a yes/no question, def is the answer if the user just presses enter.
+func confirm(question string, def bool) (bool, error) {
+	defStr := "y/N"
+	if def {
+		defStr = "Y/n"
+	}
+	for {
+		fmt.Fprintf(promptOut, "%s [%s]: ", question, defStr)
+		line, err := promptIn.ReadString('\n')
+		if err != nil && !(err == io.EOF && line != "") {
+			return false, fmt.Errorf("reading answer: %w", err)
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/d0sbit/gocode/config"
+)
+
+// regenArgs returns the arguments to run the tool for e again, replacing what it generated before.
+func regenArgs(e *config.ManifestEntry, dryRun string, jsonOut bool) []string {
+	args := []string{"-replace"}
+	if dryRun != "off" {
+		args = append(args, "-dry-run="+dryRun)
+		if jsonOut {
+			args = append(args, "-json")
+		}
+	}
+	// flags go before positional args
+	return append(args, e.CommandArgs()...)
+}
+
+// regenDiff is what regenerating one manifest entry would change, for -dry-run with -json.
+// Each entry's diff is kept separate since several can change the same file, e.g. store.go.
+type regenDiff struct {
+	Tool    string            `json:"tool"`
+	Type    string            `json:"type"`
+	Package string            `json:"package"`
+	Diff    map[string]string `json:"diff"` // file name to diff, as the tool reports it
+}
+
+// runRegen is called for `gocode regen`, it runs each generation recorded in
+// .gocode/manifest.toml again, replacing the code generated last time.
+func runRegen(args []string) int {
+
+	flagSet := flag.NewFlagSet("gocode regen", flag.ContinueOnError)
+	typeF := flagSet.String("type", "", "Only regenerate code for this type")
+	toolF := flagSet.String("tool", "", "Only regenerate code from this tool")
+	dryRunF := flagSet.String("dry-run", "off", "Do not apply changes, only output diff of what would change. Value specifies format, 'term' for terminal pretty text, 'html' for HTML, or 'off' to disable.")
+	jsonF := flagSet.Bool("json", false, "Write dry-run output as JSON")
+	err := flagSet.Parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	moduleFS, moduleDir, err := osModuleFS()
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	m, err := config.LoadManifestFS(moduleFS)
+	if err != nil {
+		log.Printf("error loading %s: %v", config.ManifestPath, err)
+		return 1
+	}
+	entries := m.Find(*toolF, *typeF)
+	if len(entries) == 0 {
+		log.Printf("nothing to regenerate, no matching entries in %s", config.ManifestPath)
+		return 1
+	}
+
+	jsonOut := *jsonF && *dryRunF != "off"
+	diffList := make([]regenDiff, 0, len(entries))
+
+	for i := range entries {
+		e := &entries[i]
+
+		toolPath, err := findTool(e.Tool)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+
+		toolArgs := regenArgs(e, *dryRunF, *jsonF)
+		fmt.Fprintf(os.Stderr, "Regenerating %s in %q with %s: gocode %s %s\n",
+			e.Type, e.Package, e.Tool, e.Tool, strings.Join(toolArgs, " "))
+
+		cmd, err := toolCommand(e.Tool, toolPath, toolArgs...)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		var stdout bytes.Buffer
+		cmd.Dir = moduleDir
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if jsonOut {
+			cmd.Stdout = &stdout
+		}
+		err = cmd.Run()
+		if err != nil {
+			log.Printf("error regenerating %s with %s: %v", e.Type, e.Tool, err)
+			return 1
+		}
+
+		// each tool reports its own diff, listed by entry
+		if jsonOut {
+			var res struct {
+				Diff map[string]string `json:"diff"`
+			}
+			err := json.Unmarshal(stdout.Bytes(), &res)
+			if err != nil {
+				log.Printf("error parsing %s output: %v", e.Tool, err)
+				return 1
+			}
+			diffList = append(diffList, regenDiff{Tool: e.Tool, Type: e.Type, Package: e.Package, Diff: res.Diff})
+		}
+	}
+
+	if jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.Encode(map[string]interface{}{
+			"entries": diffList,
+		})
+	}
+
+	if *dryRunF == "off" {
+		names := make([]string, 0, len(entries))
+		for _, e := range entries {
+			names = append(names, e.Tool+" "+e.Type)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(os.Stderr, "Regenerated %d: %s\n", len(names), strings.Join(names, ", "))
+	}
+
+	return 0
+}
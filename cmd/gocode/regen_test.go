@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/d0sbit/gocode/config"
+	"github.com/d0sbit/gocode/srcedit"
+)
+
+func TestRegenArgs(t *testing.T) {
+
+	e := &config.ManifestEntry{
+		Tool:  "handlercrud",
+		Flags: map[string]string{"store-dir": "db"},
+		Args:  []string{"handlers/widget.go"},
+	}
+
+	args := regenArgs(e, "off", true)
+	if !reflect.DeepEqual(args, []string{"-replace", "-store-dir=db", "handlers/widget.go"}) {
+		t.Errorf("unexpected args: %q", args)
+	}
+
+	args = regenArgs(e, "html", true)
+	if !reflect.DeepEqual(args, []string{"-replace", "-dry-run=html", "-json", "-store-dir=db", "handlers/widget.go"}) {
+		t.Errorf("unexpected args: %q", args)
+	}
+}
+
+func TestRunRegen(t *testing.T) {
+
+	dir := fakeTools(t, map[string]string{
+		"example": `echo "$@" >> "$(dirname "$0")/args.txt"`,
+	})
+
+	modDir := t.TempDir()
+	must(t, os.WriteFile(filepath.Join(modDir, "go.mod"), []byte("module test1\n"), 0644))
+	m := &config.Manifest{}
+	m.Record(config.ManifestEntry{Tool: "example", Type: "A", Package: "a", Flags: map[string]string{"type": "A"}})
+	m.Record(config.ManifestEntry{Tool: "example", Type: "B", Package: "a", Flags: map[string]string{"type": "B"}})
+	must(t, config.StoreManifestFS(srcedit.DirFS(modDir), m))
+
+	wd, err := os.Getwd()
+	must(t, err)
+	must(t, os.Chdir(modDir))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	if ret := runRegen([]string{"-type", "B"}); ret != 0 {
+		t.Fatalf("unexpected exit code %d", ret)
+	}
+	if ret := runRegen([]string{"-type", "C"}); ret != 1 {
+		t.Errorf("expected exit code 1 for no matches, got %d", ret)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "args.txt"))
+	must(t, err)
+	if strings.TrimSpace(string(b)) != "-replace -type=B" {
+		t.Errorf("unexpected tool args: %q", b)
+	}
+}
+
+func TestRunRegenJSON(t *testing.T) {
+
+	// both change the same file
+	fakeTools(t, map[string]string{
+		"example": `echo '{"diff":{"a/store.go":"'"$4"'"}}'`,
+	})
+
+	modDir := t.TempDir()
+	must(t, os.WriteFile(filepath.Join(modDir, "go.mod"), []byte("module test1\n"), 0644))
+	m := &config.Manifest{}
+	m.Record(config.ManifestEntry{Tool: "example", Type: "A", Package: "a", Flags: map[string]string{"type": "A"}})
+	m.Record(config.ManifestEntry{Tool: "example", Type: "B", Package: "a", Flags: map[string]string{"type": "B"}})
+	must(t, config.StoreManifestFS(srcedit.DirFS(modDir), m))
+
+	wd, err := os.Getwd()
+	must(t, err)
+	must(t, os.Chdir(modDir))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	r, w, err := os.Pipe()
+	must(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	ret := runRegen([]string{"-dry-run=html", "-json"})
+	os.Stdout = stdout
+	w.Close()
+	if ret != 0 {
+		t.Fatalf("unexpected exit code %d", ret)
+	}
+
+	var res struct {
+		Entries []regenDiff `json:"entries"`
+	}
+	must(t, json.NewDecoder(r).Decode(&res))
+	expected := []regenDiff{
+		{Tool: "example", Type: "A", Package: "a", Diff: map[string]string{"a/store.go": "-type=A"}},
+		{Tool: "example", Type: "B", Package: "a", Diff: map[string]string{"a/store.go": "-type=B"}},
+	}
+	if !reflect.DeepEqual(res.Entries, expected) {
+		t.Errorf("unexpected output: %+v", res.Entries)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/d0sbit/gocode/codetmpl"
+)
+
+// installedTemplateTools returns the names of the tools with templates installed in the module.
+func installedTemplateTools(moduleFS fs.FS) ([]string, error) {
+	pathList, err := fs.Glob(moduleFS, path.Join(codetmpl.Root, "*", codetmpl.InstalledName))
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0, len(pathList))
+	for _, p := range pathList {
+		ret = append(ret, path.Base(path.Dir(p)))
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// runTemplates is called for `gocode templates <command>`.
+func runTemplates(args []string) int {
+	if len(args) < 1 || args[0] != "upgrade" {
+		fmt.Fprintln(os.Stderr, "usage: gocode templates upgrade [-tool X] [-dry-run=term] [-yes]")
+		return 2
+	}
+	return runTemplatesUpgrade(args[1:])
+}
+
+// runTemplatesUpgrade is called for `gocode templates upgrade`, it has each tool with templates
+// installed in the module merge the changes to its built-in templates into them, showing
+// the result and asking before anything is written.
+func runTemplatesUpgrade(args []string) int {
+
+	flagSet := flag.NewFlagSet("gocode templates upgrade", flag.ContinueOnError)
+	toolF := flagSet.String("tool", "", "Only upgrade the templates for this tool")
+	dryRunF := flagSet.String("dry-run", "off", "Only show what would change, in this format: 'term' for terminal pretty text, 'html' for HTML, or 'off' to show it and then ask whether to write it.")
+	yesF := flagSet.Bool("yes", false, "Write the changes without asking")
+	err := flagSet.Parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	moduleFS, moduleDir, err := osModuleFS()
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	toolList, err := installedTemplateTools(moduleFS)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if *toolF != "" {
+		found := false
+		for _, name := range toolList {
+			found = found || name == *toolF
+		}
+		if !found {
+			log.Printf("no templates installed for %s in %s", *toolF, codetmpl.Dir(*toolF))
+			return 1
+		}
+		toolList = []string{*toolF}
+	}
+	if len(toolList) == 0 {
+		log.Printf("no templates installed in %s (see `gocode help <tool>` for -install-templates)", codetmpl.Root)
+		return 1
+	}
+
+	// run reports whether each tool ran successfully
+	run := func(toolArgs ...string) bool {
+		for _, name := range toolList {
+			toolPath, err := findTool(name)
+			if err != nil {
+				log.Print(err)
+				return false
+			}
+			cmd, err := toolCommand(name, toolPath, append([]string{"-upgrade-templates"}, toolArgs...)...)
+			if err != nil {
+				log.Print(err)
+				return false
+			}
+			cmd.Dir = moduleDir
+			cmd.Stdin = os.Stdin
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			err = cmd.Run()
+			if err != nil {
+				log.Printf("error upgrading %s templates: %v", name, err)
+				return false
+			}
+		}
+		return true
+	}
+
+	dryRun := *dryRunF
+	if dryRun == "off" {
+		dryRun = "term"
+	}
+	if !run("-dry-run=" + dryRun) {
+		return 1
+	}
+	if *dryRunF != "off" {
+		return 0
+	}
+
+	if !*yesF {
+		ok, err := confirm("Write these changes?", false)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		if !ok {
+			return 1
+		}
+	}
+
+	if !run() {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunTemplatesUpgrade(t *testing.T) {
+
+	dir := fakeTools(t, map[string]string{
+		"example": `echo "$@" >> "$(dirname "$0")/args.txt"`,
+		"other":   "exit 1",
+	})
+
+	modDir := t.TempDir()
+	must(t, os.WriteFile(filepath.Join(modDir, "go.mod"), []byte("module test1\n"), 0644))
+	must(t, os.MkdirAll(filepath.Join(modDir, ".gocode/templates/example"), 0755))
+	must(t, os.WriteFile(filepath.Join(modDir, ".gocode/templates/example/installed.toml"), []byte(""), 0644))
+	// customized by hand, not installed, so not upgraded
+	must(t, os.MkdirAll(filepath.Join(modDir, ".gocode/templates/other"), 0755))
+	must(t, os.WriteFile(filepath.Join(modDir, ".gocode/templates/other/other.tmpl"), []byte(""), 0644))
+
+	wd, err := os.Getwd()
+	must(t, err)
+	must(t, os.Chdir(modDir))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	if ret := maine([]string{"templates", "upgrade", "-tool", "other"}); ret != 1 {
+		t.Errorf("expected exit code 1 for tool without installed templates, got %d", ret)
+	}
+	if ret := maine([]string{"templates", "upgrade", "-yes"}); ret != 0 {
+		t.Fatalf("unexpected exit code %d", ret)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "args.txt"))
+	must(t, err)
+	if string(b) != "-upgrade-templates -dry-run=term\n-upgrade-templates\n" {
+		t.Errorf("unexpected tool args: %q", b)
+	}
+}
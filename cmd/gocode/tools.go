@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/d0sbit/gocode/codeflag"
+	"github.com/d0sbit/gocode/plugin"
+)
+
+// toolPrefix is what each tool executable name starts with, e.g. "gocode_sqlcrud" is the "sqlcrud" tool.
+const toolPrefix = "gocode_"
+
+// describeTool runs the tool at toolPath with -describe and returns the description it outputs.
+// For plugins the description includes the flags gocode handles for it.
+func describeTool(name, toolPath string) (*codeflag.Description, error) {
+	if isPlugin(toolPath) {
+		pf, err := newPluginFlags(name, toolPath)
+		if err != nil {
+			return nil, err
+		}
+		return pf.Describe(), nil
+	}
+	b, err := exec.Command(toolPath, "-describe").Output()
+	if err != nil {
+		return nil, fmt.Errorf("running %q -describe: %w", toolPath, err)
+	}
+	var d codeflag.Description
+	err = json.Unmarshal(b, &d)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %q -describe output: %w", toolPath, err)
+	}
+	return &d, nil
+}
+
+// toolCommand returns the command to run the tool at toolPath with args.  Plugins are
+// run through the gocode executable, which does the work of a tool on their behalf.
+func toolCommand(name, toolPath string, args ...string) (*exec.Cmd, error) {
+	if !isPlugin(toolPath) {
+		return exec.Command(toolPath, args...), nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("finding gocode executable to run plugin %q: %w", name, err)
+	}
+	return exec.Command(exe, append([]string{name}, args...)...), nil
+}
+
+// toolDirs returns the directories to search for tools, in order of preference:
+// the directory the gocode executable is in followed by each PATH entry.
+func toolDirs() []string {
+
+	var ret []string
+	seen := make(map[string]bool)
+	add := func(dir string) {
+		if dir == "" {
+			dir = "."
+		}
+		dir = filepath.Clean(dir)
+		if seen[dir] {
+			return
+		}
+		seen[dir] = true
+		ret = append(ret, dir)
+	}
+
+	exe, err := os.Executable()
+	if err == nil {
+		if rexe, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = rexe
+		}
+		add(filepath.Dir(exe))
+	}
+
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		add(dir)
+	}
+
+	return ret
+}
+
+// toolName returns the tool name for an executable file name or empty string if it is
+// not a tool or plugin, e.g. "gocode_sqlcrud.exe" returns "sqlcrud" and
+// "gocodeplugin_example" returns "example".
+func toolName(fileName string) string {
+	if runtime.GOOS == "windows" {
+		if !strings.EqualFold(filepath.Ext(fileName), ".exe") {
+			return ""
+		}
+		fileName = fileName[:len(fileName)-len(".exe")]
+	}
+	for _, prefix := range []string{toolPrefix, plugin.Prefix} {
+		if strings.HasPrefix(fileName, prefix) {
+			return strings.TrimPrefix(fileName, prefix)
+		}
+	}
+	return ""
+}
+
+// isExecutable returns true if the file at p is a regular file we could run.
+func isExecutable(p string) bool {
+	st, err := os.Stat(p)
+	if err != nil || st.IsDir() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return true // extension was already checked by toolName
+	}
+	return st.Mode()&0111 != 0
+}
+
+// findTools returns a map of tool name to the path of its executable for every tool found.
+// When the same tool is in more than one directory the first one in toolDirs() wins,
+// and within a directory a tool wins over a plugin with the same name.
+func findTools() (map[string]string, error) {
+
+	ret := make(map[string]string)
+
+	for _, dir := range toolDirs() {
+		dirEntryList, err := os.ReadDir(dir)
+		if err != nil {
+			continue // PATH commonly contains directories that don't exist
+		}
+		for _, de := range dirEntryList {
+			name := toolName(de.Name())
+			if name == "" {
+				continue
+			}
+			if p, ok := ret[name]; ok && (filepath.Dir(p) != dir || !isPlugin(p)) {
+				continue
+			}
+			p := filepath.Join(dir, de.Name())
+			if !isExecutable(p) {
+				continue
+			}
+			ret[name] = p
+		}
+	}
+
+	return ret, nil
+}
+
+// findTool returns the path to the executable for the named tool or plugin.
+func findTool(name string) (string, error) {
+
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid tool name %q", name)
+	}
+
+	fileName, pluginFileName := toolPrefix+name, plugin.Prefix+name
+	if runtime.GOOS == "windows" {
+		fileName += ".exe"
+		pluginFileName += ".exe"
+	}
+
+	for _, dir := range toolDirs() {
+		for _, fn := range []string{fileName, pluginFileName} {
+			p := filepath.Join(dir, fn)
+			if isExecutable(p) {
+				return p, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("tool %q not found (looked for %s or %s next to gocode and on PATH)", name, fileName, pluginFileName)
+}
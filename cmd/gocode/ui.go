@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/d0sbit/gocode/codeflag"
+)
+
+//go:embed ui.html
+var uiHTML []byte
+
+// uiHiddenFlags are flags the UI sets itself, or which switch the tool to
+// another mode, and so are not shown in the form.  Each tool's are listed in
+// uiTool.Hidden for the page.
+var uiHiddenFlags = map[string]bool{
+	"dry-run":           true,
+	"json":              true,
+	"describe":          true,
+	"install-templates": true,
+	"force":             true,
+	"upgrade-templates": true,
+	"plan":              true,
+	"uninstall":         true,
+}
+
+// uiPackage is a package in the module along with the structs declared in it.
+type uiPackage struct {
+	Dir     string   `json:"dir"`     // relative to the module, "" for the module root
+	Name    string   `json:"name"`    // package name
+	Structs []string `json:"structs"` // sorted struct type names
+}
+
+// uiTool is a tool that can be run from the UI.
+type uiTool struct {
+	Name     string                `json:"name"`
+	Describe *codeflag.Description `json:"describe"`
+	Preview  bool                  `json:"preview"` // true if it supports -dry-run=html -json
+	Hidden   []string              `json:"hidden"`  // its flags in uiHiddenFlags, left out of the form
+}
+
+// uiRunRequest is posted by the page to preview or apply a tool run.
+type uiRunRequest struct {
+	Tool  string            `json:"tool"`
+	Flags map[string]string `json:"flags"` // flag name to value, bools are "true" or "false"
+	Args  []string          `json:"args"`  // positional args
+}
+
+// uiRunResponse is the result of a preview or apply.
+type uiRunResponse struct {
+	Diff   map[string]string `json:"diff,omitempty"` // file name to HTML diff (preview only)
+	Output string            `json:"output"`         // anything the tool wrote to stderr (and stdout when applying)
+	Error  string            `json:"error,omitempty"`
+}
+
+// uiServer serves the `gocode ui` page and its API.
+type uiServer struct {
+	moduleFS  fs.FS
+	moduleDir string // OS path tools are run in
+	tools     map[string]*uiTool
+	mux       *http.ServeMux
+}
+
+func newUIServer(moduleFS fs.FS, moduleDir string, tools map[string]*uiTool) *uiServer {
+	s := &uiServer{
+		moduleFS:  moduleFS,
+		moduleDir: moduleDir,
+		tools:     tools,
+		mux:       http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/", s.serveIndex)
+	s.mux.HandleFunc("/api/packages", s.servePackages)
+	s.mux.HandleFunc("/api/tools", s.serveTools)
+	s.mux.HandleFunc("/api/preview", s.serveRun(true))
+	s.mux.HandleFunc("/api/apply", s.serveRun(false))
+	return s
+}
+
+func (s *uiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// only answer to localhost names, so another site can't get at this through DNS rebinding
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	if host != "localhost" && host != "127.0.0.1" && host != "::1" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *uiServer) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(uiHTML)
+}
+
+func (s *uiServer) servePackages(w http.ResponseWriter, r *http.Request) {
+	pkgs, err := scanPackages(s.moduleFS)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, pkgs)
+}
+
+func (s *uiServer) serveTools(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(s.tools))
+	for name := range s.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ret := make([]*uiTool, 0, len(names))
+	for _, name := range names {
+		ret = append(ret, s.tools[name])
+	}
+	writeJSON(w, ret)
+}
+
+// serveRun returns the handler which runs a tool, either as a dry-run preview or for real.
+func (s *uiServer) serveRun(preview bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// requiring a JSON content type means a browser won't send this cross-origin without a preflight
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "expected POST with application/json", http.StatusBadRequest)
+			return
+		}
+
+		var req uiRunRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		tool := s.tools[req.Tool]
+		if tool == nil {
+			http.Error(w, fmt.Sprintf("unknown tool %q", req.Tool), http.StatusBadRequest)
+			return
+		}
+		if preview && !tool.Preview {
+			http.Error(w, fmt.Sprintf("tool %q does not support -dry-run=html", req.Tool), http.StatusBadRequest)
+			return
+		}
+
+		var extra []string
+		if preview {
+			extra = []string{"-dry-run=html", "-json"}
+		}
+		args, err := toolArgs(tool.Describe, &req, extra...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		toolPath, err := findTool(req.Tool)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		cmd, err := toolCommand(req.Tool, toolPath, args...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var stdout, stderr bytes.Buffer
+		cmd.Dir = s.moduleDir
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		runErr := cmd.Run()
+
+		var res uiRunResponse
+		res.Output = stderr.String()
+		if runErr != nil {
+			res.Error = runErr.Error()
+		}
+		if preview {
+			if runErr == nil {
+				err := json.Unmarshal(stdout.Bytes(), &res)
+				if err != nil {
+					res.Error = fmt.Sprintf("parsing tool output: %v", err)
+				}
+			}
+		} else {
+			res.Output = stdout.String() + res.Output
+		}
+
+		if !preview {
+			log.Printf("ran gocode %s %s (err=%v)", req.Tool, strings.Join(args, " "), runErr)
+		}
+
+		writeJSON(w, res)
+	}
+}
+
+// toolArgs makes the command line for req, checking each flag against the tool description.
+// The extra args go after the flags and before any positional args.
+func toolArgs(d *codeflag.Description, req *uiRunRequest, extra ...string) ([]string, error) {
+
+	known := make(map[string]string, len(d.Flags)) // name to type
+	for _, fi := range d.Flags {
+		known[fi.Name] = fi.Type
+	}
+	for name, v := range req.Flags {
+		typ, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown flag %q for tool %q", name, d.Tool)
+		}
+		if uiHiddenFlags[name] {
+			// a page that still shows the checkbox sends "false", which is the same as leaving it out
+			if typ == "bool" && (v == "false" || v == "") {
+				continue
+			}
+			return nil, fmt.Errorf("unknown flag %q for tool %q", name, d.Tool)
+		}
+	}
+
+	var ret []string
+	for _, fi := range d.Flags {
+		v := req.Flags[fi.Name]
+		if v == "" || uiHiddenFlags[fi.Name] {
+			continue
+		}
+		if fi.Type == "bool" {
+			if v == "true" {
+				ret = append(ret, "-"+fi.Name)
+			}
+			continue
+		}
+		ret = append(ret, "-"+fi.Name+"="+v)
+	}
+
+	ret = append(ret, extra...)
+
+	for _, a := range req.Args {
+		if a == "" {
+			continue
+		}
+		if d.Args == nil {
+			return nil, fmt.Errorf("tool %q does not accept arguments", d.Tool)
+		}
+		ret = append(ret, a)
+	}
+
+	return ret, nil
+}
+
+// scanPackages returns each package in the module with the structs declared in it.
+func scanPackages(moduleFS fs.FS) ([]uiPackage, error) {
+
+	var ret []uiPackage
+
+	err := walkPackageDirs(moduleFS, func(dir string) error {
+		pkg, err := scanPackageDir(moduleFS, dir)
+		if err != nil {
+			return err
+		}
+		if pkg != nil {
+			ret = append(ret, *pkg)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// walkPackageDirs calls fn for each directory in the module that could hold a package ("." is the module root).
+// Hidden directories, testdata, vendor and nested modules are skipped.
+func walkPackageDirs(moduleFS fs.FS, fn func(dir string) error) error {
+	return fs.WalkDir(moduleFS, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if p != "." {
+			name := d.Name()
+			if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor" {
+				return fs.SkipDir
+			}
+			if _, err := fs.Stat(moduleFS, path.Join(p, "go.mod")); err == nil {
+				return fs.SkipDir
+			}
+		}
+		return fn(p)
+	})
+}
+
+// scanPackageDir returns the package in dir, or nil if it has no Go files.
+func scanPackageDir(moduleFS fs.FS, dir string) (*uiPackage, error) {
+
+	dirEntryList, err := fs.ReadDir(moduleFS, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret *uiPackage
+	fset := token.NewFileSet()
+	for _, de := range dirEntryList {
+		name := de.Name()
+		if de.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		b, err := fs.ReadFile(moduleFS, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		f, err := parser.ParseFile(fset, name, b, 0)
+		if err != nil {
+			continue // files that don't parse can't have anything for us to generate from
+		}
+		if ret == nil {
+			ret = &uiPackage{Dir: strings.TrimPrefix(dir, "."), Name: f.Name.Name, Structs: []string{}}
+		}
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if _, ok := ts.Type.(*ast.StructType); ok {
+					ret.Structs = append(ret.Structs, ts.Name.Name)
+				}
+			}
+		}
+	}
+
+	if ret != nil {
+		sort.Strings(ret.Structs)
+	}
+	return ret, nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Printf("error writing JSON response: %v", err)
+	}
+}
+
+// runUI is called for `gocode ui`, it serves a page on localhost for picking a type and
+// a tool, previewing the changes it would make and applying them.
+func runUI(args []string) int {
+
+	flagSet := flag.NewFlagSet("gocode ui", flag.ContinueOnError)
+	addrF := flagSet.String("addr", "127.0.0.1:8030", "Address to listen on, should be a localhost address")
+	err := flagSet.Parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	moduleFS, moduleDir, err := osModuleFS()
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	toolPaths, err := findTools()
+	if err != nil {
+		log.Printf("error finding tools: %v", err)
+		return 1
+	}
+	tools := make(map[string]*uiTool, len(toolPaths))
+	for name, p := range toolPaths {
+		d, err := describeTool(name, p)
+		if err != nil {
+			log.Printf("skipping tool %q: %v", name, err)
+			continue
+		}
+		tool := &uiTool{Name: name, Describe: d}
+		var hasDryRun, hasJSON bool
+		for _, fi := range d.Flags {
+			hasDryRun = hasDryRun || (fi.Name == "dry-run" && fi.Type == "string")
+			hasJSON = hasJSON || (fi.Name == "json" && fi.Type == "bool")
+			if uiHiddenFlags[fi.Name] {
+				tool.Hidden = append(tool.Hidden, fi.Name)
+			}
+		}
+		tool.Preview = hasDryRun && hasJSON
+		tools[name] = tool
+	}
+
+	ln, err := net.Listen("tcp", *addrF)
+	if err != nil {
+		log.Printf("listen error: %v", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "gocode ui for %s running at http://%s/ (Ctrl+C to stop)\n", moduleDir, ln.Addr())
+
+	err = http.Serve(ln, newUIServer(moduleFS, moduleDir, tools))
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/psanford/memfs"
+
+	"github.com/d0sbit/gocode/codeflag"
+)
+
+func TestScanPackages(t *testing.T) {
+
+	mfs := memfs.New()
+	must(t, mfs.WriteFile("go.mod", []byte("module test1\n"), 0644))
+	must(t, mfs.WriteFile("main.go", []byte("package main\n\nfunc main() {}\n"), 0644))
+	must(t, mfs.MkdirAll("store", 0755))
+	must(t, mfs.WriteFile("store/types.go", []byte("package store\n\ntype Widget struct{}\n\ntype ID string\n\ntype (\n\tAThing struct{}\n)\n"), 0644))
+	must(t, mfs.WriteFile("store/types_test.go", []byte("package store\n\ntype testThing struct{}\n"), 0644))
+	must(t, mfs.MkdirAll("store/testdata", 0755))
+	must(t, mfs.WriteFile("store/testdata/x.go", []byte("package x\n\ntype X struct{}\n"), 0644))
+	must(t, mfs.MkdirAll("sub", 0755))
+	must(t, mfs.WriteFile("sub/go.mod", []byte("module test1/sub\n"), 0644))
+	must(t, mfs.WriteFile("sub/sub.go", []byte("package sub\n\ntype Sub struct{}\n"), 0644))
+
+	pkgs, err := scanPackages(mfs)
+	must(t, err)
+
+	expected := []uiPackage{
+		{Dir: "", Name: "main", Structs: []string{}},
+		{Dir: "store", Name: "store", Structs: []string{"AThing", "Widget"}},
+	}
+	if !reflect.DeepEqual(pkgs, expected) {
+		t.Errorf("unexpected packages: %#v", pkgs)
+	}
+}
+
+func TestToolArgs(t *testing.T) {
+
+	d := &codeflag.Description{
+		Tool: "example",
+		Flags: []codeflag.FlagInfo{
+			{Name: "type", Type: "string"},
+			{Name: "no-gofmt", Type: "bool"},
+			{Name: "v", Type: "bool"},
+			{Name: "dry-run", Type: "string"},
+			{Name: "force", Type: "bool"},
+		},
+		Args: &codeflag.ArgInfo{Name: "file.go"},
+	}
+
+	type tcase struct {
+		name   string
+		req    uiRunRequest
+		args   []string
+		errtxt string
+	}
+
+	tcaseList := []tcase{
+		{
+			name: "flags_and_args",
+			req:  uiRunRequest{Flags: map[string]string{"type": "Widget", "no-gofmt": "true", "v": "false"}, Args: []string{"store/widget.go", ""}},
+			args: []string{"-type=Widget", "-no-gofmt", "-dry-run=html", "-json", "store/widget.go"},
+		},
+		{
+			name:   "unknown_flag",
+			req:    uiRunRequest{Flags: map[string]string{"other": "x"}},
+			errtxt: `unknown flag "other" for tool "example"`,
+		},
+		{
+			name:   "hidden_flag",
+			req:    uiRunRequest{Flags: map[string]string{"dry-run": "off"}},
+			errtxt: `unknown flag "dry-run" for tool "example"`,
+		},
+		{
+			name: "hidden_bool_false",
+			req:  uiRunRequest{Flags: map[string]string{"type": "Widget", "force": "false"}},
+			args: []string{"-type=Widget", "-dry-run=html", "-json"},
+		},
+		{
+			name:   "hidden_bool_true",
+			req:    uiRunRequest{Flags: map[string]string{"force": "true"}},
+			errtxt: `unknown flag "force" for tool "example"`,
+		},
+	}
+
+	for _, tc := range tcaseList {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			args, err := toolArgs(d, &tc.req, "-dry-run=html", "-json")
+			if tc.errtxt != "" {
+				if err == nil || err.Error() != tc.errtxt {
+					t.Fatalf("unexpected error: expected=%q, got=%v", tc.errtxt, err)
+				}
+				return
+			}
+			must(t, err)
+			if !reflect.DeepEqual(args, tc.args) {
+				t.Errorf("unexpected args: %q", args)
+			}
+		})
+	}
+}
+
+func TestUIServerPreview(t *testing.T) {
+
+	fakeTools(t, map[string]string{
+		"example": `echo '{"diff":{"store/widget.go":"<ins>'"$*"'</ins>"}}'`,
+	})
+
+	mfs := memfs.New()
+	tools := map[string]*uiTool{
+		"example": {
+			Name: "example",
+			Describe: &codeflag.Description{
+				Tool:  "example",
+				Flags: []codeflag.FlagInfo{{Name: "type", Type: "string"}},
+			},
+			Preview: true,
+		},
+	}
+	s := newUIServer(mfs, t.TempDir(), tools)
+
+	body := `{"tool":"example","flags":{"type":"Widget"}}`
+
+	// anything but a localhost name is refused
+	req := httptest.NewRequest("POST", "http://example.com/api/preview", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected forbidden, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("POST", "http://127.0.0.1:8030/api/preview", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	var res uiRunResponse
+	must(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	if res.Error != "" {
+		t.Fatalf("unexpected error: %s", res.Error)
+	}
+	if res.Diff["store/widget.go"] != "<ins>-type=Widget -dry-run=html -json</ins>" {
+		t.Errorf("unexpected diff: %#v", res.Diff)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"embed"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/fs"
@@ -14,12 +15,14 @@ import (
 	"strings"
 	"text/template"
 
+	"github.com/d0sbit/gocode/codeflag"
+	"github.com/d0sbit/gocode/codeplan"
+	"github.com/d0sbit/gocode/codetmpl"
 	"github.com/d0sbit/gocode/config"
 	"github.com/d0sbit/gocode/srcedit"
 	"github.com/d0sbit/gocode/srcedit/diff"
 	"github.com/d0sbit/gocode/srcedit/model"
 	"github.com/psanford/memfs"
-	"github.com/pterm/pterm"
 )
 
 //go:embed handlercrud.tmpl
@@ -27,7 +30,7 @@ var defaultTmplFS embed.FS
 
 func main() {
 	os.Exit(maine(
-		flag.NewFlagSet(os.Args[0], flag.PanicOnError),
+		flag.NewFlagSet(os.Args[0], flag.ContinueOnError),
 		os.Args[1:]))
 }
 
@@ -41,15 +44,60 @@ func maine(flagSet *flag.FlagSet, args []string) int {
 	// storeTestFileF := flagSet.String("store-test-file", "store_test.go", "Filename for the Store type tests")
 	// packageF := flagSet.String("package", "", "Package directory within module to analyze/edit")
 	// migrationsPackageF := flagSet.String("migrations-package", "", "Package directory to use for migrations, will default to ../migrations resolved against the package directory")
-	dryRunF := flagSet.Bool("dry-run", false, "Do not apply changes, only output diff of what would change.")
-	noGofmtF := flagSet.Bool("no-gofmt", false, "Do not gofmt the output")
-	// jsonF := flagSet.Bool("json", false, "Write output as JSON")
-	vF := flagSet.Bool("v", false, "Verbose output")
-	// allF := flagSet.Bool("all", false, "Generate all methods")
+	codeFlags := codeflag.New(flagSet, "handlercrud", "REST HTTP handlers for a struct with a generated store")
+	storeDirF := codeFlags.String("store-dir", "store", "Directory suffix of the store package, used to find the store package for the handlers package", codeflag.ConfigKey("store_dir"))
+	handlersDirF := codeFlags.String("handlers-dir", "handlers", "Directory suffix the handlers package must have", codeflag.ConfigKey("handlers_dir"))
+	dryRunF := codeFlags.String("dry-run", "off", "Do not apply changes, only output diff of what would change. Value specifies format, 'term' for terminal pretty text, 'html' for HTML, or 'off' to disable.", codeflag.Transient())
+	noGofmtF := codeFlags.Bool("no-gofmt", false, "Do not gofmt the output")
+	replaceF := codeFlags.Bool("replace", false, "Replace existing declarations with the newly generated ones instead of leaving them as-is", codeflag.Transient())
+	jsonF := codeFlags.Bool("json", false, "Write output as JSON", codeflag.Transient())
+	vF := codeFlags.Bool("v", false, "Verbose output", codeflag.Transient())
+	installTemplatesF := codeFlags.Bool("install-templates", false, "Copy the built-in templates into .gocode/templates/handlercrud for editing, instead of generating code", codeflag.Transient())
+	forceF := codeFlags.Bool("force", false, "With -install-templates, overwrite templates that have been customized", codeflag.Transient())
+	upgradeTemplatesF := codeFlags.Bool("upgrade-templates", false, "Merge changes to the built-in templates into the ones in .gocode/templates/handlercrud, instead of generating code", codeflag.Transient())
+	planF := codeFlags.String("plan", "", "Write the changes to this file as a plan to review and apply later with `gocode apply`, instead of applying them", codeflag.Transient())
+	uninstallF := codeFlags.Bool("uninstall", false, "Remove the code generated for the type instead of generating it, code shared with other types is kept while they use it", codeflag.Transient())
+	// allF := codeFlags.Bool("all", false, "Generate all methods")
+	codeFlags.Positional("file.go", "File in the handlers package to generate handlers into, the type is found in the store package from the file name", true)
+	codeFlags.Example("gocode handlercrud handlers/widget.go", "Generate handlers for the Widget type in the store package")
+	codeFlags.Example("gocode handlercrud -dry-run=term handlers/widget.go", "Show what would change without writing anything")
+	codeFlags.Example("gocode handlercrud -install-templates", "Copy the built-in templates into .gocode/templates/handlercrud to customize them")
+	codeFlags.Example("gocode handlercrud -uninstall handlers/widget.go", "Remove the handlers generated for Widget")
+	codeFlags.Example("gocode handlercrud -plan widget-plan.json handlers/widget.go", "Save the changes to widget-plan.json to apply later with `gocode apply widget-plan.json`")
+
+	err := codeFlags.Parse(args, os.Stdout)
+	if err != nil {
+		return codeflag.ExitCode(err)
+	}
 
-	flagSet.Parse(args)
+	if *installTemplatesF {
+		res, err := codetmpl.RunInstall(defaultTmplFS, "handlercrud", *forceF, *dryRunF, *jsonF, os.Stdout)
+		if err != nil {
+			log.Fatalf("error installing templates: %v", err)
+		}
+		if *dryRunF == "off" {
+			log.Printf("installed templates: %d written, %d unchanged, %d customized overwritten", len(res.Written), len(res.Unchanged), len(res.Customized))
+		}
+		return 0
+	}
 
-	pterm.Info.Println("Hello!")
+	if *upgradeTemplatesF {
+		res, err := codetmpl.RunUpgrade(defaultTmplFS, "handlercrud", *dryRunF, *jsonF, os.Stdout)
+		if err != nil {
+			log.Fatalf("error upgrading templates: %v", err)
+		}
+		if *dryRunF == "off" {
+			log.Printf("upgraded templates: %d updated, %d merged, %d with conflicts, %d unchanged, %d skipped",
+				len(res.Updated), len(res.Merged), len(res.Conflicts), len(res.Unchanged), len(res.Skipped))
+		}
+		for _, p := range res.Conflicts {
+			log.Printf("conflicts marked with <<<<<<< in %s need to be resolved", p)
+		}
+		for _, p := range res.Skipped {
+			log.Printf("skipped %s, it is customized but the built-in version it came from is not known, or it is no longer built-in", p)
+		}
+		return 0
+	}
 
 	_ = vF
 
@@ -85,10 +133,10 @@ func maine(flagSet *flag.FlagSet, args []string) int {
 		log.Fatalf("fs.Sub error while construct input fs: %v", err)
 	}
 
-	// output is either same as input or memory for dry-run
+	// output is either same as input or memory for dry-run, and a plan isn't applied
 	var outFS fs.FS
 	var dryRunFS *memfs.FS
-	if !*dryRunF {
+	if *dryRunF == "off" && *planF == "" {
 		outFS = inFS
 		// if migrationsPackagePath != "" {
 		// 	mda, ok := outFS.(srcedit.MkdirAller)
@@ -122,15 +170,19 @@ func maine(flagSet *flag.FlagSet, args []string) int {
 	// if err != nil {
 	// 	log.Fatal(err)
 	// }
-	config, err := config.LoadFS(inFS, true)
+	cfg, err := config.LoadFS(inFS, true)
 	if err != nil {
 		log.Fatalf("config.LoadFS failed: %v", err)
 	}
+	err = codeFlags.ApplyConfig(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
 	// TODO: we should distinguish between the directory with stores and the directory with types at some later point,
 	// but for now we assume these are always the same
-	storeDir := config.GetString("store_dir", "store")
+	storeDir := *storeDirF
 
-	handlersDir := config.GetString("handlers_dir", "handlers")
+	handlersDir := *handlersDirF
 
 	// TODO: make functions that:
 	// 1. verify a given path matches the specified suffix (i.e. "is this in the handlers folder")
@@ -156,6 +208,13 @@ func maine(flagSet *flag.FlagSet, args []string) int {
 	if *vF {
 		log.Printf("storePkgPath: %s", storePkgPath)
 	}
+	if dryRunFS != nil {
+		// the store package is loaded through outFS too, so it needs to exist there
+		err := dryRunFS.MkdirAll(storePkgPath, 0755)
+		if err != nil {
+			log.Fatalf("MkdirAll for %q: %v", storePkgPath, err)
+		}
+	}
 
 	// NVM: if there's no store then there's no types so don't bother
 	// check if storeDir exists, if not then prompt before mkdir
@@ -201,81 +260,114 @@ func maine(flagSet *flag.FlagSet, args []string) int {
 		Struct:          s,
 		StoreImportPath: path.Join(modPath, storePkgPath),
 	}
-	tmpl, err := template.New("_main_").Funcs(funcMap).ParseFS(defaultTmplFS, "handlercrud.tmpl")
+	tmpl, projectTmplList, err := codetmpl.Parse(template.New("_main_").Funcs(funcMap), defaultTmplFS, []string{"handlercrud.tmpl"}, inFS, "handlercrud")
 	if err != nil {
 		log.Fatalf("template parse error: %v", err)
 	}
-
-	fmtt := &srcedit.GofmtTransform{}
-	var trs []srcedit.Transform
-
-	{
-		fn := "handlerutil.go"
-		fmtt.FilenameList = append(fmtt.FilenameList, fn)
-		trList, err := tmplToTransforms(fn, data, tmpl, "HandlerUtil")
-		if err != nil {
-			log.Fatalf("tmplToTransforms for %q error: %v", fn, err)
-		}
-		trs = append(trs, trList...)
+	if *vF && len(projectTmplList) > 0 {
+		log.Printf("using project templates: %v", projectTmplList)
 	}
 
-	{
-		// fn := filepath.Join(wdPackagePath, fileNamePart)
-		fn := fileNamePart
-		fmtt.FilenameList = append(fmtt.FilenameList, fn)
-		trList, err := tmplToTransforms(fn, data, tmpl, "Handler", "HandlerMethods")
+	if *uninstallF {
+		if *planF != "" {
+			log.Fatalf("-plan cannot be used with -uninstall")
+		}
+		err := uninstall(handlersPkg, data, tmpl, packageFiles(fileNamePart, strings.TrimSuffix(fileNamePart, ".go")+"_test.go"), !*noGofmtF)
 		if err != nil {
-			log.Fatalf("tmplToTransforms for %q error: %v", fn, err)
+			log.Fatalf("uninstall error: %v", err)
 		}
-		trs = append(trs, trList...)
+		for _, c := range handlersPkg.Conflicts() {
+			log.Print(c)
+		}
+		if *dryRunF == "off" {
+			err := config.RemoveRunFS(inFS, "handlercrud", wdPackagePath, typeInfo.Name())
+			if err != nil {
+				log.Fatalf("error removing run from %s: %v", config.ManifestPath, err)
+			}
+		} else {
+			printDiff(inFS, outFS, *dryRunF, *jsonF)
+		}
+		return 0
 	}
 
-	{
-		// fn := filepath.Join(wdPackagePath, strings.TrimSuffix(fileNamePart, ".go")+"_test.go")
-		fn := strings.TrimSuffix(fileNamePart, ".go") + "_test.go"
-		fmtt.FilenameList = append(fmtt.FilenameList, fn)
-		trList, err := tmplToTransforms(fn, data, tmpl, "TestHandler")
+	fmtt := &srcedit.GofmtTransform{}
+	var trs []srcedit.Transform
+
+	for _, f := range packageFiles(fileNamePart, strings.TrimSuffix(fileNamePart, ".go")+"_test.go") {
+		fmtt.FilenameList = append(fmtt.FilenameList, f.name)
+		trList, err := tmplToTransforms(f.name, data, tmpl, f.blocks...)
 		if err != nil {
-			log.Fatalf("tmplToTransforms for %q error: %v", fn, err)
+			log.Fatalf("tmplToTransforms for %q error: %v", f.name, err)
 		}
 		trs = append(trs, trList...)
 	}
 
-	dd := &srcedit.DedupImportsTransform{
+	fi := &srcedit.FixImportsTransform{
 		FilenameList: fmtt.FilenameList,
 	}
-	trs = append(trs, dd)
+	trs = append(trs, fi)
 
 	if !*noGofmtF {
 		trs = append(trs, fmtt)
 	}
 
+	// generated structs that already exist keep any fields added to them
+	srcedit.SetMerge(trs)
+
+	if *replaceF {
+		srcedit.SetReplace(trs)
+	}
+
+	// files written, for the manifest
+	var fileList []string
+	for _, fn := range fmtt.FilenameList {
+		fileList = append(fileList, path.Join(wdPackagePath, fn))
+	}
+	flags := codeFlags.Values()
+	entry := config.ManifestEntry{
+		Tool:    "handlercrud",
+		Type:    typeInfo.Name(),
+		Package: wdPackagePath,
+		Files:   fileList,
+		Flags:   flags,
+		Args:    []string{path.Join(wdPackagePath, fileNamePart)},
+	}
+
+	if *planF != "" {
+		pl := codeplan.New("handlercrud", modPath)
+		err := pl.AddPackage(handlersPkg, trs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		pl.Manifest = &entry
+		err = pl.WriteFile(*planF)
+		if err != nil {
+			log.Fatalf("error writing plan: %v", err)
+		}
+		log.Printf("wrote %s to %s", pl, *planF)
+		return 0
+	}
+
 	err = handlersPkg.ApplyTransforms(trs...)
 	if err != nil {
 		log.Fatalf("apply transform error: %v", err)
 	}
+	for _, c := range handlersPkg.Conflicts() {
+		log.Print(c)
+	}
+	for _, a := range handlersPkg.ImportAliases() {
+		log.Print(a)
+	}
 
-	if *dryRunF {
-		diffMap, err := diff.Run(inFS, outFS, ".", "term")
+	if *dryRunF == "off" {
+		err := config.RecordRunFS(inFS, entry)
 		if err != nil {
-			log.Fatalf("error running diff: %v", err)
-		}
-		// if *jsonF {
-		// 	enc := json.NewEncoder(os.Stdout)
-		// 	enc.Encode(map[string]interface{}{
-		// 		"diff": diffMap,
-		// 	})
-		// } else {
-		klist := make([]string, 0, len(diffMap))
-		for k := range diffMap {
-			klist = append(klist, k)
+			log.Fatalf("error recording run in %s: %v", config.ManifestPath, err)
 		}
-		sort.Strings(klist)
-		for _, k := range klist {
-			fmt.Printf("### %s\n", k)
-			fmt.Println(diffMap[k])
-		}
-		// }
+	}
+
+	if *dryRunF != "off" {
+		printDiff(inFS, outFS, *dryRunF, *jsonF)
 	}
 
 	// ----
@@ -561,6 +653,76 @@ func maine(flagSet *flag.FlagSet, args []string) int {
 	return 0
 }
 
+// printDiff writes the changes from inFS to outFS to stdout in the -dry-run format.
+func printDiff(inFS, outFS fs.FS, format string, jsonOut bool) {
+	diffMap, err := diff.Run(inFS, outFS, ".", format)
+	if err != nil {
+		log.Fatalf("error running diff: %v", err)
+	}
+	if jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.Encode(map[string]interface{}{
+			"diff": diffMap,
+		})
+	} else {
+		klist := make([]string, 0, len(diffMap))
+		for k := range diffMap {
+			klist = append(klist, k)
+		}
+		sort.Strings(klist)
+		for _, k := range klist {
+			fmt.Printf("### %s\n", k)
+			fmt.Println(diffMap[k])
+		}
+	}
+}
+
+// tmplFile is a file to generate and the template blocks written into it.
+type tmplFile struct {
+	name   string
+	blocks []string
+	shared bool // used by all types in the package, not just the one generated for
+}
+
+// packageFiles returns the files generated into the handlers package.
+func packageFiles(file, testFile string) []tmplFile {
+	return []tmplFile{
+		{"handlerutil.go", []string{"HandlerUtil"}, true},
+		{file, []string{"Handler", "HandlerMethods"}, false},
+		{testFile, []string{"TestHandler"}, false},
+	}
+}
+
+// uninstall removes the declarations generated into files from pkg.  The shared ones are only
+// removed if nothing else in the package refers to them, i.e. this was the last type using them.
+// Imports that are no longer used are removed afterwards.
+func uninstall(pkg *srcedit.Package, data interface{}, tmpl *template.Template, files []tmplFile, gofmt bool) error {
+
+	fmtt := &srcedit.GofmtTransform{}
+	var typeTrs, sharedTrs []srcedit.Transform
+	for _, f := range files {
+		fmtt.FilenameList = append(fmtt.FilenameList, f.name)
+		trList, err := tmplToTransforms(f.name, data, tmpl, f.blocks...)
+		if err != nil {
+			return err
+		}
+		if f.shared {
+			sharedTrs = append(sharedTrs, srcedit.RemoveTransforms(trList)...)
+		} else {
+			typeTrs = append(typeTrs, srcedit.RemoveTransforms(trList)...)
+		}
+	}
+
+	// shared code goes too once nothing is left that uses it, all of it applied together so a
+	// failure leaves the package as it was
+	trs := append(typeTrs, &srcedit.RemoveUnreferencedTransform{TransformList: sharedTrs})
+	trs = append(trs, &srcedit.RemoveUnusedImportsTransform{FilenameList: fmtt.FilenameList})
+	if gofmt {
+		trs = append(trs, fmtt)
+	}
+	return srcedit.ApplyPackageTransforms(srcedit.PackageTransforms{Package: pkg, Transforms: trs})
+}
+
 func tmplToTransforms(fileName string, data interface{}, tmpl *template.Template, tmplName ...string) ([]srcedit.Transform, error) {
 
 	var ret []srcedit.Transform
@@ -576,6 +738,7 @@ func tmplToTransforms(fileName string, data interface{}, tmpl *template.Template
 		if err != nil {
 			return ret, fmt.Errorf("%q transform parse error: %v", tName, err)
 		}
+		srcedit.SetOwner(trList, "handlercrud/"+tName)
 		ret = append(ret, trList...)
 
 	}
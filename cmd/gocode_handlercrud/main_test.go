@@ -2,13 +2,28 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+	"text/template"
+
+	"github.com/d0sbit/gocode/codecheck"
+	"github.com/d0sbit/gocode/codegolden"
+	"github.com/d0sbit/gocode/codetmpl"
+	"github.com/d0sbit/gocode/srcedit"
+	"github.com/d0sbit/gocode/srcedit/model"
 )
 
+var updateF = flag.Bool("update", false, "Rewrite the golden files in testdata/golden with the current output")
+
+// pkgDir is the directory of this package, other tests change the working directory
+var pkgDir, _ = os.Getwd()
+
 func TestMaineExec(t *testing.T) {
 
 	// uses sqlcrud to create the store, which this depends on, and then
@@ -76,6 +91,135 @@ type Example struct {
 
 }
 
+func TestTemplatesCompile(t *testing.T) {
+
+	// the generated code must type-check for each kind of struct, against a minimal store
+	checker := codecheck.New()
+
+	tmpl, _, err := codetmpl.Parse(template.New("_main_").Funcs(funcMap), defaultTmplFS, []string{"handlercrud.tmpl"}, nil, "handlercrud")
+	must(t, err)
+	tmpl, err = codecheck.Mark(tmpl)
+	must(t, err)
+
+	var fixtures []codecheck.Fixture
+	for _, f := range codecheck.SQLFixtures {
+		f.Name = "sql_" + f.Name
+		fixtures = append(fixtures, f)
+	}
+	for _, f := range codecheck.MongoFixtures {
+		f.Name = "mongo_" + f.Name
+		fixtures = append(fixtures, f)
+	}
+
+	for _, fixture := range fixtures {
+		fixture := fixture
+		t.Run(fixture.Name, func(t *testing.T) {
+
+			moduleFS, err := fixture.Module()
+			must(t, err)
+			must(t, moduleFS.MkdirAll("handlers", 0755))
+
+			storePkg := srcedit.NewPackage(moduleFS, moduleFS, codecheck.FixtureModule, codecheck.FixtureDir)
+			typeInfo, err := storePkg.FindType(codecheck.FixtureType)
+			must(t, err)
+			s, err := model.NewStruct(typeInfo, "")
+			must(t, err)
+			pks := s.FieldList().PK()
+			if len(pks) != 1 {
+				t.Skip("handlers only support a single primary key field")
+			}
+
+			// the parts of the store the handler uses
+			imports := `"context"`
+			if strings.HasPrefix(pks[0].GoTypeExpr(), "primitive.") {
+				imports += "\n\t\"go.mongodb.org/mongo-driver/bson/primitive\""
+			}
+			must(t, moduleFS.WriteFile(codecheck.FixtureDir+"/a-store.go", []byte(fmt.Sprintf(`package a
+
+import (
+	%s
+)
+
+type AStore struct{}
+
+func (s *AStore) SelectByID(ctx context.Context, id %s) (*A, error) { return nil, nil }
+`, imports, pks[0].GoTypeExpr())), 0644))
+
+			data := struct {
+				Struct          *model.Struct
+				StoreImportPath string
+			}{
+				Struct:          s,
+				StoreImportPath: codecheck.FixtureModule + "/" + codecheck.FixtureDir,
+			}
+
+			var trs []srcedit.Transform
+			fmtt := &srcedit.GofmtTransform{}
+			for _, f := range packageFiles("a.go", "a_test.go") {
+				trList, err := tmplToTransforms(f.name, data, tmpl, f.blocks...)
+				must(t, err)
+				trs = append(trs, trList...)
+				fmtt.FilenameList = append(fmtt.FilenameList, f.name)
+			}
+			trs = append(trs, &srcedit.FixImportsTransform{FilenameList: fmtt.FilenameList}, fmtt)
+			handlersPkg := srcedit.NewPackage(moduleFS, moduleFS, codecheck.FixtureModule, "handlers")
+			must(t, handlersPkg.ApplyTransforms(trs...))
+
+			errs, err := checker.Check(moduleFS, codecheck.FixtureModule, "handlers")
+			must(t, err)
+			for _, e := range errs {
+				t.Error(e)
+			}
+		})
+	}
+}
+
+func TestGolden(t *testing.T) {
+
+	// compare everything maine writes with testdata/golden, run with -update after changing the templates
+	defer os.Chdir(pkgDir)
+
+	fixtures := map[string]codecheck.Fixture{
+		"string_pk":      codecheck.SQLFixtures[0],
+		"int64_autoincr": codecheck.SQLFixtures[1],
+		"mongo_objectid": codecheck.MongoFixtures[0],
+	}
+	for name, fixture := range fixtures {
+		name, fixture := name, fixture
+		t.Run(name, func(t *testing.T) {
+
+			moduleFS, err := fixture.Module()
+			must(t, err)
+			modDir := t.TempDir()
+			must(t, codegolden.CopyToDir(moduleFS, modDir))
+			must(t, os.Chdir(modDir))
+			defer os.Chdir(pkgDir)
+
+			flset := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+			ret := maine(flset, []string{"-store-dir=" + codecheck.FixtureDir, "handlers/a.go"})
+			if ret != 0 {
+				t.Fatalf("ret = %d", ret)
+			}
+
+			must(t, codegolden.RemoveUnchanged(moduleFS, modDir))
+			diffs, err := codegolden.Compare(os.DirFS(modDir), ".", filepath.Join(pkgDir, "testdata/golden", name), *updateF)
+			must(t, err)
+			for _, fn := range sortedKeys(diffs) {
+				t.Errorf("%s does not match golden file (run with -update to accept):\n%s", fn, diffs[fn])
+			}
+		})
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
 func must(t *testing.T, err error) {
 	t.Helper()
 	if err != nil {
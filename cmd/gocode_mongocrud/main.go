@@ -9,12 +9,17 @@ import (
 	"io/fs"
 	"log"
 	"os"
+	"path"
 	"sort"
 	"strings"
 	"text/template"
 
 	"github.com/psanford/memfs"
 
+	"github.com/d0sbit/gocode/codeflag"
+	"github.com/d0sbit/gocode/codeplan"
+	"github.com/d0sbit/gocode/codetmpl"
+	"github.com/d0sbit/gocode/config"
 	"github.com/d0sbit/gocode/srcedit"
 	"github.com/d0sbit/gocode/srcedit/diff"
 	"github.com/d0sbit/gocode/srcedit/model"
@@ -25,33 +30,70 @@ var defaultTmplFS embed.FS
 
 func main() {
 	os.Exit(maine(
-		flag.NewFlagSet(os.Args[0], flag.PanicOnError),
+		flag.NewFlagSet(os.Args[0], flag.ContinueOnError),
 		os.Args[1:]))
 }
 
 // maine is broken out so it can be tested separately
 func maine(flagSet *flag.FlagSet, args []string) int {
 
-	// gocode mongocrud -type Workspace -file workspace.go -package ./mstore -create -read -update -delete -search -all
-
-	typeF := flagSet.String("type", "", "Type name of Go struct with fields corresponding to the MongoDB document")
-	fileF := flagSet.String("file", "", "Filename for the main type into which to add store code")
-	testFileF := flagSet.String("test-file", "", "Test filename into which to add code, defaults to file with _test.go suffix")
-	storeFileF := flagSet.String("store-file", "store.go", "Filename for the Store type")
-	storeTestFileF := flagSet.String("store-test-file", "store_test.go", "Filename for the Store type tests")
-	packageF := flagSet.String("package", "", "Package directory within module to analyze/edit")
-	dryRunF := flagSet.String("dry-run", "off", "Do not apply changes, only output diff of what would change. Value specifies format, 'term' for terminal pretty text, 'html' for HTML, or 'off' to disable.")
-	noGofmtF := flagSet.Bool("no-gofmt", false, "Do not gofmt the output")
-	jsonF := flagSet.Bool("json", false, "Write output as JSON")
-	vF := flagSet.Bool("v", false, "Verbose output")
-	// allF := flagSet.Bool("all", false, "Generate all methods")
+	codeFlags := codeflag.New(flagSet, "mongocrud", "MongoDB CRUD store methods for a struct")
+	typeF := codeFlags.String("type", "", "Type name of Go struct with fields corresponding to the MongoDB document", codeflag.Required())
+	fileF := codeFlags.String("file", "", "Filename for the main type into which to add store code")
+	testFileF := codeFlags.String("test-file", "", "Test filename into which to add code, defaults to file with _test.go suffix")
+	storeFileF := codeFlags.String("store-file", "store.go", "Filename for the Store type")
+	storeTestFileF := codeFlags.String("store-test-file", "store_test.go", "Filename for the Store type tests")
+	packageF := codeFlags.String("package", "", "Package directory within module to analyze/edit")
+	dryRunF := codeFlags.String("dry-run", "off", "Do not apply changes, only output diff of what would change. Value specifies format, 'term' for terminal pretty text, 'html' for HTML, or 'off' to disable.", codeflag.Transient())
+	noGofmtF := codeFlags.Bool("no-gofmt", false, "Do not gofmt the output")
+	replaceF := codeFlags.Bool("replace", false, "Replace existing declarations with the newly generated ones instead of leaving them as-is", codeflag.Transient())
+	jsonF := codeFlags.Bool("json", false, "Write output as JSON", codeflag.Transient())
+	vF := codeFlags.Bool("v", false, "Verbose output", codeflag.Transient())
+	installTemplatesF := codeFlags.Bool("install-templates", false, "Copy the built-in templates into .gocode/templates/mongocrud for editing, instead of generating code", codeflag.Transient())
+	forceF := codeFlags.Bool("force", false, "With -install-templates, overwrite templates that have been customized", codeflag.Transient())
+	upgradeTemplatesF := codeFlags.Bool("upgrade-templates", false, "Merge changes to the built-in templates into the ones in .gocode/templates/mongocrud, instead of generating code", codeflag.Transient())
+	planF := codeFlags.String("plan", "", "Write the changes to this file as a plan to review and apply later with `gocode apply`, instead of applying them", codeflag.Transient())
+	uninstallF := codeFlags.Bool("uninstall", false, "Remove the code generated for the type instead of generating it, code shared with other types is kept while they use it", codeflag.Transient())
+	// allF := codeFlags.Bool("all", false, "Generate all methods")
+	codeFlags.Example("gocode mongocrud -package mstore -type Workspace -file workspace.go", "Generate store methods for Workspace in mstore/workspace.go")
+	codeFlags.Example("gocode mongocrud -package mstore -type Workspace -dry-run=term", "Show what would change without writing anything")
+	codeFlags.Example("gocode mongocrud -install-templates", "Copy the built-in templates into .gocode/templates/mongocrud to customize them")
+	codeFlags.Example("gocode mongocrud -package mstore -type Workspace -uninstall", "Remove the store methods generated for Workspace")
+	codeFlags.Example("gocode mongocrud -package mstore -type Workspace -plan workspace-plan.json", "Save the changes to workspace-plan.json to apply later with `gocode apply workspace-plan.json`")
+
+	err := codeFlags.Parse(args, os.Stdout)
+	if err != nil {
+		return codeflag.ExitCode(err)
+	}
 
-	// TODO:
-	// - -dry-run
-	// - codeflag package with Usage() that dumps the flags as JSON
-	// - example command lines
+	if *installTemplatesF {
+		res, err := codetmpl.RunInstall(defaultTmplFS, "mongocrud", *forceF, *dryRunF, *jsonF, os.Stdout)
+		if err != nil {
+			log.Fatalf("error installing templates: %v", err)
+		}
+		if *dryRunF == "off" {
+			log.Printf("installed templates: %d written, %d unchanged, %d customized overwritten", len(res.Written), len(res.Unchanged), len(res.Customized))
+		}
+		return 0
+	}
 
-	flagSet.Parse(args)
+	if *upgradeTemplatesF {
+		res, err := codetmpl.RunUpgrade(defaultTmplFS, "mongocrud", *dryRunF, *jsonF, os.Stdout)
+		if err != nil {
+			log.Fatalf("error upgrading templates: %v", err)
+		}
+		if *dryRunF == "off" {
+			log.Printf("upgraded templates: %d updated, %d merged, %d with conflicts, %d unchanged, %d skipped",
+				len(res.Updated), len(res.Merged), len(res.Conflicts), len(res.Unchanged), len(res.Skipped))
+		}
+		for _, p := range res.Conflicts {
+			log.Printf("conflicts marked with <<<<<<< in %s need to be resolved", p)
+		}
+		for _, p := range res.Skipped {
+			log.Printf("skipped %s, it is customized but the built-in version it came from is not known, or it is no longer built-in", p)
+		}
+		return 0
+	}
 
 	typeName := *typeF
 	if typeName == "" {
@@ -79,8 +121,6 @@ func maine(flagSet *flag.FlagSet, args []string) int {
 
 	// values read from toml config should be reported but not as errors
 
-	// example command lines?
-
 	// analyze package glean structure
 
 	// for values like the package of where the crud stuff goes, this stuff should probably be
@@ -123,10 +163,10 @@ func maine(flagSet *flag.FlagSet, args []string) int {
 		log.Fatalf("fs.Sub error while construct input fs: %v", err)
 	}
 
-	// output is either same as input or memory for dry-run
+	// output is either same as input or memory for dry-run, and a plan isn't applied
 	var outFS fs.FS
 	var dryRunFS *memfs.FS
-	if *dryRunF == "off" {
+	if *dryRunF == "off" && *planF == "" {
 		outFS = inFS
 	} else {
 		dryRunFS = memfs.New()
@@ -176,49 +216,158 @@ func maine(flagSet *flag.FlagSet, args []string) int {
 	}{
 		Struct: s,
 	}
-	// TODO: check for and load module-specific template first
-	tmpl, err := template.New("_main_").ParseFS(defaultTmplFS, "mongocrud.tmpl")
+	tmpl, projectTmplList, err := codetmpl.Parse(template.New("_main_"), defaultTmplFS, []string{"mongocrud.tmpl"}, inFS, "mongocrud")
 	if err != nil {
 		log.Fatalf("template parse error: %v", err)
 	}
+	if *vF && len(projectTmplList) > 0 {
+		log.Printf("using project templates: %v", projectTmplList)
+	}
+
+	if *uninstallF {
+		if *planF != "" {
+			log.Fatalf("-plan cannot be used with -uninstall")
+		}
+		err := uninstall(pkg, data, tmpl, packageFiles(typeFilename, *testFileF, *storeFileF, *storeTestFileF), !*noGofmtF)
+		if err != nil {
+			log.Fatalf("uninstall error: %v", err)
+		}
+		for _, c := range pkg.Conflicts() {
+			log.Print(c)
+		}
+		if *dryRunF == "off" {
+			err := config.RemoveRunFS(inFS, "mongocrud", packagePath, typeName)
+			if err != nil {
+				log.Fatalf("error removing run from %s: %v", config.ManifestPath, err)
+			}
+		} else {
+			printDiff(inFS, outFS, *dryRunF, *jsonF)
+		}
+		return 0
+	}
 
 	fmtt := &srcedit.GofmtTransform{}
 	var trs []srcedit.Transform
 
-	{
-		fn := "mongoutil.go"
-		fmtt.FilenameList = append(fmtt.FilenameList, fn)
-		trList, err := tmplToTransforms(fn, data, tmpl, "MongoUtil")
+	// TODO: -no-test flag
+	for _, f := range packageFiles(typeFilename, *testFileF, *storeFileF, *storeTestFileF) {
+		fmtt.FilenameList = append(fmtt.FilenameList, f.name)
+		trList, err := tmplToTransforms(f.name, data, tmpl, f.blocks...)
 		if err != nil {
 			log.Fatal(err)
 		}
 		trs = append(trs, trList...)
 	}
 
-	{
-		fn := *storeFileF
-		fmtt.FilenameList = append(fmtt.FilenameList, fn)
-		trList, err := tmplToTransforms(fn, data, tmpl, "Store", "StoreMethods")
+	fi := &srcedit.FixImportsTransform{
+		FilenameList: fmtt.FilenameList,
+	}
+	trs = append(trs, fi)
+
+	if !*noGofmtF {
+		trs = append(trs, fmtt)
+	}
+
+	// generated structs that already exist keep any fields added to them
+	srcedit.SetMerge(trs)
+
+	if *replaceF {
+		srcedit.SetReplace(trs)
+	}
+
+	// files written, for the manifest
+	var fileList []string
+	for _, fn := range fmtt.FilenameList {
+		fileList = append(fileList, path.Join(packagePath, fn))
+	}
+	flags := codeFlags.Values()
+	flags["package"] = packagePath
+	entry := config.ManifestEntry{
+		Tool:    "mongocrud",
+		Type:    typeName,
+		Package: packagePath,
+		Files:   fileList,
+		Flags:   flags,
+	}
+
+	if *planF != "" {
+		pl := codeplan.New("mongocrud", modPath)
+		err := pl.AddPackage(pkg, trs)
 		if err != nil {
 			log.Fatal(err)
 		}
-		trs = append(trs, trList...)
+		pl.Manifest = &entry
+		err = pl.WriteFile(*planF)
+		if err != nil {
+			log.Fatalf("error writing plan: %v", err)
+		}
+		log.Printf("wrote %s to %s", pl, *planF)
+		return 0
+	}
+
+	err = pkg.ApplyTransforms(trs...)
+	if err != nil {
+		log.Fatalf("apply transform error: %v", err)
+	}
+	for _, c := range pkg.Conflicts() {
+		log.Print(c)
+	}
+	for _, a := range pkg.ImportAliases() {
+		log.Print(a)
 	}
 
-	{
-		fn := *storeTestFileF
-		fmtt.FilenameList = append(fmtt.FilenameList, fn)
-		trList, err := tmplToTransforms(fn, data, tmpl, "TestStore")
+	if *dryRunF == "off" {
+		err := config.RecordRunFS(inFS, entry)
 		if err != nil {
-			log.Fatal(err)
+			log.Fatalf("error recording run in %s: %v", config.ManifestPath, err)
 		}
-		trs = append(trs, trList...)
 	}
 
-	{
-		fn := typeFilename
-		fmtt.FilenameList = append(fmtt.FilenameList, fn)
-		trList, err := tmplToTransforms(fn, data, tmpl,
+	if *dryRunF != "off" {
+		printDiff(inFS, outFS, *dryRunF, *jsonF)
+	}
+
+	return 0
+}
+
+// printDiff writes the changes from inFS to outFS to stdout in the -dry-run format.
+func printDiff(inFS, outFS fs.FS, format string, jsonOut bool) {
+	diffMap, err := diff.Run(inFS, outFS, ".", format)
+	if err != nil {
+		log.Fatalf("error running diff: %v", err)
+	}
+	if jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.Encode(map[string]interface{}{
+			"diff": diffMap,
+		})
+	} else {
+		klist := make([]string, 0, len(diffMap))
+		for k := range diffMap {
+			klist = append(klist, k)
+		}
+		sort.Strings(klist)
+		for _, k := range klist {
+			fmt.Printf("### %s\n", k)
+			fmt.Println(diffMap[k])
+		}
+	}
+}
+
+// tmplFile is a file to generate and the template blocks written into it.
+type tmplFile struct {
+	name   string
+	blocks []string
+	shared bool // used by all types in the package, not just the one generated for
+}
+
+// packageFiles returns the files generated into the package with the type.
+func packageFiles(typeFile, testFile, storeFile, storeTestFile string) []tmplFile {
+	return []tmplFile{
+		{"mongoutil.go", []string{"MongoUtil"}, true},
+		{storeFile, []string{"Store", "StoreMethods"}, true},
+		{storeTestFile, []string{"TestStore"}, true},
+		{typeFile, []string{
 			"TYPEStore",
 			"TYPEStoreMethods",
 			// FIJXME: filter which things go here base on flags
@@ -229,64 +378,39 @@ func maine(flagSet *flag.FlagSet, args []string) int {
 			"TYPESelect",
 			"TYPESelectCursor",
 			"TYPECount",
-		)
-		if err != nil {
-			log.Fatal(err)
-		}
-		trs = append(trs, trList...)
-	}
-
-	{
-		// TODO: -no-test flag
-		fn := *testFileF
-		fmtt.FilenameList = append(fmtt.FilenameList, fn)
-		trList, err := tmplToTransforms(fn, data, tmpl,
-			"TestTYPE",
-		)
-		if err != nil {
-			log.Fatal(err)
-		}
-		trs = append(trs, trList...)
-	}
-
-	dd := &srcedit.DedupImportsTransform{
-		FilenameList: fmtt.FilenameList,
-	}
-	trs = append(trs, dd)
-
-	if !*noGofmtF {
-		trs = append(trs, fmtt)
+		}, false},
+		{testFile, []string{"TestTYPE"}, false},
 	}
+}
 
-	err = pkg.ApplyTransforms(trs...)
-	if err != nil {
-		log.Fatalf("apply transform error: %v", err)
-	}
+// uninstall removes the declarations generated into files from pkg.  The shared ones are only
+// removed if nothing else in the package refers to them, i.e. this was the last type using them.
+// Imports that are no longer used are removed afterwards.
+func uninstall(pkg *srcedit.Package, data interface{}, tmpl *template.Template, files []tmplFile, gofmt bool) error {
 
-	if *dryRunF != "off" {
-		diffMap, err := diff.Run(inFS, outFS, ".", *dryRunF)
+	fmtt := &srcedit.GofmtTransform{}
+	var typeTrs, sharedTrs []srcedit.Transform
+	for _, f := range files {
+		fmtt.FilenameList = append(fmtt.FilenameList, f.name)
+		trList, err := tmplToTransforms(f.name, data, tmpl, f.blocks...)
 		if err != nil {
-			log.Fatalf("error running diff: %v", err)
+			return err
 		}
-		if *jsonF {
-			enc := json.NewEncoder(os.Stdout)
-			enc.Encode(map[string]interface{}{
-				"diff": diffMap,
-			})
+		if f.shared {
+			sharedTrs = append(sharedTrs, srcedit.RemoveTransforms(trList)...)
 		} else {
-			klist := make([]string, 0, len(diffMap))
-			for k := range diffMap {
-				klist = append(klist, k)
-			}
-			sort.Strings(klist)
-			for _, k := range klist {
-				fmt.Printf("### %s\n", k)
-				fmt.Println(diffMap[k])
-			}
+			typeTrs = append(typeTrs, srcedit.RemoveTransforms(trList)...)
 		}
 	}
 
-	return 0
+	// shared code goes too once nothing is left that uses it, all of it applied together so a
+	// failure leaves the package as it was
+	trs := append(typeTrs, &srcedit.RemoveUnreferencedTransform{TransformList: sharedTrs})
+	trs = append(trs, &srcedit.RemoveUnusedImportsTransform{FilenameList: fmtt.FilenameList})
+	if gofmt {
+		trs = append(trs, fmtt)
+	}
+	return srcedit.ApplyPackageTransforms(srcedit.PackageTransforms{Package: pkg, Transforms: trs})
 }
 
 func tmplToTransforms(fileName string, data interface{}, tmpl *template.Template, tmplName ...string) ([]srcedit.Transform, error) {
@@ -304,6 +428,7 @@ func tmplToTransforms(fileName string, data interface{}, tmpl *template.Template
 		if err != nil {
 			return ret, fmt.Errorf("%q transform parse error: %v", tName, err)
 		}
+		srcedit.SetOwner(trList, "mongocrud/"+tName)
 		ret = append(ret, trList...)
 
 	}
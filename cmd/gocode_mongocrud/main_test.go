@@ -2,12 +2,27 @@ package main
 
 import (
 	"flag"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+	"text/template"
+
+	"github.com/d0sbit/gocode/codecheck"
+	"github.com/d0sbit/gocode/codegolden"
+	"github.com/d0sbit/gocode/codetmpl"
+	"github.com/d0sbit/gocode/srcedit"
+	"github.com/d0sbit/gocode/srcedit/model"
 )
 
+var updateF = flag.Bool("update", false, "Rewrite the golden files in testdata/golden with the current output")
+
+// pkgDir is the directory of this package, other tests change the working directory
+var pkgDir, _ = os.Getwd()
+
 func TestMaineExec(t *testing.T) {
 
 	// tests with execution of `go test` - requires "docker run ..." etc to work
@@ -139,6 +154,126 @@ type A struct {
 
 }
 
+func TestTemplatesCompile(t *testing.T) {
+
+	// the generated code must type-check for each kind of struct, without needing a database
+	checker := codecheck.New()
+
+	tmpl, _, err := codetmpl.Parse(template.New("_main_"), defaultTmplFS, []string{"mongocrud.tmpl"}, nil, "mongocrud")
+	must(t, err)
+	tmpl, err = codecheck.Mark(tmpl)
+	must(t, err)
+
+	for _, fixture := range codecheck.MongoFixtures {
+		fixture := fixture
+		t.Run(fixture.Name, func(t *testing.T) {
+
+			moduleFS, err := fixture.Module()
+			must(t, err)
+
+			pkg := srcedit.NewPackage(moduleFS, moduleFS, codecheck.FixtureModule, codecheck.FixtureDir)
+			typeInfo, err := pkg.FindType(codecheck.FixtureType)
+			must(t, err)
+			s, err := model.NewStruct(typeInfo, "")
+			must(t, err)
+			data := struct {
+				Struct *model.Struct
+			}{
+				Struct: s,
+			}
+
+			var trs []srcedit.Transform
+			fmtt := &srcedit.GofmtTransform{}
+			for _, f := range packageFiles("a-store.go", "a-store_test.go", "store.go", "store_test.go") {
+				trList, err := tmplToTransforms(f.name, data, tmpl, f.blocks...)
+				must(t, err)
+				trs = append(trs, trList...)
+				fmtt.FilenameList = append(fmtt.FilenameList, f.name)
+			}
+			trs = append(trs, &srcedit.FixImportsTransform{FilenameList: fmtt.FilenameList}, fmtt)
+			must(t, pkg.ApplyTransforms(trs...))
+
+			errs, err := checker.Check(moduleFS, codecheck.FixtureModule, codecheck.FixtureDir)
+			must(t, err)
+			for _, e := range errs {
+				t.Error(e)
+			}
+		})
+	}
+}
+
+func TestGolden(t *testing.T) {
+
+	// compare everything maine writes with testdata/golden, run with -update after changing the templates
+	defer os.Chdir(pkgDir)
+
+	for _, fixture := range codecheck.MongoFixtures {
+		fixture := fixture
+		t.Run(fixture.Name, func(t *testing.T) {
+
+			moduleFS, err := fixture.Module()
+			must(t, err)
+			modDir := t.TempDir()
+			must(t, codegolden.CopyToDir(moduleFS, modDir))
+			must(t, os.Chdir(modDir))
+			defer os.Chdir(pkgDir)
+
+			flset := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+			ret := maine(flset, []string{"-package=a", "-type=A"})
+			if ret != 0 {
+				t.Fatalf("ret = %d", ret)
+			}
+
+			must(t, codegolden.RemoveUnchanged(moduleFS, modDir))
+			diffs, err := codegolden.Compare(os.DirFS(modDir), ".", filepath.Join(pkgDir, "testdata/golden", fixture.Name), *updateF)
+			must(t, err)
+			for _, fn := range sortedKeys(diffs) {
+				t.Errorf("%s does not match golden file (run with -update to accept):\n%s", fn, diffs[fn])
+			}
+		})
+	}
+}
+
+func TestUninstall(t *testing.T) {
+
+	moduleFS, err := codecheck.MongoFixtures[0].Module()
+	must(t, err)
+	modDir := t.TempDir()
+	must(t, codegolden.CopyToDir(moduleFS, modDir))
+	must(t, os.Chdir(modDir))
+	defer os.Chdir(pkgDir)
+
+	for _, args := range [][]string{{"-package=a", "-type=A"}, {"-package=a", "-type=A", "-uninstall"}} {
+		ret := maine(flag.NewFlagSet(os.Args[0], flag.ContinueOnError), args)
+		if ret != 0 {
+			t.Fatalf("maine(%q) ret = %d", args, ret)
+		}
+	}
+
+	// A was the only type, so everything generated goes
+	for _, fn := range []string{"mongoutil.go", "store.go", "store_test.go", "a-store.go", "a-store_test.go"} {
+		b, err := ioutil.ReadFile(filepath.Join(modDir, "a", fn))
+		must(t, err)
+		if s := strings.TrimSpace(string(b)); s != "package a" {
+			t.Errorf("%s should only have a package clause left:\n%s", fn, s)
+		}
+	}
+	b, err := ioutil.ReadFile(filepath.Join(modDir, "a", "types.go"))
+	must(t, err)
+	if string(b) != codecheck.MongoFixtures[0].Src {
+		t.Errorf("types.go was changed:\n%s", b)
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
 func must(t *testing.T, err error) {
 	t.Helper()
 	if err != nil {
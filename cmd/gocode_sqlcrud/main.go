@@ -18,37 +18,97 @@ import (
 
 	"github.com/psanford/memfs"
 
+	"github.com/d0sbit/gocode/codeflag"
+	"github.com/d0sbit/gocode/codeplan"
+	"github.com/d0sbit/gocode/codetmpl"
+	"github.com/d0sbit/gocode/config"
 	"github.com/d0sbit/gocode/srcedit"
 	"github.com/d0sbit/gocode/srcedit/diff"
 	"github.com/d0sbit/gocode/srcedit/model"
 )
 
-//go:embed sqlcrud.tmpl
+//go:embed *.tmpl
 var defaultTmplFS embed.FS
 
+// templateSets maps each -template-set to the built-in template files it is made from.
+// The blocks in a later file replace the ones of the same name in sqlcrud.tmpl.
+var templateSets = map[string][]string{
+	"sqlx":         {"sqlcrud.tmpl"},
+	"database-sql": {"sqlcrud.tmpl", "sqlcrud-database-sql.tmpl"},
+	"pgx":          {"sqlcrud.tmpl", "sqlcrud-pgx.tmpl"},
+}
+
 func main() {
 	os.Exit(maine(
-		flag.NewFlagSet(os.Args[0], flag.PanicOnError),
+		flag.NewFlagSet(os.Args[0], flag.ContinueOnError),
 		os.Args[1:]))
 }
 
 // maine is broken out so it can be tested separately
 func maine(flagSet *flag.FlagSet, args []string) int {
 
-	typeF := flagSet.String("type", "", "Type name of Go struct with fields corresponding to the MongoDB document")
-	fileF := flagSet.String("file", "", "Filename for the main type into which to add store code")
-	testFileF := flagSet.String("test-file", "", "Test filename into which to add code, defaults to file with _test.go suffix")
-	storeFileF := flagSet.String("store-file", "store.go", "Filename for the Store type")
-	storeTestFileF := flagSet.String("store-test-file", "store_test.go", "Filename for the Store type tests")
-	packageF := flagSet.String("package", "", "Package directory within module to analyze/edit")
-	migrationsPackageF := flagSet.String("migrations-package", "", "Package directory to use for migrations, will default to ../migrations resolved against the package directory")
-	dryRunF := flagSet.String("dry-run", "off", "Do not apply changes, only output diff of what would change. Value specifies format, 'term' for terminal pretty text, 'html' for HTML, or 'off' to disable.")
-	noGofmtF := flagSet.Bool("no-gofmt", false, "Do not gofmt the output")
-	jsonF := flagSet.Bool("json", false, "Write output as JSON")
-	vF := flagSet.Bool("v", false, "Verbose output")
-	// allF := flagSet.Bool("all", false, "Generate all methods")
-
-	flagSet.Parse(args)
+	codeFlags := codeflag.New(flagSet, "sqlcrud", "SQL CRUD store methods for a struct")
+	typeF := codeFlags.String("type", "", "Type name of Go struct with fields corresponding to the database table", codeflag.Required())
+	fileF := codeFlags.String("file", "", "Filename for the main type into which to add store code")
+	testFileF := codeFlags.String("test-file", "", "Test filename into which to add code, defaults to file with _test.go suffix")
+	storeFileF := codeFlags.String("store-file", "store.go", "Filename for the Store type")
+	storeTestFileF := codeFlags.String("store-test-file", "store_test.go", "Filename for the Store type tests")
+	packageF := codeFlags.String("package", "", "Package directory within module to analyze/edit")
+	migrationsPackageF := codeFlags.String("migrations-package", "", "Package directory to use for migrations, will default to ../migrations resolved against the package directory", codeflag.ConfigKey("migrations_dir"))
+	templateSetF := codeFlags.String("template-set", "sqlx", "Templates to generate from: 'sqlx' for MySQL using sqlx, 'database-sql' for MySQL using only database/sql, or 'pgx' for PostgreSQL using pgx", codeflag.ConfigKey("template_set"))
+	dryRunF := codeFlags.String("dry-run", "off", "Do not apply changes, only output diff of what would change. Value specifies format, 'term' for terminal pretty text, 'html' for HTML, or 'off' to disable.", codeflag.Transient())
+	noGofmtF := codeFlags.Bool("no-gofmt", false, "Do not gofmt the output")
+	replaceF := codeFlags.Bool("replace", false, "Replace existing declarations with the newly generated ones instead of leaving them as-is", codeflag.Transient())
+	jsonF := codeFlags.Bool("json", false, "Write output as JSON", codeflag.Transient())
+	vF := codeFlags.Bool("v", false, "Verbose output", codeflag.Transient())
+	installTemplatesF := codeFlags.Bool("install-templates", false, "Copy the built-in templates into .gocode/templates/sqlcrud for editing, instead of generating code", codeflag.Transient())
+	forceF := codeFlags.Bool("force", false, "With -install-templates, overwrite templates that have been customized", codeflag.Transient())
+	upgradeTemplatesF := codeFlags.Bool("upgrade-templates", false, "Merge changes to the built-in templates into the ones in .gocode/templates/sqlcrud, instead of generating code", codeflag.Transient())
+	planF := codeFlags.String("plan", "", "Write the changes to this file as a plan to review and apply later with `gocode apply`, instead of applying them", codeflag.Transient())
+	uninstallF := codeFlags.Bool("uninstall", false, "Remove the code generated for the type instead of generating it, code shared with other types is kept while they use it", codeflag.Transient())
+	// allF := codeFlags.Bool("all", false, "Generate all methods")
+	codeFlags.Positional("file.go", "File to generate store code into, the type, file and package are inferred from it", false)
+	codeFlags.Example("gocode sqlcrud -package store -type Widget", "Generate store methods for Widget in store/widget-store.go")
+	codeFlags.Example("gocode sqlcrud store/widget.go", "Same but for store/widget.go, finding the type from the file name")
+	codeFlags.Example("gocode sqlcrud -dry-run=term store/widget.go", "Show what would change without writing anything")
+	codeFlags.Example("gocode sqlcrud -template-set=pgx store/widget.go", "Same but generate code for PostgreSQL using pgx")
+	codeFlags.Example("gocode sqlcrud -install-templates", "Copy the built-in templates into .gocode/templates/sqlcrud to customize them")
+	codeFlags.Example("gocode sqlcrud -uninstall store/widget.go", "Remove the store methods generated for Widget")
+	codeFlags.Example("gocode sqlcrud -plan widget-plan.json store/widget.go", "Save the changes to widget-plan.json to apply later with `gocode apply widget-plan.json`")
+
+	err := codeFlags.Parse(args, os.Stdout)
+	if err != nil {
+		return codeflag.ExitCode(err)
+	}
+
+	if *installTemplatesF {
+		res, err := codetmpl.RunInstall(defaultTmplFS, "sqlcrud", *forceF, *dryRunF, *jsonF, os.Stdout)
+		if err != nil {
+			log.Fatalf("error installing templates: %v", err)
+		}
+		if *dryRunF == "off" {
+			log.Printf("installed templates: %d written, %d unchanged, %d customized overwritten", len(res.Written), len(res.Unchanged), len(res.Customized))
+		}
+		return 0
+	}
+
+	if *upgradeTemplatesF {
+		res, err := codetmpl.RunUpgrade(defaultTmplFS, "sqlcrud", *dryRunF, *jsonF, os.Stdout)
+		if err != nil {
+			log.Fatalf("error upgrading templates: %v", err)
+		}
+		if *dryRunF == "off" {
+			log.Printf("upgraded templates: %d updated, %d merged, %d with conflicts, %d unchanged, %d skipped",
+				len(res.Updated), len(res.Merged), len(res.Conflicts), len(res.Unchanged), len(res.Skipped))
+		}
+		for _, p := range res.Conflicts {
+			log.Printf("conflicts marked with <<<<<<< in %s need to be resolved", p)
+		}
+		for _, p := range res.Skipped {
+			log.Printf("skipped %s, it is customized but the built-in version it came from is not known, or it is no longer built-in", p)
+		}
+		return 0
+	}
 
 	fileArgList := flagSet.Args()
 	if len(fileArgList) > 1 {
@@ -118,21 +178,36 @@ func maine(flagSet *flag.FlagSet, args []string) int {
 		log.Printf("rootFS=%v; modDir=%q, packagePath=%q, modPath=%q", rootFS, modDir, packagePath, modPath)
 	}
 
-	migrationsPackagePath := *migrationsPackageF
-	if migrationsPackagePath == "" {
-		migrationsPackagePath = strings.TrimPrefix(path.Join(packagePath, "../migrations"), "/")
-	}
-
 	// set up file systems
 	inFS, err := fs.Sub(rootFS, modDir)
 	if err != nil {
 		log.Fatalf("fs.Sub error while construct input fs: %v", err)
 	}
 
-	// output is either same as input or memory for dry-run
+	// settings from config fill in flags not given on the command line
+	cfg, err := config.LoadFS(inFS, true)
+	if err != nil {
+		log.Fatalf("config.LoadFS failed: %v", err)
+	}
+	err = codeFlags.ApplyConfig(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tmplNames, ok := templateSets[*templateSetF]
+	if !ok {
+		log.Fatalf("unknown -template-set %q", *templateSetF)
+	}
+
+	migrationsPackagePath := *migrationsPackageF
+	if migrationsPackagePath == "" {
+		migrationsPackagePath = strings.TrimPrefix(path.Join(packagePath, "../migrations"), "/")
+	}
+
+	// output is either same as input or memory for dry-run, and a plan isn't applied
 	var outFS fs.FS
 	var dryRunFS *memfs.FS
-	if *dryRunF == "off" {
+	if *dryRunF == "off" && *planF == "" {
 		outFS = inFS
 		if migrationsPackagePath != "" {
 			mda, ok := outFS.(srcedit.MkdirAller)
@@ -198,183 +273,294 @@ func maine(flagSet *flag.FlagSet, args []string) int {
 		Struct:               s,
 		MigrationsImportPath: modPath + "/" + migrationsPackagePath,
 	}
-	tmpl, err := template.New("_main_").Funcs(funcMap).ParseFS(defaultTmplFS, "sqlcrud.tmpl")
+	tmpl, projectTmplList, err := codetmpl.Parse(template.New("_main_").Funcs(funcMap), defaultTmplFS, tmplNames, inFS, "sqlcrud")
 	if err != nil {
 		log.Fatalf("template parse error: %v", err)
 	}
-
-	fmtt := &srcedit.GofmtTransform{}
-	var trs []srcedit.Transform
-
-	{
-		fn := "sqlutil.go"
-		fmtt.FilenameList = append(fmtt.FilenameList, fn)
-		trList, err := tmplToTransforms(fn, data, tmpl, "SQLUtil")
-		if err != nil {
-			log.Fatal(err)
-		}
-		trs = append(trs, trList...)
+	if *vF && len(projectTmplList) > 0 {
+		log.Printf("using project templates: %v", projectTmplList)
 	}
 
-	{
-		fn := *storeFileF
-		fmtt.FilenameList = append(fmtt.FilenameList, fn)
-		trList, err := tmplToTransforms(fn, data, tmpl, "Store", "StoreMethods")
-		if err != nil {
-			log.Fatal(err)
+	if *uninstallF {
+		if *planF != "" {
+			log.Fatalf("-plan cannot be used with -uninstall")
 		}
-		trs = append(trs, trList...)
-	}
-
-	{
-		fn := *storeTestFileF
-		fmtt.FilenameList = append(fmtt.FilenameList, fn)
-		trList, err := tmplToTransforms(fn, data, tmpl, "TestStore")
+		err := uninstall(pkg, data, tmpl, packageFiles(typeFilename, *testFileF, *storeFileF, *storeTestFileF), !*noGofmtF)
 		if err != nil {
-			log.Fatal(err)
+			log.Fatalf("uninstall error: %v", err)
 		}
-		trs = append(trs, trList...)
-	}
-
-	{
-		fn := typeFilename
-		fmtt.FilenameList = append(fmtt.FilenameList, fn)
-		trList, err := tmplToTransforms(fn, data, tmpl,
-			"TYPEStore",
-			"TYPEStoreMethods",
-			// FIXME: filter which things go here base on flags
-			"TYPEInsert",
-			"TYPEDelete",
-			"TYPEUpdate",
-			"TYPESelectByID",
-			"TYPESelect",
-			"TYPESelectCursor",
-			"TYPECount",
-		)
-		if err != nil {
-			log.Fatal(err)
+		for _, c := range pkg.Conflicts() {
+			log.Print(c)
 		}
-		trs = append(trs, trList...)
+		if *dryRunF == "off" {
+			err := config.RemoveRunFS(inFS, "sqlcrud", packagePath, typeName)
+			if err != nil {
+				log.Fatalf("error removing run from %s: %v", config.ManifestPath, err)
+			}
+		} else {
+			printDiff(inFS, outFS, *dryRunF, *jsonF)
+		}
+		return 0
 	}
 
-	{
-		// TODO: -no-test flag
-		fn := *testFileF
-		fmtt.FilenameList = append(fmtt.FilenameList, fn)
-		trList, err := tmplToTransforms(fn, data, tmpl,
-			"TestTYPE",
-		)
+	fmtt := &srcedit.GofmtTransform{}
+	var trs []srcedit.Transform
+
+	// TODO: -no-test flag
+	for _, f := range packageFiles(typeFilename, *testFileF, *storeFileF, *storeTestFileF) {
+		fmtt.FilenameList = append(fmtt.FilenameList, f.name)
+		trList, err := tmplToTransforms(f.name, data, tmpl, f.blocks...)
 		if err != nil {
 			log.Fatal(err)
 		}
 		trs = append(trs, trList...)
 	}
 
-	dd := &srcedit.DedupImportsTransform{
+	fi := &srcedit.FixImportsTransform{
 		FilenameList: fmtt.FilenameList,
 	}
-	trs = append(trs, dd)
+	trs = append(trs, fi)
 
 	if !*noGofmtF {
 		trs = append(trs, fmtt)
 	}
 
-	err = pkg.ApplyTransforms(trs...)
-	if err != nil {
-		log.Fatalf("apply transform error: %v", err)
+	// generated structs that already exist keep any fields added to them
+	srcedit.SetMerge(trs)
+
+	if *replaceF {
+		srcedit.SetReplace(trs)
 	}
 
 	// TODO: option to skip migrations stuff?
-	// do migrations package separately
+	// the migrations package has its own transforms
+	var migrationsTrs []srcedit.Transform
 	{
 		fmtt := &srcedit.GofmtTransform{}
-		var trs []srcedit.Transform
 
-		fn := "migrations.go"
+		fn := migrationsFile.name
 		fmtt.FilenameList = append(fmtt.FilenameList, fn)
-		trList, err := tmplToTransforms(fn, data, tmpl, "Migrations")
+		trList, err := tmplToTransforms(fn, data, tmpl, migrationsFile.blocks...)
 		if err != nil {
 			log.Fatal(err)
 		}
-		trs = append(trs, trList...)
+		migrationsTrs = append(migrationsTrs, trList...)
 
-		dd := &srcedit.DedupImportsTransform{
+		fi := &srcedit.FixImportsTransform{
 			FilenameList: fmtt.FilenameList,
 		}
-		trs = append(trs, dd)
+		migrationsTrs = append(migrationsTrs, fi)
 
 		if !*noGofmtF {
-			trs = append(trs, fmtt)
+			migrationsTrs = append(migrationsTrs, fmtt)
 		}
 
-		err = migrationsPkg.ApplyTransforms(trs...)
+		sample, err := sampleMigration(inFS, migrationsPackagePath)
 		if err != nil {
-			log.Fatalf("apply transform for migrations error: %v", err)
+			log.Fatal(err)
+		}
+		if sample != nil {
+			migrationsTrs = append(migrationsTrs, sample)
 		}
 
-		// mpf, err := inFS.Open(migrationsPackagePath)
+		if *replaceF {
+			srcedit.SetReplace(migrationsTrs)
+		}
+	}
 
-		needSampleMigration := true
-		err = fs.WalkDir(inFS, migrationsPackagePath, fs.WalkDirFunc(func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-			if path == migrationsPackagePath {
-				return nil
-			}
-			if d.IsDir() { // only scan the immediate directory
-				return fs.SkipDir
-			}
-			if strings.HasSuffix(path, ".sql") || strings.HasSuffix(path, ".SQL") {
-				needSampleMigration = false
-			}
+	// files written, for the manifest
+	var fileList []string
+	for _, fn := range fmtt.FilenameList {
+		fileList = append(fileList, path.Join(packagePath, fn))
+	}
+	fileList = append(fileList, path.Join(migrationsPackagePath, migrationsFile.name))
+
+	flags := codeFlags.Values()
+	flags["package"] = packagePath
+	flags["migrations-package"] = migrationsPackagePath
+	entry := config.ManifestEntry{
+		Tool:    "sqlcrud",
+		Type:    typeName,
+		Package: packagePath,
+		Files:   fileList,
+		Flags:   flags,
+	}
+
+	if *planF != "" {
+		pl := codeplan.New("sqlcrud", modPath)
+		err := pl.AddPackage(pkg, trs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = pl.AddPackage(migrationsPkg, migrationsTrs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		pl.Manifest = &entry
+		err = pl.WriteFile(*planF)
+		if err != nil {
+			log.Fatalf("error writing plan: %v", err)
+		}
+		log.Printf("wrote %s to %s", pl, *planF)
+		return 0
+	}
+
+	// both packages are written or neither is
+	err = srcedit.ApplyPackageTransforms(
+		srcedit.PackageTransforms{Package: pkg, Transforms: trs},
+		srcedit.PackageTransforms{Package: migrationsPkg, Transforms: migrationsTrs},
+	)
+	if err != nil {
+		log.Fatalf("apply transform error: %v", err)
+	}
+	for _, c := range pkg.Conflicts() {
+		log.Print(c)
+	}
+	for _, a := range pkg.ImportAliases() {
+		log.Print(a)
+	}
+	for _, c := range migrationsPkg.Conflicts() {
+		log.Print(c)
+	}
+	for _, a := range migrationsPkg.ImportAliases() {
+		log.Print(a)
+	}
+
+	if *dryRunF == "off" {
+		err := config.RecordRunFS(inFS, entry)
+		if err != nil {
+			log.Fatalf("error recording run in %s: %v", config.ManifestPath, err)
+		}
+	}
+
+	if *dryRunF != "off" {
+		printDiff(inFS, outFS, *dryRunF, *jsonF)
+	}
+
+	return 0
+}
+
+// sampleMigration returns a transform which creates an empty migration in migrationsDir
+// if it has no migrations yet, or nil if it has.
+func sampleMigration(inFS fs.FS, migrationsDir string) (*srcedit.AddFileTransform, error) {
+
+	needSampleMigration := true
+	err := fs.WalkDir(inFS, migrationsDir, fs.WalkDirFunc(func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == migrationsDir {
 			return nil
-		}))
-		if err != nil && !os.IsNotExist(err) {
-			log.Fatalf("error walking migrations dir %q: %v", migrationsPackagePath, err)
 		}
+		if d.IsDir() { // only scan the immediate directory
+			return fs.SkipDir
+		}
+		if strings.HasSuffix(path, ".sql") || strings.HasSuffix(path, ".SQL") {
+			needSampleMigration = false
+		}
+		return nil
+	}))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error walking migrations dir %q: %w", migrationsDir, err)
+	}
+	if !needSampleMigration {
+		return nil, nil
+	}
 
-		if needSampleMigration {
-			b := []byte(`
+	text := `
 -- +goose Up
 
 -- +goose Down
 
-`)
-			fname := time.Now().UTC().Format("20060102150405") + "_sample.sql"
-			fpath := path.Join(migrationsPackagePath, fname)
-			err := outFS.(srcedit.FileWriter).WriteFile(fpath, b, 0644)
-			if err != nil {
-				log.Fatalf("error creating example migration file %q: %v", fpath, err)
-			}
+`
+	fname := time.Now().UTC().Format("20060102150405") + "_sample.sql"
+	return &srcedit.AddFileTransform{Filename: fname, Text: text}, nil
+}
+
+// printDiff writes the changes from inFS to outFS to stdout in the -dry-run format.
+func printDiff(inFS, outFS fs.FS, format string, jsonOut bool) {
+	diffMap, err := diff.Run(inFS, outFS, ".", format)
+	if err != nil {
+		log.Fatalf("error running diff: %v", err)
+	}
+	if jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.Encode(map[string]interface{}{
+			"diff": diffMap,
+		})
+	} else {
+		klist := make([]string, 0, len(diffMap))
+		for k := range diffMap {
+			klist = append(klist, k)
 		}
+		sort.Strings(klist)
+		for _, k := range klist {
+			fmt.Printf("### %s\n", k)
+			fmt.Println(diffMap[k])
+		}
+	}
+}
+
+// tmplFile is a file to generate and the template blocks written into it.
+type tmplFile struct {
+	name   string
+	blocks []string
+	shared bool // used by all types in the package, not just the one generated for
+}
 
+// packageFiles returns the files generated into the package with the type.
+func packageFiles(typeFile, testFile, storeFile, storeTestFile string) []tmplFile {
+	return []tmplFile{
+		{"sqlutil.go", []string{"SQLUtil", "SQLDialect"}, true},
+		{storeFile, []string{"Store", "StoreMethods", "StoreErrors"}, true},
+		{storeTestFile, []string{"TestStore"}, true},
+		{typeFile, []string{
+			"TYPEStore",
+			"TYPEStoreMethods",
+			// FIXME: filter which things go here base on flags
+			"TYPEInsert",
+			"TYPEDelete",
+			"TYPEUpdate",
+			"TYPESelectByID",
+			"TYPESelect",
+			"TYPESelectCursor",
+			"TYPECount",
+		}, false},
+		{testFile, []string{"TestTYPE"}, false},
 	}
+}
 
-	if *dryRunF != "off" {
-		diffMap, err := diff.Run(inFS, outFS, ".", *dryRunF)
+// migrationsFile is generated into the migrations package.
+var migrationsFile = tmplFile{"migrations.go", []string{"Migrations"}, true}
+
+// uninstall removes the declarations generated into files from pkg.  The shared ones are only
+// removed if nothing else in the package refers to them, i.e. this was the last type using them.
+// Imports that are no longer used are removed afterwards.
+func uninstall(pkg *srcedit.Package, data interface{}, tmpl *template.Template, files []tmplFile, gofmt bool) error {
+
+	fmtt := &srcedit.GofmtTransform{}
+	var typeTrs, sharedTrs []srcedit.Transform
+	for _, f := range files {
+		fmtt.FilenameList = append(fmtt.FilenameList, f.name)
+		trList, err := tmplToTransforms(f.name, data, tmpl, f.blocks...)
 		if err != nil {
-			log.Fatalf("error running diff: %v", err)
+			return err
 		}
-		if *jsonF {
-			enc := json.NewEncoder(os.Stdout)
-			enc.Encode(map[string]interface{}{
-				"diff": diffMap,
-			})
+		if f.shared {
+			sharedTrs = append(sharedTrs, srcedit.RemoveTransforms(trList)...)
 		} else {
-			klist := make([]string, 0, len(diffMap))
-			for k := range diffMap {
-				klist = append(klist, k)
-			}
-			sort.Strings(klist)
-			for _, k := range klist {
-				fmt.Printf("### %s\n", k)
-				fmt.Println(diffMap[k])
-			}
+			typeTrs = append(typeTrs, srcedit.RemoveTransforms(trList)...)
 		}
 	}
 
-	return 0
+	// shared code goes too once nothing is left that uses it, all of it applied together so a
+	// failure leaves the package as it was
+	trs := append(typeTrs, &srcedit.RemoveUnreferencedTransform{TransformList: sharedTrs})
+	trs = append(trs, &srcedit.RemoveUnusedImportsTransform{FilenameList: fmtt.FilenameList})
+	if gofmt {
+		trs = append(trs, fmtt)
+	}
+	return srcedit.ApplyPackageTransforms(srcedit.PackageTransforms{Package: pkg, Transforms: trs})
 }
 
 func tmplToTransforms(fileName string, data interface{}, tmpl *template.Template, tmplName ...string) ([]srcedit.Transform, error) {
@@ -392,6 +578,7 @@ func tmplToTransforms(fileName string, data interface{}, tmpl *template.Template
 		if err != nil {
 			return ret, fmt.Errorf("%q transform parse error: %v", tName, err)
 		}
+		srcedit.SetOwner(trList, "sqlcrud/"+tName)
 		ret = append(ret, trList...)
 
 	}
@@ -6,9 +6,25 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+	"text/template"
+
+	"github.com/d0sbit/gocode/codecheck"
+	"github.com/d0sbit/gocode/codegolden"
+	"github.com/d0sbit/gocode/codeplan"
+	"github.com/d0sbit/gocode/codetmpl"
+	"github.com/d0sbit/gocode/config"
+	"github.com/d0sbit/gocode/srcedit"
+	"github.com/d0sbit/gocode/srcedit/model"
 )
 
+var updateF = flag.Bool("update", false, "Rewrite the golden files in testdata/golden with the current output")
+
+// pkgDir is the directory of this package, other tests change the working directory
+var pkgDir, _ = os.Getwd()
+
 func TestMaineExec(t *testing.T) {
 
 	// tests with execution of `go test` - requires "docker run ..." etc to work
@@ -64,6 +80,245 @@ func (a *A) IDAssign() { a.ID = IDString() }
 
 }
 
+func TestTemplateSets(t *testing.T) {
+
+	// every set must produce code that type-checks for each kind of struct, without needing a database
+	checker := codecheck.New()
+
+	setNames := make([]string, 0, len(templateSets))
+	for setName := range templateSets {
+		setNames = append(setNames, setName)
+	}
+	sort.Strings(setNames)
+
+	for _, setName := range setNames {
+		tmpl, _, err := codetmpl.Parse(template.New("_main_").Funcs(funcMap), defaultTmplFS, templateSets[setName], nil, "sqlcrud")
+		must(t, err)
+		tmpl, err = codecheck.Mark(tmpl)
+		must(t, err)
+
+		for _, fixture := range codecheck.SQLFixtures {
+			fixture := fixture
+			t.Run(setName+"_"+fixture.Name, func(t *testing.T) {
+
+				moduleFS, err := fixture.Module()
+				must(t, err)
+				must(t, moduleFS.MkdirAll("migrations", 0755))
+
+				pkg := srcedit.NewPackage(moduleFS, moduleFS, codecheck.FixtureModule, codecheck.FixtureDir)
+				typeInfo, err := pkg.FindType(codecheck.FixtureType)
+				must(t, err)
+				s, err := model.NewStruct(typeInfo, "")
+				must(t, err)
+				data := struct {
+					Struct               *model.Struct
+					MigrationsImportPath string
+				}{
+					Struct:               s,
+					MigrationsImportPath: codecheck.FixtureModule + "/migrations",
+				}
+
+				must(t, applyFiles(pkg, data, tmpl, packageFiles("a-store.go", "a-store_test.go", "store.go", "store_test.go")...))
+				migrationsPkg := srcedit.NewPackage(moduleFS, moduleFS, codecheck.FixtureModule, "migrations")
+				must(t, applyFiles(migrationsPkg, data, tmpl, migrationsFile))
+
+				errs, err := checker.Check(moduleFS, codecheck.FixtureModule, codecheck.FixtureDir, "migrations")
+				must(t, err)
+				for _, e := range errs {
+					t.Error(e)
+				}
+			})
+		}
+	}
+}
+
+// applyFiles generates files into pkg the same way maine does.
+func applyFiles(pkg *srcedit.Package, data interface{}, tmpl *template.Template, files ...tmplFile) error {
+	var trs []srcedit.Transform
+	fmtt := &srcedit.GofmtTransform{}
+	for _, f := range files {
+		trList, err := tmplToTransforms(f.name, data, tmpl, f.blocks...)
+		if err != nil {
+			return err
+		}
+		trs = append(trs, trList...)
+		fmtt.FilenameList = append(fmtt.FilenameList, f.name)
+	}
+	trs = append(trs, &srcedit.FixImportsTransform{FilenameList: fmtt.FilenameList}, fmtt)
+	return pkg.ApplyTransforms(trs...)
+}
+
+func TestGolden(t *testing.T) {
+
+	// compare everything maine writes with testdata/golden, run with -update after changing the templates
+
+	type tcase struct {
+		set     string
+		fixture string
+	}
+	var tcList []tcase
+	setNames := make([]string, 0, len(templateSets))
+	for setName := range templateSets {
+		setNames = append(setNames, setName)
+	}
+	sort.Strings(setNames)
+	for _, setName := range setNames {
+		tcList = append(tcList, tcase{set: setName, fixture: "string_pk"})
+	}
+	for _, f := range codecheck.SQLFixtures[1:] {
+		tcList = append(tcList, tcase{set: "sqlx", fixture: f.Name})
+	}
+
+	defer os.Chdir(pkgDir)
+
+	for _, tc := range tcList {
+		tc := tc
+		t.Run(tc.set+"_"+tc.fixture, func(t *testing.T) {
+
+			var fixture codecheck.Fixture
+			for _, f := range codecheck.SQLFixtures {
+				if f.Name == tc.fixture {
+					fixture = f
+				}
+			}
+			moduleFS, err := fixture.Module()
+			must(t, err)
+			// an existing migration means no sample one is written, its name would change each run
+			must(t, moduleFS.MkdirAll("migrations", 0755))
+			must(t, moduleFS.WriteFile("migrations/20210101000000_a.sql", []byte("-- +goose Up\n\n-- +goose Down\n"), 0644))
+
+			modDir := t.TempDir()
+			must(t, codegolden.CopyToDir(moduleFS, modDir))
+			must(t, os.Chdir(modDir))
+			defer os.Chdir(pkgDir)
+
+			flset := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+			ret := maine(flset, []string{"-package=a", "-type=A", "-template-set=" + tc.set})
+			if ret != 0 {
+				t.Fatalf("ret = %d", ret)
+			}
+
+			must(t, codegolden.RemoveUnchanged(moduleFS, modDir))
+			diffs, err := codegolden.Compare(os.DirFS(modDir), ".", filepath.Join(pkgDir, "testdata/golden", tc.set+"_"+tc.fixture), *updateF)
+			must(t, err)
+			for _, fn := range sortedKeys(diffs) {
+				t.Errorf("%s does not match golden file (run with -update to accept):\n%s", fn, diffs[fn])
+			}
+		})
+	}
+}
+
+func TestUninstall(t *testing.T) {
+
+	// generate for A and B, then take them out again one at a time
+	moduleFS, err := codecheck.SQLFixtures[0].Module()
+	must(t, err)
+	must(t, moduleFS.WriteFile("a/b.go", []byte("package a\n\ntype B struct {\n\tID string `db:\"id\"`\n}\n"), 0644))
+	must(t, moduleFS.MkdirAll("migrations", 0755))
+	must(t, moduleFS.WriteFile("migrations/20210101000000_a.sql", []byte("-- +goose Up\n\n-- +goose Down\n"), 0644))
+
+	modDir := t.TempDir()
+	must(t, codegolden.CopyToDir(moduleFS, modDir))
+	must(t, os.Chdir(modDir))
+	defer os.Chdir(pkgDir)
+
+	run := func(args ...string) {
+		t.Helper()
+		ret := maine(flag.NewFlagSet(os.Args[0], flag.ContinueOnError), args)
+		if ret != 0 {
+			t.Fatalf("maine(%q) ret = %d", args, ret)
+		}
+	}
+	read := func(fn string) string {
+		t.Helper()
+		b, err := ioutil.ReadFile(filepath.FromSlash(fn))
+		must(t, err)
+		return string(b)
+	}
+	check := func() {
+		t.Helper()
+		errs, err := codecheck.New().Check(os.DirFS(modDir), codecheck.FixtureModule, "a", "migrations")
+		must(t, err)
+		for _, e := range errs {
+			t.Error(e)
+		}
+	}
+
+	run("-package=a", "-type=A")
+	run("-package=a", "-type=B")
+
+	run("-package=a", "-type=A", "-uninstall")
+	if s := read("a/a-store.go"); strings.Contains(s, "AStore") {
+		t.Errorf("a-store.go still has AStore:\n%s", s)
+	}
+	if s := read("a/store.go"); !strings.Contains(s, "type Store struct") {
+		t.Errorf("store.go lost Store while B still uses it:\n%s", s)
+	}
+	if s := read(".gocode/manifest.toml"); strings.Contains(s, `type = "A"`) {
+		t.Errorf("manifest still has A:\n%s", s)
+	}
+	check()
+
+	run("-package=a", "-type=B", "-uninstall")
+	for _, fn := range []string{"a/store.go", "a/store_test.go", "a/sqlutil.go", "a/b-store.go"} {
+		if s := strings.TrimSpace(read(fn)); s != "package a" {
+			t.Errorf("%s should only have a package clause left:\n%s", fn, s)
+		}
+	}
+	check()
+}
+
+func TestPlan(t *testing.T) {
+
+	moduleFS, err := codecheck.SQLFixtures[0].Module()
+	must(t, err)
+
+	modDir := t.TempDir()
+	must(t, codegolden.CopyToDir(moduleFS, modDir))
+	must(t, os.Chdir(modDir))
+	defer os.Chdir(pkgDir)
+
+	ret := maine(flag.NewFlagSet(os.Args[0], flag.ContinueOnError), []string{"-package=a", "-type=A", "-plan=plan.json"})
+	if ret != 0 {
+		t.Fatalf("maine ret = %d", ret)
+	}
+	for _, fn := range []string{"a/a-store.go", "migrations", ".gocode/manifest.toml"} {
+		if _, err := os.Stat(filepath.FromSlash(fn)); err == nil {
+			t.Errorf("%s was written by -plan", fn)
+		}
+	}
+
+	pl, err := codeplan.ReadFile("plan.json")
+	must(t, err)
+	_, err = pl.Apply(srcedit.DirFS(modDir), codecheck.FixtureModule)
+	must(t, err)
+
+	errs, err := codecheck.New().Check(os.DirFS(modDir), codecheck.FixtureModule, "a", "migrations")
+	must(t, err)
+	for _, e := range errs {
+		t.Error(e)
+	}
+	m, err := config.LoadManifestFS(os.DirFS(modDir))
+	must(t, err)
+	if len(m.Find("sqlcrud", "A")) != 1 {
+		t.Errorf("manifest entry not recorded: %+v", m)
+	}
+	sqlFiles, err := filepath.Glob(filepath.Join(modDir, "migrations", "*_sample.sql"))
+	must(t, err)
+	if len(sqlFiles) != 1 {
+		t.Errorf("expected a sample migration, found %v", sqlFiles)
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
 // func TestMaineDryRun(t *testing.T) {
 
 // 	var modDir string
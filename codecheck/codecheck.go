@@ -0,0 +1,328 @@
+// Package codecheck type-checks the code written by the generators, without running the go
+// command or needing network access, so that a broken template shows up as a failing test
+// instead of in the next project that uses it.
+//
+// Standard library packages are checked from the source in GOROOT.  Third party packages the
+// templates import are replaced by stubs (in testdata/stubs) which declare just the parts of
+// their API that are used, so these need to be extended when a template starts using more.
+// Templates run through Mark first have each line of output tagged with where it came from,
+// and Check uses that to report the template define and line for each error.
+package codecheck
+
+import (
+	"embed"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+//go:embed testdata/stubs
+var stubFS embed.FS
+
+// Error is a problem found in the generated code.
+type Error struct {
+	Pos      token.Position // position in the generated file, relative to the module root
+	Msg      string
+	Define   string // name of the template define that produced the line, if known
+	Template string // template file and line, e.g. "sqlcrud.tmpl:123", if known
+}
+
+// Error implements the error interface.
+func (e Error) Error() string {
+	if e.Define == "" {
+		return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s (from define %q at %s)", e.Pos, e.Msg, e.Define, e.Template)
+}
+
+// Checker type-checks packages.  The standard library and stub packages are checked once
+// and reused by each call to Check.  A Checker is not safe for concurrent use.
+type Checker struct {
+	fset  *token.FileSet
+	ctxt  build.Context
+	sizes types.Sizes
+	stubs fs.FS
+	deps  map[string]*types.Package
+}
+
+// New returns a Checker using the built-in stubs for third party packages.
+func New() *Checker {
+	stubs, err := fs.Sub(stubFS, "testdata/stubs")
+	if err != nil {
+		panic(err) // only possible with a bad literal path
+	}
+	ctxt := build.Default
+	ctxt.CgoEnabled = false // files importing "C" cannot be checked from source
+	return &Checker{
+		fset:  token.NewFileSet(),
+		ctxt:  ctxt,
+		sizes: types.SizesFor("gc", runtime.GOARCH),
+		stubs: stubs,
+		deps:  make(map[string]*types.Package),
+	}
+}
+
+// Check type-checks the packages in dirs, which are relative to the root of fsys, the module
+// modulePath.  Test files are included.  Packages within the module that they import are
+// read from fsys and checked too.  The returned errors are the problems found in the code
+// (including imports with no stub), and the error is for anything which stopped the check
+// from being done, like a directory that can't be read.
+func (c *Checker) Check(fsys fs.FS, modulePath string, dirs ...string) ([]Error, error) {
+
+	r := &checkRun{
+		Checker:    c,
+		fsys:       fsys,
+		modulePath: modulePath,
+		pkgs:       make(map[string]*types.Package),
+		seen:       make(map[string]bool),
+	}
+
+	for _, dir := range dirs {
+		err := r.checkDir(dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(r.errs, func(i, j int) bool {
+		a, b := r.errs[i].Pos, r.errs[j].Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+	for i := range r.errs {
+		r.locate(&r.errs[i])
+	}
+
+	return r.errs, nil
+}
+
+// importDep returns a standard library or stub package, checking it the first time.
+func (c *Checker) importDep(importPath string) (*types.Package, error) {
+
+	if importPath == "unsafe" {
+		return types.Unsafe, nil
+	}
+	if pkg := c.deps[importPath]; pkg != nil {
+		return pkg, nil
+	}
+
+	files, err := c.depFiles(importPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the declarations matter for the code that imports it
+	conf := types.Config{
+		Importer:         importerFunc(c.importDep),
+		IgnoreFuncBodies: true,
+		Sizes:            c.sizes,
+	}
+	pkg, err := conf.Check(importPath, c.fset, files, nil)
+	if err != nil {
+		return nil, fmt.Errorf("checking %s: %w", importPath, err)
+	}
+	c.deps[importPath] = pkg
+
+	return pkg, nil
+}
+
+// depFiles parses the files for a stub or standard library package.
+func (c *Checker) depFiles(importPath string) ([]*ast.File, error) {
+
+	// stubs are always used for anything not in the standard library
+	if strings.Contains(strings.Split(importPath, "/")[0], ".") {
+		if entries, err := fs.ReadDir(c.stubs, importPath); err == nil {
+			var ret []*ast.File
+			for _, entry := range entries {
+				if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
+					continue
+				}
+				b, err := fs.ReadFile(c.stubs, path.Join(importPath, entry.Name()))
+				if err != nil {
+					return nil, err
+				}
+				f, err := parser.ParseFile(c.fset, path.Join("stubs", importPath, entry.Name()), b, 0)
+				if err != nil {
+					return nil, err
+				}
+				ret = append(ret, f)
+			}
+			return ret, nil
+		}
+	}
+
+	dir := filepath.Join(c.ctxt.GOROOT, "src", filepath.FromSlash(importPath))
+	if _, err := os.Stat(dir); err != nil {
+		// packages vendored into the standard library
+		dir = filepath.Join(c.ctxt.GOROOT, "src", "vendor", filepath.FromSlash(importPath))
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("no stub or standard library package for %q (add one to codecheck/testdata/stubs)", importPath)
+		}
+	}
+
+	bp, err := c.ctxt.ImportDir(dir, 0)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", importPath, err)
+	}
+	var ret []*ast.File
+	for _, fn := range bp.GoFiles {
+		f, err := parser.ParseFile(c.fset, filepath.Join(dir, fn), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, f)
+	}
+	return ret, nil
+}
+
+// checkRun is one call to Check.
+type checkRun struct {
+	*Checker
+	fsys       fs.FS
+	modulePath string
+	pkgs       map[string]*types.Package // module packages without tests, by import path
+	errs       []Error
+	seen       map[string]bool // errors already in errs
+}
+
+// Import implements types.Importer.
+func (r *checkRun) Import(importPath string) (*types.Package, error) {
+
+	dir, ok := r.moduleDir(importPath)
+	if !ok {
+		return r.importDep(importPath)
+	}
+
+	if pkg := r.pkgs[importPath]; pkg != nil {
+		return pkg, nil
+	}
+	files, _, err := r.parseDir(dir, false)
+	if err != nil {
+		return nil, err
+	}
+	pkg := r.check(importPath, files)
+	r.pkgs[importPath] = pkg
+
+	return pkg, nil
+}
+
+// moduleDir returns the directory in fsys for importPath, if it is in the module.
+func (r *checkRun) moduleDir(importPath string) (string, bool) {
+	if importPath == r.modulePath {
+		return ".", true
+	}
+	if strings.HasPrefix(importPath, r.modulePath+"/") {
+		return strings.TrimPrefix(importPath, r.modulePath+"/"), true
+	}
+	return "", false
+}
+
+// checkDir checks the package in dir along with its tests.
+func (r *checkRun) checkDir(dir string) error {
+
+	importPath := r.modulePath
+	if dir != "." && dir != "" {
+		importPath = path.Join(r.modulePath, dir)
+	}
+
+	files, xtestFiles, err := r.parseDir(dir, true)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 && len(xtestFiles) == 0 {
+		return fmt.Errorf("no Go files in %q", dir)
+	}
+
+	r.check(importPath, files)
+	if len(xtestFiles) > 0 {
+		r.check(importPath+"_test", xtestFiles)
+	}
+
+	return nil
+}
+
+// check type-checks files as the package importPath, recording any errors.
+func (r *checkRun) check(importPath string, files []*ast.File) *types.Package {
+	conf := types.Config{
+		Importer: r,
+		Sizes:    r.sizes,
+		Error: func(err error) {
+			if terr, ok := err.(types.Error); ok {
+				r.addErr(Error{Pos: terr.Fset.Position(terr.Pos), Msg: terr.Msg})
+				return
+			}
+			r.addErr(Error{Msg: err.Error()})
+		},
+	}
+	pkg, _ := conf.Check(importPath, r.fset, files, nil) // errors are reported above
+	return pkg
+}
+
+func (r *checkRun) addErr(e Error) {
+	k := e.Pos.String() + ": " + e.Msg
+	if r.seen[k] {
+		return
+	}
+	r.seen[k] = true
+	r.errs = append(r.errs, e)
+}
+
+// parseDir parses the Go files in dir, returning those for the package (including in-package
+// tests if tests is true) and those for the external test package.  Syntax errors are
+// recorded and the file is left out.  Build constraints are ignored.
+func (r *checkRun) parseDir(dir string, tests bool) (files, xtestFiles []*ast.File, err error) {
+
+	entries, err := fs.ReadDir(r.fsys, dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		if !tests && strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		p := path.Join(dir, name)
+		b, err := fs.ReadFile(r.fsys, p)
+		if err != nil {
+			return nil, nil, err
+		}
+		f, err := parser.ParseFile(r.fset, p, b, 0)
+		if err != nil {
+			if elist, ok := err.(scanner.ErrorList); ok {
+				for _, e := range elist {
+					r.addErr(Error{Pos: e.Pos, Msg: e.Msg})
+				}
+				continue
+			}
+			return nil, nil, err
+		}
+		if strings.HasSuffix(name, "_test.go") && strings.HasSuffix(f.Name.Name, "_test") {
+			xtestFiles = append(xtestFiles, f)
+		} else {
+			files = append(files, f)
+		}
+	}
+
+	return files, xtestFiles, nil
+}
+
+type importerFunc func(importPath string) (*types.Package, error)
+
+func (f importerFunc) Import(importPath string) (*types.Package, error) { return f(importPath) }
@@ -0,0 +1,124 @@
+package codecheck
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/psanford/memfs"
+)
+
+func TestCheck(t *testing.T) {
+
+	type tcase struct {
+		name  string
+		files map[string]string
+		errs  []string // expected position and part of the message of each error, in order
+	}
+
+	tcList := []tcase{
+		{
+			name: "ok",
+			files: map[string]string{
+				"a/a.go":      "package a\n\nimport (\n\t\"database/sql\"\n\n\t\"github.com/jmoiron/sqlx\"\n\t\"test1/b\"\n)\n\nfunc F(db *sql.DB) *sqlx.DB { return sqlx.NewDb(db, b.Name) }\n",
+				"a/a_test.go": "package a\n\nimport \"testing\"\n\nfunc TestF(t *testing.T) { _ = F(nil) }\n",
+				"a/x_test.go": "package a_test\n\nimport \"test1/a\"\n\nvar _ = a.F\n",
+				"b/b.go":      "package b\n\nconst Name = \"mysql\"\n",
+			},
+		},
+		{
+			name: "type_error",
+			files: map[string]string{
+				"a/a.go": "package a\n\nfunc F() int { return \"x\" }\n",
+			},
+			errs: []string{`a/a.go:3:23: cannot use "x"`},
+		},
+		{
+			name: "syntax_error",
+			files: map[string]string{
+				"a/a.go":  "package a\n\nfunc F() {\n",
+				"a/a2.go": "package a\n\nvar X = y\n",
+			},
+			errs: []string{"a/a.go:3:12: expected '}'", "a/a2.go:3:9: undefined: y"},
+		},
+		{
+			name: "module_import_error",
+			files: map[string]string{
+				"a/a.go": "package a\n\nimport \"test1/b\"\n\nvar X int = b.Y\n",
+				"b/b.go": "package b\n\nvar Y string = 1\n",
+			},
+			errs: []string{
+				"a/a.go:5:13: cannot use b.Y",
+				"b/b.go:3:16: cannot use 1",
+			},
+		},
+	}
+
+	c := New()
+	for _, tc := range tcList {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := memfs.New()
+			for fn, src := range tc.files {
+				must(t, fsys.MkdirAll(fn[:strings.LastIndex(fn, "/")], 0755))
+				must(t, fsys.WriteFile(fn, []byte(src), 0644))
+			}
+			errs, err := c.Check(fsys, "test1", "a")
+			must(t, err)
+			ok := len(errs) == len(tc.errs)
+			for i := 0; ok && i < len(errs); i++ {
+				ok = strings.HasPrefix(errs[i].Error(), tc.errs[i])
+			}
+			if !ok {
+				t.Errorf("got errors: %q\nexpected: %q", errs, tc.errs)
+			}
+		})
+	}
+}
+
+func TestMark(t *testing.T) {
+
+	tmpl := template.Must(template.New("x.tmpl").Parse(`{{define "F"}}
+// F is here.
+func F() int {
+	{{if .}}
+	return {{.}}
+	{{end}}
+	return 0
+}
+{{end}}`))
+
+	marked, err := Mark(tmpl)
+	must(t, err)
+
+	// the original is left alone
+	var buf bytes.Buffer
+	must(t, tmpl.ExecuteTemplate(&buf, "F", `"x"`))
+	if strings.Contains(buf.String(), markerPrefix) {
+		t.Errorf("original template was modified:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	buf.WriteString("package a\n")
+	must(t, marked.ExecuteTemplate(&buf, "F", `"x"`))
+	fsys := memfs.New()
+	must(t, fsys.MkdirAll("a", 0755))
+	must(t, fsys.WriteFile("a/a.go", buf.Bytes(), 0644))
+
+	errs, err := New().Check(fsys, "test1", "a")
+	must(t, err)
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %v\n%s", errs, buf.String())
+	}
+	if errs[0].Define != "F" || errs[0].Template != "x.tmpl:5" {
+		t.Errorf("expected define F at x.tmpl:5, got %q at %q", errs[0].Define, errs[0].Template)
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,163 @@
+package codecheck
+
+import (
+	"github.com/psanford/memfs"
+)
+
+// Fixture is a struct type for the generators to be run against.  Each is declared as type A
+// in package directory "a" of module "test1".
+type Fixture struct {
+	Name string // short name, used for the subtest
+	Src  string // contents of a/types.go
+}
+
+const (
+	FixtureModule = "test1" // module path for Fixture.Module
+	FixtureDir    = "a"     // package directory with the fixture type
+	FixtureType   = "A"     // name of the fixture type
+)
+
+// Module returns a new in-memory module with the fixture type in it.
+func (f Fixture) Module() (*memfs.FS, error) {
+	fsys := memfs.New()
+	err := fsys.MkdirAll(FixtureDir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	err = fsys.WriteFile("go.mod", []byte("module "+FixtureModule+"\n"), 0644)
+	if err != nil {
+		return nil, err
+	}
+	err = fsys.WriteFile(FixtureDir+"/types.go", []byte(f.Src), 0644)
+	if err != nil {
+		return nil, err
+	}
+	return fsys, nil
+}
+
+// SQLFixtures covers the kinds of struct the SQL templates need to handle.
+var SQLFixtures = []Fixture{
+	{
+		Name: "string_pk",
+		Src: `package a
+
+type A struct {
+	ID   string ` + "`db:\"id\"`" + `
+	Name string ` + "`db:\"name\"`" + `
+}
+`,
+	},
+	{
+		Name: "int64_autoincr",
+		Src: `package a
+
+type A struct {
+	ID   int64  ` + "`db:\"id\"`" + `
+	Name string ` + "`db:\"name\"`" + `
+}
+`,
+	},
+	{
+		Name: "composite_pk",
+		Src: `package a
+
+type A struct {
+	OrgID  string ` + "`db:\"org_id\" gocode:\"pk\"`" + `
+	UserID string ` + "`db:\"user_id\" gocode:\"pk\"`" + `
+	Role   string ` + "`db:\"role\"`" + `
+}
+`,
+	},
+	{
+		Name: "pointers",
+		Src: `package a
+
+import "time"
+
+type A struct {
+	ID        string     ` + "`db:\"id\"`" + `
+	Name      *string    ` + "`db:\"name\"`" + `
+	Count     *int64     ` + "`db:\"count\"`" + `
+	DeletedAt *time.Time ` + "`db:\"deleted_at\"`" + `
+}
+`,
+	},
+	{
+		Name: "embedded",
+		Src: `package a
+
+import "time"
+
+type Timestamps struct {
+	CreateTime time.Time ` + "`db:\"create_time\"`" + `
+	UpdateTime time.Time ` + "`db:\"update_time\"`" + `
+}
+
+type A struct {
+	ID string ` + "`db:\"id\"`" + `
+	Timestamps
+	Name string ` + "`db:\"name\"`" + `
+}
+`,
+	},
+}
+
+// MongoFixtures covers the kinds of struct the MongoDB templates need to handle.
+var MongoFixtures = []Fixture{
+	{
+		Name: "objectid",
+		Src: `package a
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+type A struct {
+	ID   primitive.ObjectID ` + "`bson:\"_id\"`" + `
+	Name string             ` + "`bson:\"name\"`" + `
+}
+`,
+	},
+	{
+		Name: "string_pk",
+		Src: `package a
+
+type A struct {
+	ID   string ` + "`bson:\"_id\"`" + `
+	Name string ` + "`bson:\"name\"`" + `
+}
+`,
+	},
+	{
+		Name: "composite_pk",
+		Src: `package a
+
+type A struct {
+	OrgID  string ` + "`bson:\"org_id\" gocode:\"pk\"`" + `
+	UserID string ` + "`bson:\"user_id\" gocode:\"pk\"`" + `
+	Role   string ` + "`bson:\"role\"`" + `
+}
+`,
+	},
+	{
+		Name: "objectid_pointers_embedded",
+		Src: `package a
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Timestamps struct {
+	CreateTime time.Time ` + "`bson:\"create_time\"`" + `
+	UpdateTime time.Time ` + "`bson:\"update_time\"`" + `
+}
+
+type A struct {
+	ID         primitive.ObjectID  ` + "`bson:\"_id\"`" + `
+	Timestamps ` + "`bson:\",inline\"`" + `
+	Name       *string             ` + "`bson:\"name\"`" + `
+	ParentID   *primitive.ObjectID ` + "`bson:\"parent_id\"`" + `
+}
+`,
+	},
+}
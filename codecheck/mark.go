@@ -0,0 +1,162 @@
+package codecheck
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// markerPrefix starts the comment Mark adds to each line of template text.
+const markerPrefix = "//gocode:tmpl "
+
+// markerRE matches a marker comment, e.g. "//gocode:tmpl TYPEInsert sqlcrud.tmpl:123".
+var markerRE = regexp.MustCompile(`//gocode:tmpl (\S+) (\S+):(\d+)`)
+
+// Mark returns a copy of tmpl (and the templates associated with it) where each line of
+// literal text ends with a comment giving the define and the template file and line it is
+// from.  Executing it gives the same Go code, with comments Check uses to trace an error
+// back to the template.  It is for checking only, the output is not meant to be kept.
+func Mark(tmpl *template.Template) (*template.Template, error) {
+
+	ret, err := tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range ret.Templates() {
+		if t.Tree == nil || t.Tree.Root == nil {
+			continue
+		}
+		// the parse trees are shared with tmpl, so change a copy
+		t.Tree = t.Tree.Copy()
+		err := markList(t.Tree, t.Name(), t.Tree.Root)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+func markList(tree *parse.Tree, define string, list *parse.ListNode) error {
+	if list == nil {
+		return nil
+	}
+	for _, n := range list.Nodes {
+		var err error
+		switch n := n.(type) {
+		case *parse.TextNode:
+			err = markText(tree, define, n)
+		case *parse.IfNode:
+			err = markBranch(tree, define, &n.BranchNode)
+		case *parse.RangeNode:
+			err = markBranch(tree, define, &n.BranchNode)
+		case *parse.WithNode:
+			err = markBranch(tree, define, &n.BranchNode)
+		case *parse.ListNode:
+			err = markList(tree, define, n)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func markBranch(tree *parse.Tree, define string, n *parse.BranchNode) error {
+	err := markList(tree, define, n.List)
+	if err != nil {
+		return err
+	}
+	return markList(tree, define, n.ElseList)
+}
+
+// markText adds a marker before each newline in n.
+func markText(tree *parse.Tree, define string, n *parse.TextNode) error {
+
+	// location is "name:line:col"
+	loc, _ := tree.ErrorContext(n)
+	parts := strings.Split(loc, ":")
+	if len(parts) < 3 {
+		return fmt.Errorf("unexpected template location %q", loc)
+	}
+	name := strings.Join(parts[:len(parts)-2], ":")
+	line, err := strconv.Atoi(parts[len(parts)-2])
+	if err != nil {
+		return fmt.Errorf("unexpected template location %q", loc)
+	}
+
+	var buf bytes.Buffer
+	for _, b := range n.Text {
+		if b == '\n' {
+			fmt.Fprintf(&buf, " %s%s %s:%d", markerPrefix, define, name, line)
+			line++
+		}
+		buf.WriteByte(b)
+	}
+	n.Text = buf.Bytes()
+
+	return nil
+}
+
+// marker is a parsed marker comment.
+type marker struct {
+	define string
+	name   string
+	line   int
+}
+
+func parseMarker(line string) (m marker, ok bool) {
+	sm := markerRE.FindStringSubmatch(line)
+	if sm == nil {
+		return m, false
+	}
+	n, err := strconv.Atoi(sm[3])
+	if err != nil {
+		return m, false
+	}
+	return marker{define: sm[1], name: sm[2], line: n}, true
+}
+
+// locate fills in the template define and line of e from the markers in its file.  A line
+// without a marker (e.g. the output of an action) is attributed using the closest marker
+// after it, or failing that before it.
+func (r *checkRun) locate(e *Error) {
+
+	if e.Pos.Filename == "" || e.Pos.Line < 1 {
+		return
+	}
+	b, err := fs.ReadFile(r.fsys, e.Pos.Filename)
+	if err != nil {
+		return
+	}
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Buffer(nil, len(b)+1)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	i := e.Pos.Line - 1
+	if i >= len(lines) {
+		return
+	}
+
+	for j := i; j < len(lines); j++ {
+		if m, ok := parseMarker(lines[j]); ok {
+			e.Define, e.Template = m.define, fmt.Sprintf("%s:%d", m.name, m.line-(j-i))
+			return
+		}
+	}
+	for j := i - 1; j >= 0; j-- {
+		if m, ok := parseMarker(lines[j]); ok {
+			e.Define, e.Template = m.define, fmt.Sprintf("%s:%d", m.name, m.line+(i-j))
+			return
+		}
+	}
+}
@@ -0,0 +1,2 @@
+// Package mysql is a stub of github.com/go-sql-driver/mysql for codecheck.
+package mysql
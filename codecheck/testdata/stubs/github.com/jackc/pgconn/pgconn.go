@@ -0,0 +1,7 @@
+// Package pgconn is a stub of github.com/jackc/pgconn for codecheck.
+package pgconn
+
+type CommandTag []byte
+
+func (ct CommandTag) RowsAffected() int64
+func (ct CommandTag) String() string
@@ -0,0 +1,36 @@
+// Package pgx is a stub of github.com/jackc/pgx/v4 for codecheck.
+package pgx
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgconn"
+)
+
+var ErrNoRows = errors.New("no rows in result set")
+
+type Tx interface {
+	Begin(ctx context.Context) (Tx, error)
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (commandTag pgconn.CommandTag, err error)
+	Query(ctx context.Context, sql string, args ...interface{}) (Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) Row
+}
+
+type Rows interface {
+	Close()
+	Err() error
+	CommandTag() pgconn.CommandTag
+	Next() bool
+	Scan(dest ...interface{}) error
+	Values() ([]interface{}, error)
+	RawValues() [][]byte
+}
+
+type Row interface {
+	Scan(dest ...interface{}) error
+}
+
+type TxOptions struct{}
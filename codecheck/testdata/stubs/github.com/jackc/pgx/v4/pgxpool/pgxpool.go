@@ -0,0 +1,21 @@
+// Package pgxpool is a stub of github.com/jackc/pgx/v4/pgxpool for codecheck.
+package pgxpool
+
+import (
+	"context"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+type Pool struct{}
+
+func Connect(ctx context.Context, connString string) (*Pool, error)
+
+func (p *Pool) Begin(ctx context.Context) (pgx.Tx, error)
+func (p *Pool) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+func (p *Pool) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+func (p *Pool) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+func (p *Pool) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+func (p *Pool) Ping(ctx context.Context) error
+func (p *Pool) Close()
@@ -0,0 +1,2 @@
+// Package stdlib is a stub of github.com/jackc/pgx/v4/stdlib for codecheck.
+package stdlib
@@ -0,0 +1,40 @@
+// Package sqlx is a stub of github.com/jmoiron/sqlx for codecheck.
+package sqlx
+
+import (
+	"context"
+	"database/sql"
+)
+
+type DB struct {
+	*sql.DB
+}
+
+func NewDb(db *sql.DB, driverName string) *DB
+
+func (db *DB) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*Tx, error)
+
+type Tx struct {
+	*sql.Tx
+}
+
+func (tx *Tx) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+func (tx *Tx) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+func (tx *Tx) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+func (tx *Tx) QueryxContext(ctx context.Context, query string, args ...interface{}) (*Rows, error)
+func (tx *Tx) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *Row
+func (tx *Tx) Rebind(query string) string
+
+type Rows struct {
+	*sql.Rows
+}
+
+func (r *Rows) StructScan(dest interface{}) error
+
+type Row struct{}
+
+func (r *Row) Scan(dest ...interface{}) error
+func (r *Row) StructScan(dest interface{}) error
+func (r *Row) Err() error
+
+func In(query string, args ...interface{}) (string, []interface{}, error)
@@ -0,0 +1,29 @@
+// Package httprouter is a stub of github.com/julienschmidt/httprouter for codecheck.
+package httprouter
+
+import (
+	"context"
+	"net/http"
+)
+
+type Param struct {
+	Key   string
+	Value string
+}
+
+type Params []Param
+
+func (ps Params) ByName(name string) string
+
+func ParamsFromContext(ctx context.Context) Params
+
+type Handle func(http.ResponseWriter, *http.Request, Params)
+
+type Router struct{}
+
+func New() *Router
+
+func (r *Router) Handler(method, path string, handler http.Handler)
+func (r *Router) HandlerFunc(method, path string, handler http.HandlerFunc)
+func (r *Router) Handle(method, path string, handle Handle)
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request)
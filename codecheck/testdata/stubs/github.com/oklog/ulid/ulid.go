@@ -0,0 +1,10 @@
+// Package ulid is a stub of github.com/oklog/ulid for codecheck.
+package ulid
+
+import "io"
+
+type ULID [16]byte
+
+func MustNew(ms uint64, entropy io.Reader) ULID
+
+func (id ULID) String() string
@@ -0,0 +1,16 @@
+// Package goose is a stub of github.com/pressly/goose/v3 for codecheck.
+package goose
+
+import (
+	"database/sql"
+	"io/fs"
+)
+
+type OptionsFunc func(o *options)
+
+type options struct{}
+
+func SetBaseFS(fsys fs.FS)
+func SetDialect(s string) error
+func Up(db *sql.DB, dir string, opts ...OptionsFunc) error
+func Down(db *sql.DB, dir string, opts ...OptionsFunc) error
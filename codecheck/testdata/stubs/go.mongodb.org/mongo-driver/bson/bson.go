@@ -0,0 +1,12 @@
+// Package bson is a stub of go.mongodb.org/mongo-driver/bson for codecheck.
+package bson
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+type D = primitive.D
+type E = primitive.E
+type M = primitive.M
+type A = primitive.A
+
+func Marshal(val interface{}) ([]byte, error)
+func Unmarshal(data []byte, val interface{}) error
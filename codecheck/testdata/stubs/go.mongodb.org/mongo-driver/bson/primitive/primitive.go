@@ -0,0 +1,24 @@
+// Package primitive is a stub of go.mongodb.org/mongo-driver/bson/primitive for codecheck.
+package primitive
+
+type E struct {
+	Key   string
+	Value interface{}
+}
+
+type D []E
+
+type M map[string]interface{}
+
+type A []interface{}
+
+type ObjectID [12]byte
+
+var NilObjectID ObjectID
+
+func NewObjectID() ObjectID
+func ObjectIDFromHex(s string) (ObjectID, error)
+
+func (id ObjectID) Hex() string
+func (id ObjectID) String() string
+func (id ObjectID) IsZero() bool
@@ -0,0 +1,61 @@
+// Package mongo is a stub of go.mongodb.org/mongo-driver/mongo for codecheck.
+package mongo
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrNoDocuments = errors.New("mongo: no documents in result")
+
+type Client struct{}
+
+func NewClient(opts ...*options.ClientOptions) (*Client, error)
+func Connect(ctx context.Context, opts ...*options.ClientOptions) (*Client, error)
+
+func (c *Client) Connect(ctx context.Context) error
+func (c *Client) Disconnect(ctx context.Context) error
+func (c *Client) Database(name string, opts ...*options.DatabaseOptions) *Database
+
+type Database struct{}
+
+func (db *Database) Collection(name string, opts ...*options.CollectionOptions) *Collection
+func (db *Database) Drop(ctx context.Context) error
+
+type Collection struct{}
+
+func (coll *Collection) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*InsertOneResult, error)
+func (coll *Collection) DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*DeleteResult, error)
+func (coll *Collection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*UpdateResult, error)
+func (coll *Collection) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) *SingleResult
+func (coll *Collection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*Cursor, error)
+func (coll *Collection) CountDocuments(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error)
+
+type InsertOneResult struct {
+	InsertedID interface{}
+}
+
+type DeleteResult struct {
+	DeletedCount int64
+}
+
+type UpdateResult struct {
+	MatchedCount  int64
+	ModifiedCount int64
+	UpsertedCount int64
+	UpsertedID    interface{}
+}
+
+type SingleResult struct{}
+
+func (sr *SingleResult) Decode(v interface{}) error
+func (sr *SingleResult) Err() error
+
+type Cursor struct{}
+
+func (c *Cursor) Next(ctx context.Context) bool
+func (c *Cursor) Decode(val interface{}) error
+func (c *Cursor) Err() error
+func (c *Cursor) Close(ctx context.Context) error
@@ -0,0 +1,22 @@
+// Package options is a stub of go.mongodb.org/mongo-driver/mongo/options for codecheck.
+package options
+
+type ClientOptions struct{}
+
+func Client() *ClientOptions
+
+func (c *ClientOptions) ApplyURI(uri string) *ClientOptions
+
+type DatabaseOptions struct{}
+type CollectionOptions struct{}
+type InsertOneOptions struct{}
+type DeleteOptions struct{}
+type UpdateOptions struct{}
+type FindOneOptions struct{}
+type CountOptions struct{}
+
+type FindOptions struct {
+	Limit *int64
+	Skip  *int64
+	Sort  interface{}
+}
@@ -0,0 +1,300 @@
+// Package codeflag is used by each gocode tool to declare its flags along with the
+// extra information other programs need to drive it: the type and default of each
+// flag, whether it is required, which config setting backs it and some example
+// command lines.  Running a tool with -describe prints all of this as JSON, so
+// the gocode command, shell completion and UIs can read it instead of scraping -h output.
+package codeflag
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/d0sbit/gocode/config"
+)
+
+// ErrDescribed is returned by Parse when -describe was given and the description has been written.
+// The tool should exit successfully without doing anything else.
+var ErrDescribed = errors.New("description written")
+
+// Description is what -describe outputs as JSON.
+type Description struct {
+	Tool        string     `json:"tool"`               // tool name without the gocode_ prefix, e.g. "sqlcrud"
+	Description string     `json:"description"`        // one line description
+	Flags       []FlagInfo `json:"flags"`              // in the order they were declared
+	Args        *ArgInfo   `json:"args,omitempty"`     // positional arguments, nil if none accepted
+	Examples    []Example  `json:"examples,omitempty"` // example command lines
+}
+
+// FlagInfo describes a single flag.
+type FlagInfo struct {
+	Name      string      `json:"name"`                 // name without leading dash
+	Type      string      `json:"type"`                 // "string", "bool" or "int"
+	Default   interface{} `json:"default"`              // default value with the same type as Type
+	Usage     string      `json:"usage"`                // help text
+	Required  bool        `json:"required,omitempty"`   // tool cannot run without it (unless it can be inferred some other way, see ArgInfo)
+	ConfigKey string      `json:"config_key,omitempty"` // setting in .gocode/gocode.toml used when the flag is not given
+	Transient bool        `json:"transient,omitempty"`  // only affects how the tool runs (e.g. -dry-run), not what is generated
+}
+
+// ArgInfo describes the positional arguments a tool accepts.
+type ArgInfo struct {
+	Name     string `json:"name"`               // short name for usage output, e.g. "file.go"
+	Usage    string `json:"usage"`              // help text
+	Required bool   `json:"required,omitempty"` // at least one must be given
+}
+
+// Example is an example command line with a short explanation.
+type Example struct {
+	Command     string `json:"command"`
+	Description string `json:"description,omitempty"`
+}
+
+// Option sets additional information on a flag when it is declared.
+type Option func(fi *FlagInfo)
+
+// Required marks a flag as required.
+func Required() Option {
+	return func(fi *FlagInfo) { fi.Required = true }
+}
+
+// ConfigKey indicates the flag's value comes from the named config
+// setting when it is not given on the command line (see FlagSet.ApplyConfig).
+func ConfigKey(key string) Option {
+	return func(fi *FlagInfo) { fi.ConfigKey = key }
+}
+
+// Transient marks a flag as affecting only how the tool runs rather than what it generates,
+// e.g. -dry-run or -v.  Transient flags are left out by Values.
+func Transient() Option {
+	return func(fi *FlagInfo) { fi.Transient = true }
+}
+
+// FlagSet wraps a flag.FlagSet and keeps the information needed to describe each flag.
+// Flags should be declared with the methods on FlagSet rather than the embedded flag.FlagSet
+// so they are included in the description.
+type FlagSet struct {
+	*flag.FlagSet
+
+	desc      Description
+	describeF *bool
+}
+
+// New returns a FlagSet for the named tool (e.g. "sqlcrud") which declares flags on flagSet.
+// It adds the -describe flag and replaces the usage output.
+func New(flagSet *flag.FlagSet, tool, description string) *FlagSet {
+	fs := &FlagSet{
+		FlagSet: flagSet,
+		desc: Description{
+			Tool:        tool,
+			Description: description,
+			Flags:       []FlagInfo{},
+		},
+	}
+	fs.describeF = flagSet.Bool("describe", false, "Write a JSON description of this tool and its flags to stdout and exit")
+	flagSet.Usage = func() { fs.WriteUsage(flagSet.Output()) }
+	return fs
+}
+
+func (fs *FlagSet) add(fi FlagInfo, opts []Option) {
+	for _, o := range opts {
+		o(&fi)
+	}
+	fs.desc.Flags = append(fs.desc.Flags, fi)
+}
+
+// String declares a string flag.
+func (fs *FlagSet) String(name, value, usage string, opts ...Option) *string {
+	fs.add(FlagInfo{Name: name, Type: "string", Default: value, Usage: usage}, opts)
+	return fs.FlagSet.String(name, value, usage)
+}
+
+// Bool declares a bool flag.
+func (fs *FlagSet) Bool(name string, value bool, usage string, opts ...Option) *bool {
+	fs.add(FlagInfo{Name: name, Type: "bool", Default: value, Usage: usage}, opts)
+	return fs.FlagSet.Bool(name, value, usage)
+}
+
+// Int declares an int flag.
+func (fs *FlagSet) Int(name string, value int, usage string, opts ...Option) *int {
+	fs.add(FlagInfo{Name: name, Type: "int", Default: value, Usage: usage}, opts)
+	return fs.FlagSet.Int(name, value, usage)
+}
+
+// Positional declares the positional arguments the tool accepts.
+func (fs *FlagSet) Positional(name, usage string, required bool) {
+	fs.desc.Args = &ArgInfo{Name: name, Usage: usage, Required: required}
+}
+
+// Example adds an example command line.  The command should be written as it would
+// be run through the gocode command, e.g. "gocode sqlcrud -type Widget".
+func (fs *FlagSet) Example(command, description string) {
+	fs.desc.Examples = append(fs.desc.Examples, Example{Command: command, Description: description})
+}
+
+// Describe returns the description of the tool and its flags.
+func (fs *FlagSet) Describe() *Description {
+	d := fs.desc
+	return &d
+}
+
+// WriteDescription writes the description as indented JSON.
+func (fs *FlagSet) WriteDescription(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(fs.Describe())
+}
+
+// Parse parses the command line args.  If -describe was given the description is written
+// to stdout and ErrDescribed is returned.  Errors from the underlying flag.FlagSet
+// (including flag.ErrHelp) are returned as-is.
+func (fs *FlagSet) Parse(args []string, stdout io.Writer) error {
+	err := fs.FlagSet.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *fs.describeF {
+		err := fs.WriteDescription(stdout)
+		if err != nil {
+			return err
+		}
+		return ErrDescribed
+	}
+	return nil
+}
+
+// ExitCode returns the exit code a tool should use for an error returned by Parse.
+func ExitCode(err error) int {
+	if err == nil || err == ErrDescribed || err == flag.ErrHelp {
+		return 0
+	}
+	return 2
+}
+
+// IsSet returns true if the named flag was given on the command line.
+func (fs *FlagSet) IsSet(name string) bool {
+	found := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
+// ApplyConfig sets each flag declared with a ConfigKey, which was not given on the command line,
+// to the corresponding setting from c if present.
+func (fs *FlagSet) ApplyConfig(c *config.Config) error {
+	if c == nil {
+		return nil
+	}
+	for _, fi := range fs.desc.Flags {
+		if fi.ConfigKey == "" || fs.IsSet(fi.Name) {
+			continue
+		}
+		if _, ok := c.Settings[fi.ConfigKey]; !ok {
+			continue
+		}
+		err := fs.Set(fi.Name, c.GetString(fi.ConfigKey, ""))
+		if err != nil {
+			return fmt.Errorf("setting -%s from config %q: %w", fi.Name, fi.ConfigKey, err)
+		}
+	}
+	return nil
+}
+
+// Values returns the current value of each non-transient flag that differs from its default.
+// This includes values set from config and any the tool has set itself after parsing,
+// and is what is needed to run the tool again with the same result.
+func (fs *FlagSet) Values() map[string]string {
+	ret := make(map[string]string)
+	for _, fi := range fs.desc.Flags {
+		if fi.Transient {
+			continue
+		}
+		f := fs.Lookup(fi.Name)
+		if f == nil || f.Value.String() == f.DefValue {
+			continue
+		}
+		ret[fi.Name] = f.Value.String()
+	}
+	return ret
+}
+
+// Missing returns the names of required flags that have an empty value.
+func (fs *FlagSet) Missing() []string {
+	var ret []string
+	for _, fi := range fs.desc.Flags {
+		if !fi.Required {
+			continue
+		}
+		f := fs.Lookup(fi.Name)
+		if f == nil || f.Value.String() == "" {
+			ret = append(ret, fi.Name)
+		}
+	}
+	return ret
+}
+
+// WriteUsage writes human readable usage information, this is what -h shows.
+func (fs *FlagSet) WriteUsage(w io.Writer) {
+
+	d := fs.desc
+
+	fmt.Fprintf(w, "gocode %s - %s\n\nUsage:\n\n\tgocode %s [flags]", d.Tool, d.Description, d.Tool)
+	if d.Args != nil {
+		if d.Args.Required {
+			fmt.Fprintf(w, " %s", d.Args.Name)
+		} else {
+			fmt.Fprintf(w, " [%s]", d.Args.Name)
+		}
+	}
+	fmt.Fprint(w, "\n\n")
+	if d.Args != nil {
+		fmt.Fprintf(w, "%s: %s\n\n", d.Args.Name, d.Args.Usage)
+	}
+
+	fmt.Fprint(w, "Flags:\n\n")
+	flags := append([]FlagInfo(nil), d.Flags...)
+	sort.SliceStable(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	for _, fi := range flags {
+		fmt.Fprintf(w, "\t-%s", fi.Name)
+		if fi.Type != "bool" {
+			fmt.Fprintf(w, " %s", fi.Type)
+		}
+		fmt.Fprintf(w, "\n\t\t%s", strings.ReplaceAll(fi.Usage, "\n", "\n\t\t"))
+		var notes []string
+		if fi.Required {
+			notes = append(notes, "required")
+		}
+		if def := fmt.Sprint(fi.Default); def != "" && def != "false" && def != "0" {
+			if fi.Type == "string" {
+				def = strconv.Quote(def)
+			}
+			notes = append(notes, "default "+def)
+		}
+		if fi.ConfigKey != "" {
+			notes = append(notes, "config "+fi.ConfigKey)
+		}
+		if len(notes) > 0 {
+			fmt.Fprintf(w, " (%s)", strings.Join(notes, ", "))
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "\t-describe\n\t\tWrite a JSON description of this tool and its flags to stdout and exit\n")
+
+	if len(d.Examples) > 0 {
+		fmt.Fprint(w, "\nExamples:\n\n")
+		for _, ex := range d.Examples {
+			fmt.Fprintf(w, "\t%s\n", ex.Command)
+			if ex.Description != "" {
+				fmt.Fprintf(w, "\t\t%s\n", ex.Description)
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package codeflag
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/d0sbit/gocode/config"
+)
+
+func newTestFlagSet() (*FlagSet, *string, *string, *bool) {
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	flagSet.SetOutput(ioutil.Discard)
+	fs := New(flagSet, "example", "Example tool")
+	typeF := fs.String("type", "", "Type name", Required())
+	dirF := fs.String("dir", "store", "Store directory", ConfigKey("store_dir"))
+	dryRunF := fs.Bool("dry-run", false, "Dry run", Transient())
+	fs.Positional("file.go", "File to generate", false)
+	fs.Example("gocode example -type Widget", "Generate for Widget")
+	return fs, typeF, dirF, dryRunF
+}
+
+func TestDescribe(t *testing.T) {
+
+	fs, _, _, _ := newTestFlagSet()
+
+	var buf bytes.Buffer
+	err := fs.Parse([]string{"-describe"}, &buf)
+	if err != ErrDescribed {
+		t.Fatalf("expected ErrDescribed, got %v", err)
+	}
+	if ExitCode(err) != 0 {
+		t.Errorf("unexpected exit code %d", ExitCode(err))
+	}
+	t.Logf("description: %s", buf.String())
+
+	var d Description
+	must(t, json.Unmarshal(buf.Bytes(), &d))
+
+	expected := Description{
+		Tool:        "example",
+		Description: "Example tool",
+		Flags: []FlagInfo{
+			{Name: "type", Type: "string", Default: "", Usage: "Type name", Required: true},
+			{Name: "dir", Type: "string", Default: "store", Usage: "Store directory", ConfigKey: "store_dir"},
+			{Name: "dry-run", Type: "bool", Default: false, Usage: "Dry run", Transient: true},
+		},
+		Args:     &ArgInfo{Name: "file.go", Usage: "File to generate"},
+		Examples: []Example{{Command: "gocode example -type Widget", Description: "Generate for Widget"}},
+	}
+	if !reflect.DeepEqual(&d, &expected) {
+		t.Errorf("unexpected description: %#v", d)
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+
+	cfg := &config.Config{Settings: map[string]interface{}{"store_dir": "mstore"}}
+
+	type tcase struct {
+		name    string
+		args    []string
+		dir     string
+		missing []string
+		values  map[string]string
+	}
+
+	tcaseList := []tcase{
+		{
+			name:    "from_config",
+			args:    []string{},
+			dir:     "mstore",
+			missing: []string{"type"},
+			values:  map[string]string{"dir": "mstore"},
+		},
+		{
+			name:   "flag_wins",
+			args:   []string{"-type", "Widget", "-dir", "other", "-dry-run"},
+			dir:    "other",
+			values: map[string]string{"type": "Widget", "dir": "other"},
+		},
+	}
+
+	for _, tc := range tcaseList {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			fs, _, dirF, _ := newTestFlagSet()
+			must(t, fs.Parse(tc.args, ioutil.Discard))
+			must(t, fs.ApplyConfig(cfg))
+			if *dirF != tc.dir {
+				t.Errorf("unexpected dir: expected=%q, got=%q", tc.dir, *dirF)
+			}
+			if !reflect.DeepEqual(fs.Missing(), tc.missing) {
+				t.Errorf("unexpected missing: %q", fs.Missing())
+			}
+			if !reflect.DeepEqual(fs.Values(), tc.values) {
+				t.Errorf("unexpected values: %q", fs.Values())
+			}
+		})
+	}
+}
+
+func TestWriteUsage(t *testing.T) {
+
+	fs, _, _, _ := newTestFlagSet()
+
+	var buf bytes.Buffer
+	fs.WriteUsage(&buf)
+	t.Logf("usage:\n%s", buf.String())
+
+	for _, s := range []string{
+		"gocode example [flags] [file.go]",
+		"-type string\n\t\tType name (required)",
+		`(default "store", config store_dir)`,
+		"gocode example -type Widget",
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("usage missing %q", s)
+		}
+	}
+
+	err := fs.Parse([]string{"-h"}, ioutil.Discard)
+	if err != flag.ErrHelp || ExitCode(err) != 0 {
+		t.Errorf("unexpected -h result: %v", err)
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,188 @@
+// Package codegolden compares generated files with golden copies checked in alongside the
+// tests, so a change to a template shows up in review as the change to the code it produces.
+//
+// Golden files are stored under a directory in the OS filesystem (usually testdata/golden/<case>)
+// at the same relative path as the file they are for, plus a ".golden" suffix, which keeps
+// them from being seen as Go source.  Tests normally take an -update flag and pass it to
+// Compare to rewrite the golden files after an intended change.
+package codegolden
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/psanford/memfs"
+
+	"github.com/d0sbit/gocode/srcedit/diff"
+)
+
+// Suffix is added to the name of each golden file.
+const Suffix = ".golden"
+
+// Compare compares each file under dir in fsys with its golden file under goldenDir, and
+// returns a map of file name (relative to dir) to a description of the difference, rendered
+// with srcedit/diff.  Files missing on either side are reported too.  If update is true the
+// golden files are instead rewritten to match fsys, stale ones are removed, and nothing is
+// reported.
+func Compare(fsys fs.FS, dir, goldenDir string, update bool) (map[string]string, error) {
+
+	got, err := readTree(fsys, dir, "")
+	if err != nil {
+		return nil, fmt.Errorf("reading generated files: %w", err)
+	}
+	want, err := readTree(os.DirFS(goldenDir), ".", Suffix)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("reading golden files: %w", err)
+	}
+
+	if update {
+		return nil, write(goldenDir, got, want)
+	}
+
+	ret := make(map[string]string)
+
+	gotFS, wantFS := memfs.New(), memfs.New()
+	for p, b := range got {
+		err := writeMem(gotFS, p, b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for p, b := range want {
+		if _, ok := got[p]; !ok {
+			ret[p] = "golden file exists but the file was not generated"
+			continue
+		}
+		err := writeMem(wantFS, p, b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	diffMap, err := diff.Run(wantFS, gotFS, ".", "text")
+	if err != nil {
+		return nil, err
+	}
+	for p, d := range diffMap {
+		if _, ok := want[p]; !ok {
+			d = "no golden file, generated:\n" + d
+		}
+		ret[p] = d
+	}
+
+	return ret, nil
+}
+
+// CopyToDir writes the files in fsys to the OS directory dir, e.g. to set up a module for
+// a tool that works on the current directory.
+func CopyToDir(fsys fs.FS, dir string) error {
+	files, err := readTree(fsys, ".", "")
+	if err != nil {
+		return err
+	}
+	for p, b := range files {
+		fp := filepath.Join(dir, filepath.FromSlash(p))
+		err := os.MkdirAll(filepath.Dir(fp), 0755)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(fp, b, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveUnchanged removes the files in the OS directory dir which are the same as in fsys,
+// e.g. after CopyToDir and running a tool, so that only what the tool wrote is compared.
+func RemoveUnchanged(fsys fs.FS, dir string) error {
+	files, err := readTree(fsys, ".", "")
+	if err != nil {
+		return err
+	}
+	for p, b := range files {
+		fp := filepath.Join(dir, filepath.FromSlash(p))
+		cur, err := os.ReadFile(fp)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		if bytes.Equal(cur, b) {
+			err := os.Remove(fp)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readTree returns the contents of the files under dir, by path relative to dir.  Only
+// files ending in suffix are read, and it is removed from the path.
+func readTree(fsys fs.FS, dir, suffix string) (map[string][]byte, error) {
+	ret := make(map[string][]byte)
+	err := fs.WalkDir(fsys, dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(p, suffix) {
+			return nil
+		}
+		b, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(p, dir), "/")
+		if dir == "." {
+			rel = p
+		}
+		ret[strings.TrimSuffix(rel, suffix)] = b
+		return nil
+	})
+	return ret, err
+}
+
+// write updates the golden files in goldenDir to got, removing those in want
+// that are no longer generated.
+func write(goldenDir string, got, want map[string][]byte) error {
+	for p := range want {
+		if _, ok := got[p]; ok {
+			continue
+		}
+		err := os.Remove(filepath.Join(goldenDir, filepath.FromSlash(p+Suffix)))
+		if err != nil {
+			return err
+		}
+	}
+	for p, b := range got {
+		fp := filepath.Join(goldenDir, filepath.FromSlash(p+Suffix))
+		err := os.MkdirAll(filepath.Dir(fp), 0755)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(fp, b, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeMem(fsys *memfs.FS, p string, b []byte) error {
+	if d := path.Dir(p); d != "." {
+		err := fsys.MkdirAll(d, 0755)
+		if err != nil {
+			return err
+		}
+	}
+	return fsys.WriteFile(p, b, 0644)
+}
@@ -0,0 +1,67 @@
+package codegolden
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/psanford/memfs"
+)
+
+func TestCompare(t *testing.T) {
+
+	goldenDir := t.TempDir()
+
+	fsys := memfs.New()
+	must(t, fsys.MkdirAll("out/a", 0755))
+	must(t, fsys.WriteFile("out/a/a.go", []byte("package a\n\nfunc A() {}\n"), 0644))
+	must(t, fsys.WriteFile("out/a/b.go", []byte("package a\n"), 0644))
+
+	diffs, err := Compare(fsys, "out", goldenDir, true)
+	must(t, err)
+	if len(diffs) != 0 {
+		t.Fatalf("unexpected diffs on update: %v", diffs)
+	}
+
+	diffs, err = Compare(fsys, "out", goldenDir, false)
+	must(t, err)
+	if len(diffs) != 0 {
+		t.Fatalf("unexpected diffs after update: %v", diffs)
+	}
+
+	fsys2 := memfs.New()
+	must(t, fsys2.MkdirAll("out/a", 0755))
+	must(t, fsys2.WriteFile("out/a/a.go", []byte("package a\n\nfunc B() {}\n"), 0644))
+	must(t, fsys2.WriteFile("out/a/c.go", []byte("package a\n"), 0644))
+
+	diffs, err = Compare(fsys2, "out", goldenDir, false)
+	must(t, err)
+	expected := map[string]string{
+		"a/a.go": "-func A() {}",
+		"a/b.go": "not generated",
+		"a/c.go": "no golden file",
+	}
+	if len(diffs) != len(expected) {
+		t.Errorf("got diffs for %d files, expected %d: %v", len(diffs), len(expected), diffs)
+	}
+	for fn, s := range expected {
+		if !strings.Contains(diffs[fn], s) {
+			t.Errorf("diff for %s: expected it to contain %q, got: %q", fn, s, diffs[fn])
+		}
+	}
+
+	// update removes the golden file for b.go
+	_, err = Compare(fsys2, "out", goldenDir, true)
+	must(t, err)
+	diffs, err = Compare(fsys2, "out", goldenDir, false)
+	must(t, err)
+	if len(diffs) != 0 {
+		t.Errorf("unexpected diffs after second update: %v", diffs)
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,199 @@
+// Package codeplan saves the transforms a generator would apply to a plan file, so they can be
+// reviewed (e.g. in a pull request) and applied later with `gocode apply`.  A plan records the hash
+// of every Go file in the packages it changes, and of the other files its transforms change, and
+// is only applied if they are still the same.
+package codeplan
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/d0sbit/gocode/config"
+	"github.com/d0sbit/gocode/srcedit"
+)
+
+// Version is the plan file format version written by this package.
+const Version = 1
+
+// Plan is the contents of a plan file.
+type Plan struct {
+	Version    int                   `json:"version"`
+	Tool       string                `json:"tool"`               // the tool that made the plan, e.g. "sqlcrud"
+	ModulePath string                `json:"module_path"`        // from go.mod, the plan is only applied to the same module
+	Packages   []Package             `json:"packages"`           // applied together, all or nothing
+	Manifest   *config.ManifestEntry `json:"manifest,omitempty"` // recorded in the manifest once applied
+}
+
+// Package is the transforms for one package.
+type Package struct {
+	Dir        string                `json:"dir"`        // package directory relative to the module root
+	Inputs     map[string]string     `json:"inputs"`     // SHA-256 of each Go file in Dir when planned, and of other files the transforms change ("" if missing), by file name
+	Transforms srcedit.TransformList `json:"transforms"` // see srcedit.TransformList for the format
+}
+
+// New returns an empty plan for the given tool and module.
+func New(tool, modulePath string) *Plan {
+	return &Plan{Version: Version, Tool: tool, ModulePath: modulePath}
+}
+
+// AddPackage adds the transforms for p to the plan, along with the hashes of its files as they are now.
+func (pl *Plan) AddPackage(p *srcedit.Package, trList []srcedit.Transform) error {
+	inputs, err := packageInputs(p, trList)
+	if err != nil {
+		return fmt.Errorf("hashing files in %q: %w", p.SubDir(), err)
+	}
+	pl.Packages = append(pl.Packages, Package{
+		Dir:        p.SubDir(),
+		Inputs:     inputs,
+		Transforms: trList,
+	})
+	return nil
+}
+
+// WriteFile writes the plan to the named file on disk.
+func (pl *Plan) WriteFile(name string) error {
+	b, err := json.MarshalIndent(pl, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(b, '\n'), 0644)
+}
+
+// ReadFile reads a plan from the named file on disk.
+func ReadFile(name string) (*Plan, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var pl Plan
+	err = json.Unmarshal(b, &pl)
+	if err != nil {
+		return nil, fmt.Errorf("parsing plan %q: %w", name, err)
+	}
+	if pl.Version != Version {
+		return nil, fmt.Errorf("plan %q has version %d, this version of gocode reads version %d", name, pl.Version, Version)
+	}
+	return &pl, nil
+}
+
+// ErrChanged is returned by Check and Apply when files have changed since the plan was made.
+var ErrChanged = errors.New("files changed since the plan was made")
+
+// Check returns an error wrapping ErrChanged, listing the files, if any Go file in the plan's
+// packages or other file its transforms change is not as it was when the plan was made.
+func (pl *Plan) Check(moduleFS fs.FS, modulePath string) error {
+
+	if pl.ModulePath != modulePath {
+		return fmt.Errorf("plan is for module %q, not %q", pl.ModulePath, modulePath)
+	}
+
+	var changed []string
+	for _, pp := range pl.Packages {
+		p := srcedit.NewPackage(moduleFS, moduleFS, modulePath, pp.Dir)
+		hashes, err := packageInputs(p, pp.Transforms)
+		if err != nil {
+			return fmt.Errorf("hashing files in %q: %w", pp.Dir, err)
+		}
+		for fn, h := range hashes {
+			if pp.Inputs[fn] != h {
+				changed = append(changed, path.Join(pp.Dir, fn))
+			}
+		}
+		for fn := range pp.Inputs {
+			if _, ok := hashes[fn]; !ok {
+				changed = append(changed, path.Join(pp.Dir, fn))
+			}
+		}
+	}
+
+	if len(changed) > 0 {
+		sort.Strings(changed)
+		return fmt.Errorf("%w: %v", ErrChanged, changed)
+	}
+	return nil
+}
+
+// Apply checks the plan against moduleFS (see Check) and then applies it: the transforms for
+// all packages together, and then its manifest entry.  Declarations that were
+// left alone because they were changed by hand are returned, see srcedit.Conflict.
+func (pl *Plan) Apply(moduleFS fs.FS, modulePath string) ([]srcedit.Conflict, error) {
+
+	err := pl.Check(moduleFS, modulePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// a new package, e.g. migrations, has its directory created along with its files
+	var ptList []srcedit.PackageTransforms
+	for _, pp := range pl.Packages {
+		ptList = append(ptList, srcedit.PackageTransforms{
+			Package:    srcedit.NewPackage(moduleFS, moduleFS, modulePath, pp.Dir),
+			Transforms: pp.Transforms,
+		})
+	}
+	err = srcedit.ApplyPackageTransforms(ptList...)
+	if err != nil {
+		return nil, err
+	}
+	var conflicts []srcedit.Conflict
+	for _, pt := range ptList {
+		conflicts = append(conflicts, pt.Package.Conflicts()...)
+	}
+
+	if pl.Manifest != nil {
+		err := config.RecordRunFS(moduleFS, *pl.Manifest)
+		if err != nil {
+			return nil, fmt.Errorf("recording run in %s: %w", config.ManifestPath, err)
+		}
+	}
+
+	return conflicts, nil
+}
+
+// packageInputs returns the hashes to record for p before trList is applied: those of its Go
+// files, and of the other files trList changes, with "" for ones that don't exist yet.
+func packageInputs(p *srcedit.Package, trList []srcedit.Transform) (map[string]string, error) {
+	ret, err := p.FileHashes()
+	if errors.Is(err, fs.ErrNotExist) { // a new package
+		ret, err = make(map[string]string), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, tr := range trList {
+		var fname string
+		switch t := tr.(type) {
+		case *srcedit.AddFileTransform:
+			fname = t.Filename
+		case *srcedit.AppendFileSectionTransform:
+			fname = t.Filename
+		default:
+			continue
+		}
+		h, err := p.FileHash(fname)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		ret[fname] = h
+	}
+	return ret, nil
+}
+
+// String returns a short summary of what the plan changes.
+func (pl *Plan) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s plan", pl.Tool)
+	if pl.Manifest != nil {
+		fmt.Fprintf(&buf, " for %s", pl.Manifest.Type)
+	}
+	for _, pp := range pl.Packages {
+		fmt.Fprintf(&buf, ", %d transforms in %q", len(pp.Transforms), pp.Dir)
+	}
+	return buf.String()
+}
@@ -0,0 +1,139 @@
+package codeplan
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/psanford/memfs"
+
+	"github.com/d0sbit/gocode/config"
+	"github.com/d0sbit/gocode/srcedit"
+)
+
+func TestPlan(t *testing.T) {
+
+	type tcase struct {
+		name   string
+		change func(fsys *memfs.FS) // made between planning and applying
+		eerr   string               // "" means it applies
+	}
+
+	tcList := []tcase{
+		{
+			name:   "unchanged",
+			change: func(fsys *memfs.FS) {},
+		},
+		{
+			name: "edited",
+			change: func(fsys *memfs.FS) {
+				must(t, fsys.WriteFile("a/a.go", []byte("package a\n\ntype A struct{ X int }\n"), 0644))
+			},
+			eerr: "[a/a.go]",
+		},
+		{
+			name: "added",
+			change: func(fsys *memfs.FS) {
+				must(t, fsys.WriteFile("a/c.go", []byte("package a\n"), 0644))
+			},
+			eerr: "[a/c.go]",
+		},
+		{
+			name: "created",
+			change: func(fsys *memfs.FS) {
+				must(t, fsys.WriteFile("a/notes.txt", []byte("mine"), 0644))
+			},
+			eerr: "[a/notes.txt]",
+		},
+		{
+			name: "edited_other",
+			change: func(fsys *memfs.FS) {
+				must(t, fsys.WriteFile("a/README.md", []byte("# A\n\nMore.\n"), 0644))
+			},
+			eerr: "[a/README.md]",
+		},
+	}
+
+	for _, tc := range tcList {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+
+			fsys := memfs.New()
+			must(t, fsys.MkdirAll("a", 0755))
+			must(t, fsys.WriteFile("a/a.go", []byte("package a\n\ntype A struct{}\n"), 0644))
+			must(t, fsys.WriteFile("a/README.md", []byte("# A\n"), 0644))
+
+			pl := New("test", "test1")
+			must(t, pl.AddPackage(srcedit.NewPackage(fsys, fsys, "test1", "a"), []srcedit.Transform{
+				&srcedit.AddFuncDeclTransform{Filename: "b.go", Name: "F", Text: "func F() A { return A{} }"},
+				&srcedit.AppendFileSectionTransform{Filename: "README.md", Name: "F", Text: "F returns an A."},
+				&srcedit.AddFileTransform{Filename: "notes.txt", Text: "notes"},
+			}))
+			pl.Manifest = &config.ManifestEntry{Tool: "test", Type: "A", Package: "a", Files: []string{"a/b.go"}}
+
+			// through a file and back
+			planFile := filepath.Join(t.TempDir(), "plan.json")
+			must(t, pl.WriteFile(planFile))
+			pl, err := ReadFile(planFile)
+			must(t, err)
+
+			tc.change(fsys)
+			_, err = pl.Apply(fsys, "test1")
+			if tc.eerr != "" {
+				if !errors.Is(err, ErrChanged) || !strings.Contains(err.Error(), tc.eerr) {
+					t.Fatalf("expected %v with %q, got: %v", ErrChanged, tc.eerr, err)
+				}
+				if _, err := fs.Stat(fsys, "a/b.go"); err == nil {
+					t.Errorf("a/b.go was written even though the plan was refused")
+				}
+				return
+			}
+			must(t, err)
+
+			b, err := fs.ReadFile(fsys, "a/b.go")
+			must(t, err)
+			if !strings.Contains(string(b), "func F() A") {
+				t.Errorf("b.go does not have F:\n%s", b)
+			}
+			b, err = fs.ReadFile(fsys, "a/notes.txt")
+			must(t, err)
+			if string(b) != "notes" {
+				t.Errorf("notes.txt has %q", b)
+			}
+			b, err = fs.ReadFile(fsys, "a/README.md")
+			must(t, err)
+			if string(b) != "# A\n<!-- gocode:section F begin -->\nF returns an A.\n<!-- gocode:section F end -->\n" {
+				t.Errorf("README.md does not have the section:\n%s", b)
+			}
+			m, err := config.LoadManifestFS(fsys)
+			must(t, err)
+			if len(m.Find("test", "A")) != 1 {
+				t.Errorf("manifest entry not recorded: %+v", m)
+			}
+
+			// the files are different now, so it can't be applied twice
+			_, err = pl.Apply(fsys, "test1")
+			if !errors.Is(err, ErrChanged) {
+				t.Errorf("expected %v applying again, got: %v", ErrChanged, err)
+			}
+		})
+	}
+}
+
+func TestPlanModule(t *testing.T) {
+	fsys := memfs.New()
+	pl := New("test", "test1")
+	_, err := pl.Apply(fsys, "test2")
+	if err == nil || !strings.Contains(err.Error(), `plan is for module "test1"`) {
+		t.Errorf("expected module mismatch error, got: %v", err)
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,96 @@
+// Package codetmpl loads the templates for a tool, letting a project override any of the
+// built-in ones.
+//
+// Each tool has its templates embedded in one file of {{define}} blocks, e.g. sqlcrud.tmpl.
+// Any *.tmpl files in .gocode/templates/<tool>/ in the module are parsed after it, and
+// each {{define}} found there replaces the built-in block of the same name.  Blocks that
+// are not redefined keep their built-in version, so a project only needs to copy the
+// blocks it wants to change.
+//
+// A tool can also have template sets, extra built-in files whose blocks replace those in
+// the main file, e.g. sqlcrud-pgx.tmpl.  Only the files for the selected set are parsed.
+package codetmpl
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"text/template"
+)
+
+// Root is the directory within the module which has a subdirectory of templates for each tool.
+const Root = ".gocode/templates"
+
+// Dir returns the directory within the module for project templates for tool, e.g. ".gocode/templates/sqlcrud".
+func Dir(tool string) string {
+	return path.Join(Root, tool)
+}
+
+// Parse parses the built-in templates defaultNames from defaultFS into tmpl, in order, followed
+// by the project templates for tool from moduleFS.  A project file with the same name as a
+// built-in one (as written by Install) is parsed right after it, and one with the name of a
+// built-in file that is not in defaultNames (e.g. from a template set not selected) is
+// skipped.  It returns tmpl and the paths of the project template files that were parsed, if
+// any.  moduleFS may be nil to only use the built-in ones.
+func Parse(tmpl *template.Template, defaultFS fs.FS, defaultNames []string, moduleFS fs.FS, tool string) (*template.Template, []string, error) {
+
+	var projectList []string
+	projectFile := make(map[string]bool)
+	if moduleFS != nil {
+		// ParseFS fails if the pattern matches nothing, which is the usual case
+		var err error
+		projectList, err = fs.Glob(moduleFS, path.Join(Dir(tool), "*.tmpl"))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, fn := range projectList {
+			projectFile[fn] = true
+		}
+	}
+
+	var fileList []string
+	parseProject := func(fn string) error {
+		b, err := fs.ReadFile(moduleFS, fn)
+		if err != nil {
+			return err
+		}
+		// a file with only defines has an empty body and does not replace anything itself
+		_, err = tmpl.New(fn).Parse(string(b))
+		if err != nil {
+			return fmt.Errorf("parsing project template: %w", err)
+		}
+		fileList = append(fileList, fn)
+		return nil
+	}
+
+	for _, name := range defaultNames {
+		var err error
+		tmpl, err = tmpl.ParseFS(defaultFS, name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing built-in template %q: %w", name, err)
+		}
+		fn := path.Join(Dir(tool), name)
+		if projectFile[fn] {
+			err := parseProject(fn)
+			if err != nil {
+				return nil, nil, err
+			}
+			delete(projectFile, fn)
+		}
+	}
+
+	for _, fn := range projectList {
+		if !projectFile[fn] {
+			continue // done above
+		}
+		if _, err := fs.Stat(defaultFS, path.Base(fn)); err == nil {
+			continue // copy of a built-in template which was not selected
+		}
+		err := parseProject(fn)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return tmpl, fileList, nil
+}
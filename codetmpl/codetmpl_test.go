@@ -0,0 +1,129 @@
+package codetmpl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/psanford/memfs"
+)
+
+func TestParse(t *testing.T) {
+
+	defaultFS := memfs.New()
+	must(t, defaultFS.WriteFile("example.tmpl", []byte(`
+{{define "A"}}built-in A{{end}}
+{{define "B"}}built-in B {{.}}{{end}}
+`), 0644))
+	must(t, defaultFS.WriteFile("example-other.tmpl", []byte(`{{define "A"}}other A{{end}}`), 0644))
+
+	type tcase struct {
+		name  string
+		names []string          // built-in files, example.tmpl if empty
+		files map[string]string // project files in the module
+		eA    string
+		eB    string
+		eerr  string
+		elen  int
+	}
+
+	tcaseList := []tcase{
+		{
+			name: "no_project",
+			eA:   "built-in A",
+			eB:   "built-in B x",
+		},
+		{
+			name: "override_one",
+			files: map[string]string{
+				".gocode/templates/example/b.tmpl": `{{define "B"}}project B {{upper .}}{{end}}`,
+				".gocode/templates/other/a.tmpl":   `{{define "A"}}other tool A{{end}}`,
+				".gocode/templates/example/a.txt":  `{{define "A"}}not a template file{{end}}`,
+				".gocode/templates/example/c.tmpl": `{{define "C"}}helper{{end}}`,
+			},
+			eA:   "built-in A",
+			eB:   "project B X",
+			elen: 2,
+		},
+		{
+			name: "parse_error",
+			files: map[string]string{
+				".gocode/templates/example/b.tmpl": "\n{{define \"B\"}}{{.Nope}{{end}}",
+			},
+			eerr: ".gocode/templates/example/b.tmpl:2",
+		},
+		{
+			name:  "set",
+			names: []string{"example.tmpl", "example-other.tmpl"},
+			eA:    "other A",
+			eB:    "built-in B x",
+		},
+		{
+			name: "unselected_set_copy",
+			files: map[string]string{
+				".gocode/templates/example/example-other.tmpl": `{{define "A"}}copy of other A{{end}}`,
+			},
+			eA: "built-in A",
+			eB: "built-in B x",
+		},
+		{
+			name:  "set_copies",
+			names: []string{"example.tmpl", "example-other.tmpl"},
+			files: map[string]string{
+				".gocode/templates/example/example.tmpl":       `{{define "A"}}project A{{end}}{{define "B"}}project B{{end}}`,
+				".gocode/templates/example/example-other.tmpl": `{{define "A"}}project other A{{end}}`,
+			},
+			eA:   "project other A",
+			eB:   "project B",
+			elen: 2,
+		},
+	}
+
+	for _, tc := range tcaseList {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+
+			moduleFS := memfs.New()
+			for fn, s := range tc.files {
+				must(t, moduleFS.MkdirAll(fn[:strings.LastIndex(fn, "/")], 0755))
+				must(t, moduleFS.WriteFile(fn, []byte(s), 0644))
+			}
+
+			names := tc.names
+			if len(names) == 0 {
+				names = []string{"example.tmpl"}
+			}
+			funcMap := template.FuncMap{"upper": strings.ToUpper}
+			tmpl, fileList, err := Parse(template.New("_main_").Funcs(funcMap), defaultFS, names, moduleFS, "example")
+			if tc.eerr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.eerr) {
+					t.Fatalf("expected error containing %q, got %v", tc.eerr, err)
+				}
+				return
+			}
+			must(t, err)
+			if len(fileList) != tc.elen {
+				t.Errorf("unexpected project file list: %v", fileList)
+			}
+
+			var buf bytes.Buffer
+			must(t, tmpl.ExecuteTemplate(&buf, "A", "x"))
+			if buf.String() != tc.eA {
+				t.Errorf("unexpected A output: %q", buf.String())
+			}
+			buf.Reset()
+			must(t, tmpl.ExecuteTemplate(&buf, "B", "x"))
+			if buf.String() != tc.eB {
+				t.Errorf("unexpected B output: %q", buf.String())
+			}
+		})
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,245 @@
+package codetmpl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/psanford/memfs"
+
+	"github.com/d0sbit/gocode/config"
+	"github.com/d0sbit/gocode/srcedit"
+	"github.com/d0sbit/gocode/srcedit/diff"
+)
+
+// InstalledName is the name of the file in Dir(tool) which records the built-in
+// templates that were installed there.
+const InstalledName = "installed.toml"
+
+// BaseSuffix is added to the name of an installed template for the file which has
+// the pristine built-in text it came from, e.g. "sqlcrud.tmpl.base".  Upgrade uses it
+// as the base of a three-way merge.
+const BaseSuffix = ".base"
+
+// Installed records the built-in templates installed into a project for a tool.
+type Installed struct {
+	Files []InstalledFile `toml:"file"`
+}
+
+// InstalledFile is one template file written by Install.
+type InstalledFile struct {
+	Name string `toml:"name"` // file name within Dir(tool), same as the built-in one
+	Hash string `toml:"hash"` // Hash of the built-in text it is based on, which is also in the BaseSuffix file
+}
+
+// File returns the record for the named file or nil if not found.
+func (in *Installed) File(name string) *InstalledFile {
+	for i := range in.Files {
+		if in.Files[i].Name == name {
+			return &in.Files[i]
+		}
+	}
+	return nil
+}
+
+// Hash returns the hash used to identify template text, e.g. "sha256:e3b0c442...".
+func Hash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// LoadInstalledFS reads the installed record for tool, returning an empty one if there is none.
+func LoadInstalledFS(moduleFS fs.FS, tool string) (*Installed, error) {
+	var ret Installed
+	b, err := fs.ReadFile(moduleFS, path.Join(Dir(tool), InstalledName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &ret, nil
+	} else if err != nil {
+		return nil, err
+	}
+	_, err = toml.Decode(string(b), &ret)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", InstalledName, err)
+	}
+	return &ret, nil
+}
+
+// InstallResult reports what Install did (or would do) with each file, by path within the module.
+type InstallResult struct {
+	Written    []string // new or changed files
+	Unchanged  []string // already the same as the built-in version
+	Customized []string // differ from what was last installed, only overwritten with force
+}
+
+// Install copies each built-in *.tmpl file in defaultFS into Dir(tool) in outFS and records
+// their hashes, keeping a copy of each in a BaseSuffix file.  Existing files are read from
+// inFS.  A file which was edited after it was installed (or was not installed by us) is
+// customized, and if there are any nothing is written and an error is returned unless
+// force is true.  outFS must implement config.FileWriter and config.MkdirAller.
+func Install(inFS, outFS, defaultFS fs.FS, tool string, force bool) (*InstallResult, error) {
+
+	fw, ok := outFS.(config.FileWriter)
+	if !ok {
+		return nil, fmt.Errorf("output filesystem does not implement FileWriter")
+	}
+	mda, ok := outFS.(config.MkdirAller)
+	if !ok {
+		return nil, fmt.Errorf("output filesystem does not implement MkdirAller")
+	}
+
+	nameList, err := fs.Glob(defaultFS, "*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	if len(nameList) == 0 {
+		return nil, fmt.Errorf("no built-in templates found for %s", tool)
+	}
+
+	installed, err := LoadInstalledFS(inFS, tool)
+	if err != nil {
+		return nil, err
+	}
+
+	var res InstallResult
+	files := make(map[string][]byte, len(nameList))
+	newInstalled := &Installed{}
+
+	for _, name := range nameList {
+		b, err := fs.ReadFile(defaultFS, name)
+		if err != nil {
+			return nil, err
+		}
+		p := path.Join(Dir(tool), name)
+		newInstalled.Files = append(newInstalled.Files, InstalledFile{Name: name, Hash: Hash(b)})
+		files[p+BaseSuffix] = b
+
+		cur, err := fs.ReadFile(inFS, p)
+		if errors.Is(err, fs.ErrNotExist) {
+			res.Written = append(res.Written, p)
+			files[p] = b
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch rec := installed.File(name); {
+		case bytes.Equal(cur, b):
+			res.Unchanged = append(res.Unchanged, p)
+		case rec == nil || rec.Hash != Hash(cur):
+			res.Customized = append(res.Customized, p)
+			files[p] = b
+		default: // as we installed it, safe to update
+			res.Written = append(res.Written, p)
+			files[p] = b
+		}
+	}
+
+	if len(res.Customized) > 0 && !force {
+		return &res, fmt.Errorf("not overwriting customized templates (use -force to overwrite): %s", strings.Join(res.Customized, ", "))
+	}
+
+	err = writeFiles(fw, mda, tool, newInstalled, files)
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// writeFiles writes installed and files (path to contents) to Dir(tool).
+func writeFiles(fw config.FileWriter, mda config.MkdirAller, tool string, installed *Installed, files map[string][]byte) error {
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Built-in %s templates installed by `gocode %s -install-templates`, do not edit.\n", tool, tool)
+	fmt.Fprintf(&buf, "# A template whose hash no longer matches has been customized.\n\n")
+	err := toml.NewEncoder(&buf).Encode(installed)
+	if err != nil {
+		return err
+	}
+	files[path.Join(Dir(tool), InstalledName)] = buf.Bytes()
+
+	err = mda.MkdirAll(Dir(tool), 0755)
+	if err != nil {
+		return err
+	}
+	pathList := make([]string, 0, len(files))
+	for p := range files {
+		pathList = append(pathList, p)
+	}
+	sort.Strings(pathList)
+	for _, p := range pathList {
+		err := fw.WriteFile(p, files[p], 0644)
+		if err != nil {
+			return fmt.Errorf("writing %q: %w", p, err)
+		}
+	}
+
+	return nil
+}
+
+// RunInstall implements -install-templates for a tool, installing into the module the working
+// directory is in.  If dryRun is not "off" nothing is written and the diff is written to
+// stdout in that format (as JSON if jsonOut), the same as a tool's dry run.
+func RunInstall(defaultFS fs.FS, tool string, force bool, dryRun string, jsonOut bool, stdout io.Writer) (res *InstallResult, err error) {
+	err = runModule(dryRun, jsonOut, stdout, func(inFS, outFS fs.FS) error {
+		res, err = Install(inFS, outFS, defaultFS, tool, force)
+		return err
+	})
+	return res, err
+}
+
+// runModule calls fn with the module the working directory is in as inFS, and outFS either
+// the same or, for a dry run, in memory with the diff written to stdout after.
+func runModule(dryRun string, jsonOut bool, stdout io.Writer, fn func(inFS, outFS fs.FS) error) error {
+
+	rootFS, modDir, _, _, err := srcedit.FindOSWdModuleDir("")
+	if err != nil {
+		return fmt.Errorf("error finding module directory: %w", err)
+	}
+	inFS, err := fs.Sub(rootFS, modDir)
+	if err != nil {
+		return fmt.Errorf("fs.Sub error while construct input fs: %w", err)
+	}
+
+	if dryRun == "off" {
+		return fn(inFS, inFS)
+	}
+
+	outFS := memfs.New()
+	err = fn(inFS, outFS)
+	if err != nil {
+		return err
+	}
+
+	diffMap, err := diff.Run(inFS, outFS, ".", dryRun)
+	if err != nil {
+		return fmt.Errorf("error running diff: %w", err)
+	}
+	if jsonOut {
+		enc := json.NewEncoder(stdout)
+		enc.Encode(map[string]interface{}{
+			"diff": diffMap,
+		})
+	} else {
+		klist := make([]string, 0, len(diffMap))
+		for k := range diffMap {
+			klist = append(klist, k)
+		}
+		sort.Strings(klist)
+		for _, k := range klist {
+			fmt.Fprintf(stdout, "### %s\n", k)
+			fmt.Fprintln(stdout, diffMap[k])
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package codetmpl
+
+import (
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/psanford/memfs"
+)
+
+func TestInstall(t *testing.T) {
+
+	const p = ".gocode/templates/example/example.tmpl"
+
+	defaultFS := memfs.New()
+	must(t, defaultFS.WriteFile("example.tmpl", []byte(`{{define "A"}}v1{{end}}`), 0644))
+	moduleFS := memfs.New()
+
+	// fresh install
+	res, err := Install(moduleFS, moduleFS, defaultFS, "example", false)
+	must(t, err)
+	if !reflect.DeepEqual(res.Written, []string{p}) {
+		t.Errorf("unexpected result: %#v", res)
+	}
+	installed, err := LoadInstalledFS(moduleFS, "example")
+	must(t, err)
+	if f := installed.File("example.tmpl"); f == nil || f.Hash != Hash([]byte(`{{define "A"}}v1{{end}}`)) {
+		t.Errorf("unexpected installed record: %#v", installed)
+	}
+
+	// again, nothing to do
+	res, err = Install(moduleFS, moduleFS, defaultFS, "example", false)
+	must(t, err)
+	if !reflect.DeepEqual(res.Unchanged, []string{p}) || len(res.Written) != 0 {
+		t.Errorf("unexpected result: %#v", res)
+	}
+
+	// new built-in version replaces a copy that was not edited
+	must(t, defaultFS.WriteFile("example.tmpl", []byte(`{{define "A"}}v2{{end}}`), 0644))
+	res, err = Install(moduleFS, moduleFS, defaultFS, "example", false)
+	must(t, err)
+	if !reflect.DeepEqual(res.Written, []string{p}) {
+		t.Errorf("unexpected result: %#v", res)
+	}
+
+	// customized copy is left alone unless forced
+	must(t, moduleFS.WriteFile(p, []byte(`{{define "A"}}mine{{end}}`), 0644))
+	must(t, defaultFS.WriteFile("example.tmpl", []byte(`{{define "A"}}v3{{end}}`), 0644))
+	res, err = Install(moduleFS, moduleFS, defaultFS, "example", false)
+	if err == nil || !strings.Contains(err.Error(), p) {
+		t.Fatalf("expected error for customized template, got %v", err)
+	}
+	b, err := fs.ReadFile(moduleFS, p)
+	must(t, err)
+	if string(b) != `{{define "A"}}mine{{end}}` {
+		t.Errorf("customized template was overwritten: %s", b)
+	}
+
+	// dry run style, output to a separate fs
+	outFS := memfs.New()
+	res, err = Install(moduleFS, outFS, defaultFS, "example", true)
+	must(t, err)
+	if !reflect.DeepEqual(res.Customized, []string{p}) {
+		t.Errorf("unexpected result: %#v", res)
+	}
+	b, err = fs.ReadFile(outFS, p)
+	must(t, err)
+	if string(b) != `{{define "A"}}v3{{end}}` {
+		t.Errorf("unexpected output: %s", b)
+	}
+	b, err = fs.ReadFile(moduleFS, p)
+	must(t, err)
+	if string(b) != `{{define "A"}}mine{{end}}` {
+		t.Errorf("input was modified: %s", b)
+	}
+}
@@ -0,0 +1,147 @@
+package codetmpl
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Conflict markers written by merge3, in the same form as git.
+const (
+	conflictStart = "<<<<<<< project"
+	conflictSep   = "======="
+	conflictEnd   = ">>>>>>> built-in"
+)
+
+// splitLines splits s into lines, each one keeping its trailing newline.
+func splitLines(s string) []string {
+	var ret []string
+	for s != "" {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			ret = append(ret, s)
+			break
+		}
+		ret = append(ret, s[:i+1])
+		s = s[i+1:]
+	}
+	return ret
+}
+
+// matchLines returns, for each line in from, the index of the line in to it was
+// matched with by a line diff, or -1 if it was removed.
+func matchLines(from, to []string) []int {
+
+	// diff with each distinct line as a single rune
+	runeOf := make(map[string]rune)
+	toRunes := func(lines []string) []rune {
+		ret := make([]rune, len(lines))
+		for i, l := range lines {
+			r, ok := runeOf[l]
+			if !ok {
+				r = rune(0x100 + len(runeOf))
+				if r >= 0xD800 { // skip surrogates, which are not valid runes
+					r += 0x800
+				}
+				runeOf[l] = r
+			}
+			ret[i] = r
+		}
+		return ret
+	}
+
+	dmp := diffmatchpatch.New()
+	dmp.DiffTimeout = 0 // an exact diff matters more than speed here
+	diffs := dmp.DiffMainRunes(toRunes(from), toRunes(to), false)
+
+	ret := make([]int, 0, len(from))
+	j := 0
+	for _, d := range diffs {
+		n := utf8.RuneCountInString(d.Text)
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			for k := 0; k < n; k++ {
+				ret = append(ret, j)
+				j++
+			}
+		case diffmatchpatch.DiffDelete:
+			for k := 0; k < n; k++ {
+				ret = append(ret, -1)
+			}
+		case diffmatchpatch.DiffInsert:
+			j += n
+		}
+	}
+	return ret
+}
+
+// merge3 does a line based three-way merge of the changes from base to ours and from base
+// to theirs.  Where both changed the same lines differently, both versions are included
+// between conflict markers and counted in conflicts.
+func merge3(base, ours, theirs string) (merged string, conflicts int) {
+
+	o, a, b := splitLines(base), splitLines(ours), splitLines(theirs)
+	ma, mb := matchLines(o, a), matchLines(o, b)
+
+	var buf strings.Builder
+	writeLines := func(lines []string) {
+		for _, l := range lines {
+			buf.WriteString(l)
+		}
+	}
+
+	// chunk outputs a section where base, ours and theirs are not in sync
+	chunk := func(oc, ac, bc []string) {
+		oj, aj, bj := strings.Join(oc, ""), strings.Join(ac, ""), strings.Join(bc, "")
+		switch {
+		case aj == oj || aj == bj:
+			writeLines(bc)
+		case bj == oj:
+			writeLines(ac)
+		default:
+			conflicts++
+			// markers need to start on a line of their own
+			buf.WriteString(conflictStart + "\n")
+			buf.WriteString(withNewline(aj))
+			buf.WriteString(conflictSep + "\n")
+			buf.WriteString(withNewline(bj))
+			buf.WriteString(conflictEnd + "\n")
+		}
+	}
+
+	i, ai, bi := 0, 0, 0
+	for {
+		// lines which are unchanged in both
+		k := 0
+		for i+k < len(o) && ma[i+k] == ai+k && mb[i+k] == bi+k {
+			k++
+		}
+		if k > 0 {
+			writeLines(o[i : i+k])
+			i, ai, bi = i+k, ai+k, bi+k
+			continue
+		}
+
+		// find the next base line that is in both, and everything before it is one chunk
+		j := i
+		for j < len(o) && (ma[j] < 0 || mb[j] < 0) {
+			j++
+		}
+		if j == len(o) {
+			chunk(o[i:], a[ai:], b[bi:])
+			break
+		}
+		chunk(o[i:j], a[ai:ma[j]], b[bi:mb[j]])
+		i, ai, bi = j, ma[j], mb[j]
+	}
+
+	return buf.String(), conflicts
+}
+
+func withNewline(s string) string {
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		return s + "\n"
+	}
+	return s
+}
@@ -0,0 +1,82 @@
+package codetmpl
+
+import "testing"
+
+func TestMerge3(t *testing.T) {
+
+	type tcase struct {
+		name       string
+		base       string
+		ours       string
+		theirs     string
+		emerged    string
+		econflicts int
+	}
+
+	tcaseList := []tcase{
+		{
+			name:    "no_changes",
+			base:    "a\nb\nc\n",
+			ours:    "a\nb\nc\n",
+			theirs:  "a\nb\nc\n",
+			emerged: "a\nb\nc\n",
+		},
+		{
+			name:    "theirs_only",
+			base:    "a\nb\nc\n",
+			ours:    "a\nb\nc\n",
+			theirs:  "a\nB\nc\nd\n",
+			emerged: "a\nB\nc\nd\n",
+		},
+		{
+			name:    "both_different_places",
+			base:    "a\nb\nc\nd\ne\n",
+			ours:    "a\nours\nc\nd\ne\n",
+			theirs:  "a\nb\nc\nd\ntheirs\nf\n",
+			emerged: "a\nours\nc\nd\ntheirs\nf\n",
+		},
+		{
+			name:    "both_insert_at_start_same",
+			base:    "a\nb\n",
+			ours:    "x\na\nb\n",
+			theirs:  "x\na\nb\n",
+			emerged: "x\na\nb\n",
+		},
+		{
+			name:    "ours_delete_theirs_append",
+			base:    "a\nb\nc\n",
+			ours:    "a\nc\n",
+			theirs:  "a\nb\nc\nd\n",
+			emerged: "a\nc\nd\n",
+		},
+		{
+			name:       "conflict",
+			base:       "a\nb\nc\n",
+			ours:       "a\nmine\nc\n",
+			theirs:     "a\nnew\nc\n",
+			emerged:    "a\n<<<<<<< project\nmine\n=======\nnew\n>>>>>>> built-in\nc\n",
+			econflicts: 1,
+		},
+		{
+			name:       "conflict_no_trailing_newline",
+			base:       "a\nb",
+			ours:       "a\nmine",
+			theirs:     "a\nnew",
+			emerged:    "a\n<<<<<<< project\nmine\n=======\nnew\n>>>>>>> built-in\n",
+			econflicts: 1,
+		},
+	}
+
+	for _, tc := range tcaseList {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			merged, conflicts := merge3(tc.base, tc.ours, tc.theirs)
+			if merged != tc.emerged {
+				t.Errorf("unexpected merge result:\n%s\nexpected:\n%s", merged, tc.emerged)
+			}
+			if conflicts != tc.econflicts {
+				t.Errorf("expected %d conflicts, got %d", tc.econflicts, conflicts)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package codetmpl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+
+	"github.com/d0sbit/gocode/config"
+)
+
+// UpgradeResult reports what Upgrade did (or would do) with each file, by path within the module.
+type UpgradeResult struct {
+	Updated   []string // not customized, replaced with the new built-in version
+	Merged    []string // customized, changes to the built-in version merged in
+	Conflicts []string // customized, merged but with conflicts marked that need to be resolved
+	Unchanged []string // built-in version is the same as when it was installed
+	Skipped   []string // customized but there is nothing to merge from, or no longer built-in
+}
+
+// Upgrade brings the templates installed by Install up to date with the built-in ones in defaultFS.
+// Templates which have not been customized are replaced.  For customized ones, the changes
+// between the built-in version they were based on (kept in the BaseSuffix file) and the new
+// one are merged into the project copy, with conflict markers where both changed the same
+// lines.  Existing files are read from inFS and written to outFS, which must implement
+// config.FileWriter and config.MkdirAller.
+func Upgrade(inFS, outFS, defaultFS fs.FS, tool string) (*UpgradeResult, error) {
+
+	fw, ok := outFS.(config.FileWriter)
+	if !ok {
+		return nil, fmt.Errorf("output filesystem does not implement FileWriter")
+	}
+	mda, ok := outFS.(config.MkdirAller)
+	if !ok {
+		return nil, fmt.Errorf("output filesystem does not implement MkdirAller")
+	}
+
+	installed, err := LoadInstalledFS(inFS, tool)
+	if err != nil {
+		return nil, err
+	}
+	if len(installed.Files) == 0 {
+		return nil, fmt.Errorf("no templates installed in %s (see -install-templates)", Dir(tool))
+	}
+
+	var res UpgradeResult
+	files := make(map[string][]byte)
+
+	for i := range installed.Files {
+		rec := &installed.Files[i]
+		p := path.Join(Dir(tool), rec.Name)
+
+		newb, err := fs.ReadFile(defaultFS, rec.Name)
+		if errors.Is(err, fs.ErrNotExist) {
+			res.Skipped = append(res.Skipped, p)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if Hash(newb) == rec.Hash {
+			res.Unchanged = append(res.Unchanged, p)
+			continue
+		}
+
+		cur, err := fs.ReadFile(inFS, p)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // removed from the project, leave it that way
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch base, err := fs.ReadFile(inFS, p+BaseSuffix); {
+		case Hash(cur) == rec.Hash:
+			res.Updated = append(res.Updated, p)
+			files[p] = newb
+		case err != nil || Hash(base) != rec.Hash:
+			// without the exact text it was based on a merge would be guesswork
+			res.Skipped = append(res.Skipped, p)
+			continue
+		default:
+			merged, conflicts := merge3(string(base), string(cur), string(newb))
+			if conflicts > 0 {
+				res.Conflicts = append(res.Conflicts, p)
+			} else {
+				res.Merged = append(res.Merged, p)
+			}
+			if merged != string(cur) {
+				files[p] = []byte(merged)
+			}
+		}
+
+		rec.Hash = Hash(newb)
+		files[p+BaseSuffix] = newb
+	}
+
+	if len(files) == 0 {
+		return &res, nil
+	}
+
+	err = writeFiles(fw, mda, tool, installed, files)
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// RunUpgrade implements -upgrade-templates for a tool, upgrading the templates in the module the
+// working directory is in.  dryRun, jsonOut and stdout are the same as for RunInstall.
+func RunUpgrade(defaultFS fs.FS, tool string, dryRun string, jsonOut bool, stdout io.Writer) (res *UpgradeResult, err error) {
+	err = runModule(dryRun, jsonOut, stdout, func(inFS, outFS fs.FS) error {
+		res, err = Upgrade(inFS, outFS, defaultFS, tool)
+		return err
+	})
+	return res, err
+}
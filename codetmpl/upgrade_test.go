@@ -0,0 +1,76 @@
+package codetmpl
+
+import (
+	"io/fs"
+	"reflect"
+	"testing"
+
+	"github.com/psanford/memfs"
+)
+
+func TestUpgrade(t *testing.T) {
+
+	const (
+		pa = ".gocode/templates/example/a.tmpl"
+		pb = ".gocode/templates/example/b.tmpl"
+	)
+
+	defaultFS := memfs.New()
+	must(t, defaultFS.WriteFile("a.tmpl", []byte("{{define \"A\"}}\nline 1\nline 2\nline 3\n{{end}}\n"), 0644))
+	must(t, defaultFS.WriteFile("b.tmpl", []byte("{{define \"B\"}}\nb\n{{end}}\n"), 0644))
+
+	moduleFS := memfs.New()
+	_, err := Install(moduleFS, moduleFS, defaultFS, "example", false)
+	must(t, err)
+
+	// customize a.tmpl, leave b.tmpl as-is, then gocode is upgraded and both change
+	must(t, moduleFS.WriteFile(pa, []byte("{{define \"A\"}}\nline 1\nproject line 2\nline 3\n{{end}}\n"), 0644))
+	must(t, defaultFS.WriteFile("a.tmpl", []byte("{{define \"A\"}}\nline 1\nline 2\nline 3\nnew line 4\n{{end}}\n"), 0644))
+	must(t, defaultFS.WriteFile("b.tmpl", []byte("{{define \"B\"}}\nnew b\n{{end}}\n"), 0644))
+
+	// dry run style first, nothing changes in the module
+	outFS := memfs.New()
+	res, err := Upgrade(moduleFS, outFS, defaultFS, "example")
+	must(t, err)
+	if !reflect.DeepEqual(res.Merged, []string{pa}) || !reflect.DeepEqual(res.Updated, []string{pb}) {
+		t.Errorf("unexpected result: %#v", res)
+	}
+	b, err := fs.ReadFile(moduleFS, pa)
+	must(t, err)
+	if string(b) != "{{define \"A\"}}\nline 1\nproject line 2\nline 3\n{{end}}\n" {
+		t.Errorf("input was modified: %s", b)
+	}
+
+	res, err = Upgrade(moduleFS, moduleFS, defaultFS, "example")
+	must(t, err)
+	b, err = fs.ReadFile(moduleFS, pa)
+	must(t, err)
+	if string(b) != "{{define \"A\"}}\nline 1\nproject line 2\nline 3\nnew line 4\n{{end}}\n" {
+		t.Errorf("unexpected merge result: %s", b)
+	}
+	b, err = fs.ReadFile(moduleFS, pb)
+	must(t, err)
+	if string(b) != "{{define \"B\"}}\nnew b\n{{end}}\n" {
+		t.Errorf("unexpected update result: %s", b)
+	}
+
+	// nothing more to do
+	res, err = Upgrade(moduleFS, moduleFS, defaultFS, "example")
+	must(t, err)
+	if len(res.Unchanged) != 2 {
+		t.Errorf("unexpected result: %#v", res)
+	}
+
+	// both change the same line
+	must(t, defaultFS.WriteFile("a.tmpl", []byte("{{define \"A\"}}\nline 1\nnew line 2\nline 3\nnew line 4\n{{end}}\n"), 0644))
+	res, err = Upgrade(moduleFS, moduleFS, defaultFS, "example")
+	must(t, err)
+	if !reflect.DeepEqual(res.Conflicts, []string{pa}) {
+		t.Errorf("unexpected result: %#v", res)
+	}
+	b, err = fs.ReadFile(moduleFS, pa)
+	must(t, err)
+	if string(b) != "{{define \"A\"}}\nline 1\n<<<<<<< project\nproject line 2\n=======\nnew line 2\n>>>>>>> built-in\nline 3\nnew line 4\n{{end}}\n" {
+		t.Errorf("unexpected conflict result: %s", b)
+	}
+}
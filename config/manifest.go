@@ -0,0 +1,137 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ManifestPath is where the manifest is stored, relative to the module root.
+const ManifestPath = ".gocode/manifest.toml"
+
+// Manifest records each successful generator run so it can be run again later by `gocode regen`.
+type Manifest struct {
+	Entries []ManifestEntry `toml:"entry"`
+}
+
+// ManifestEntry is one generator run.
+type ManifestEntry struct {
+	Tool    string            `toml:"tool" json:"tool"`       // e.g. "sqlcrud"
+	Type    string            `toml:"type" json:"type"`       // Go type code was generated for
+	Package string            `toml:"package" json:"package"` // package directory relative to the module root
+	Files   []string          `toml:"files" json:"files"`     // files written, relative to the module root
+	Flags   map[string]string `toml:"flags" json:"flags"`     // flags to run the tool with again, all paths relative to the module root
+	Args    []string          `toml:"args" json:"args"`       // positional args to run the tool with again
+}
+
+// CommandArgs returns the command line arguments to run this entry's tool with, flags are sorted by name.
+func (e *ManifestEntry) CommandArgs() []string {
+	names := make([]string, 0, len(e.Flags))
+	for name := range e.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ret := make([]string, 0, len(names)+len(e.Args))
+	for _, name := range names {
+		ret = append(ret, "-"+name+"="+e.Flags[name])
+	}
+	return append(ret, e.Args...)
+}
+
+// Record adds e to the manifest, replacing any entry for the same tool, package and type.
+func (m *Manifest) Record(e ManifestEntry) {
+	for i := range m.Entries {
+		me := &m.Entries[i]
+		if me.Tool == e.Tool && me.Package == e.Package && me.Type == e.Type {
+			*me = e
+			return
+		}
+	}
+	m.Entries = append(m.Entries, e)
+}
+
+// Remove removes the entry for the given tool, package and type, if there is one.
+func (m *Manifest) Remove(tool, pkg, typeName string) {
+	for i, me := range m.Entries {
+		if me.Tool == tool && me.Package == pkg && me.Type == typeName {
+			m.Entries = append(m.Entries[:i], m.Entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// Find returns the entries for the given tool and type, empty string matches any.
+func (m *Manifest) Find(tool, typeName string) []ManifestEntry {
+	var ret []ManifestEntry
+	for _, e := range m.Entries {
+		if tool != "" && e.Tool != tool {
+			continue
+		}
+		if typeName != "" && e.Type != typeName {
+			continue
+		}
+		ret = append(ret, e)
+	}
+	return ret
+}
+
+// LoadManifestFS reads the manifest from .gocode/manifest.toml, returning
+// an empty manifest if the file does not exist.
+func LoadManifestFS(moduleFS fs.FS) (*Manifest, error) {
+	var m Manifest
+	b, err := fs.ReadFile(moduleFS, ManifestPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &m, nil
+		}
+		return nil, err
+	}
+	err = toml.Unmarshal(b, &m)
+	return &m, err
+}
+
+// StoreManifestFS writes the manifest to .gocode/manifest.toml.
+// The filesystem must implement FileWriter and MkdirAller.
+func StoreManifestFS(moduleFS fs.FS, m *Manifest) error {
+	fw, ok := moduleFS.(wfs)
+	if !ok {
+		return errors.New("moduleFS must implement FileWriter and MkdirAller")
+	}
+
+	err := fw.MkdirAll(".gocode", 0755)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	err = toml.NewEncoder(&buf).Encode(m)
+	if err != nil {
+		return err
+	}
+
+	return fw.WriteFile(ManifestPath, buf.Bytes(), 0644)
+}
+
+// RecordRunFS loads the manifest, records e in it and writes it back.
+func RecordRunFS(moduleFS fs.FS, e ManifestEntry) error {
+	m, err := LoadManifestFS(moduleFS)
+	if err != nil {
+		return err
+	}
+	m.Record(e)
+	return StoreManifestFS(moduleFS, m)
+}
+
+// RemoveRunFS loads the manifest, removes the entry for the given tool, package and type, and writes it back.
+func RemoveRunFS(moduleFS fs.FS, tool, pkg, typeName string) error {
+	m, err := LoadManifestFS(moduleFS)
+	if err != nil {
+		return err
+	}
+	m.Remove(tool, pkg, typeName)
+	return StoreManifestFS(moduleFS, m)
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"io/fs"
+	"reflect"
+	"testing"
+
+	"github.com/psanford/memfs"
+)
+
+func TestManifest(t *testing.T) {
+
+	mfs := memfs.New()
+
+	m, err := LoadManifestFS(mfs)
+	must(t, err)
+	if len(m.Entries) != 0 {
+		t.Fatalf("expected empty manifest, got %#v", m)
+	}
+
+	must(t, RecordRunFS(mfs, ManifestEntry{
+		Tool: "sqlcrud", Type: "Widget", Package: "store",
+		Files: []string{"store/widget.go"},
+		Flags: map[string]string{"type": "Widget", "package": "store", "no-gofmt": "true"},
+	}))
+	must(t, RecordRunFS(mfs, ManifestEntry{
+		Tool: "handlercrud", Type: "Widget", Package: "handlers",
+		Files: []string{"handlers/widget.go"},
+		Args:  []string{"handlers/widget.go"},
+	}))
+	// same tool, package and type replaces the first entry
+	must(t, RecordRunFS(mfs, ManifestEntry{
+		Tool: "sqlcrud", Type: "Widget", Package: "store",
+		Files: []string{"store/widget.go", "store/widget_test.go"},
+		Flags: map[string]string{"type": "Widget", "package": "store"},
+	}))
+
+	b, err := fs.ReadFile(mfs, ManifestPath)
+	must(t, err)
+	t.Logf("manifest:\n%s", b)
+
+	m, err = LoadManifestFS(mfs)
+	must(t, err)
+	if len(m.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %#v", m.Entries)
+	}
+	if !reflect.DeepEqual(m.Entries[0].Files, []string{"store/widget.go", "store/widget_test.go"}) {
+		t.Errorf("unexpected files: %v", m.Entries[0].Files)
+	}
+	if args := m.Entries[0].CommandArgs(); !reflect.DeepEqual(args, []string{"-package=store", "-type=Widget"}) {
+		t.Errorf("unexpected args: %q", args)
+	}
+	if args := m.Entries[1].CommandArgs(); !reflect.DeepEqual(args, []string{"handlers/widget.go"}) {
+		t.Errorf("unexpected args: %q", args)
+	}
+
+	if l := m.Find("handlercrud", ""); len(l) != 1 || l[0].Package != "handlers" {
+		t.Errorf("unexpected Find result: %#v", l)
+	}
+	if l := m.Find("", "Widget"); len(l) != 2 {
+		t.Errorf("unexpected Find result: %#v", l)
+	}
+	if l := m.Find("", "Other"); len(l) != 0 {
+		t.Errorf("unexpected Find result: %#v", l)
+	}
+
+	must(t, RemoveRunFS(mfs, "sqlcrud", "store", "Widget"))
+	m, err = LoadManifestFS(mfs)
+	must(t, err)
+	if len(m.Entries) != 1 || m.Entries[0].Tool != "handlercrud" {
+		t.Errorf("unexpected entries after RemoveRunFS: %#v", m.Entries)
+	}
+}
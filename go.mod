@@ -3,10 +3,9 @@ module github.com/d0sbit/gocode
 go 1.16
 
 require (
-	github.com/BurntSushi/toml v0.4.1 // indirect
+	github.com/BurntSushi/toml v0.4.1
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/psanford/memfs v0.0.0-20210214183328-a001468d78ef
-	github.com/pterm/pterm v0.12.31 // indirect
 	github.com/sergi/go-diff v1.2.0
 	golang.org/x/mod v0.4.2
 )
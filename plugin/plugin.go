@@ -0,0 +1,285 @@
+// Package plugin implements the protocol gocode uses to run generators that live outside
+// of this repository.
+//
+// A plugin is an executable named gocodeplugin_<name> found next to the gocode executable
+// or on the PATH.  `gocode <name> -type X -package dir` finds the type, sends a Request
+// describing it to the plugin as JSON on stdin, reads a Response as JSON from stdout and
+// applies the transforms in it with srcedit, the same way the built-in tools apply their
+// templates.  Dry runs (-dry-run), -replace, the generation manifest and `gocode regen`
+// all work the same as for the built-in tools.  Anything the plugin writes to stderr is
+// shown to the user.
+//
+// Each invocation of the plugin handles exactly one request, which has an Op of either:
+//
+// "describe" - the response has Describe set to a codeflag.Description with the plugin's
+// one line description, any extra flags it accepts and example command lines.  These flags
+// are added to the ones gocode handles itself (type, package, file, dry-run, etc.) and their
+// values are sent in Request.Flags.
+//
+// "generate" - the request has the module, package, type and config filled in and the
+// response has the list of Transforms to apply.
+//
+// A response with Error set indicates failure, and the message is shown to the user.
+//
+// Transforms are JSON objects with a "kind" which is one of "import", "func", "type",
+// "const", "var", "file" or "section" (see Transform for the fields used by each).  The "file"
+// name is relative to the package directory and must not contain a directory.  A "file"
+// transform with a .go name has the full source of a Go file, which is merged into the
+// existing file (or creates it) as if each declaration had been given separately.  Other
+// files are written as-is, but only if they do not exist yet unless "replace" is set.  A
+// "section" is a named block of lines in a non-Go file, e.g. a README, between comment lines
+// marking it, which is added once and only updated when "replace" is set.
+//
+// Plugins written in Go can use Main to handle the protocol.
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/d0sbit/gocode/codeflag"
+	"github.com/d0sbit/gocode/srcedit"
+	"github.com/d0sbit/gocode/srcedit/model"
+)
+
+// Protocol is the version of the protocol described here.  It is sent in each
+// request and a plugin should fail if it is greater than what it understands.
+const Protocol = 1
+
+// Prefix is what plugin executable names start with, e.g. "gocodeplugin_example" is the "example" plugin.
+const Prefix = "gocodeplugin_"
+
+// Request is sent to the plugin on stdin.
+type Request struct {
+	Protocol    int                    `json:"protocol"`               // see Protocol
+	Op          string                 `json:"op"`                     // "describe" or "generate"
+	ModulePath  string                 `json:"module_path,omitempty"`  // from go.mod, e.g. "github.com/example/project"
+	Package     string                 `json:"package,omitempty"`      // package directory relative to the module root, "" for the root
+	PackageName string                 `json:"package_name,omitempty"` // name from the package clause
+	ImportPath  string                 `json:"import_path,omitempty"`  // full import path of the package
+	File        string                 `json:"file,omitempty"`         // file name for the main generated code, from -file or based on the type name
+	Type        *Struct                `json:"type,omitempty"`         // the type to generate code for
+	Flags       map[string]string      `json:"flags,omitempty"`        // values for the flags the plugin declared in its description
+	Config      map[string]interface{} `json:"config,omitempty"`       // settings from .gocode/gocode.toml
+}
+
+// Struct describes a Go struct type.
+type Struct struct {
+	Name       string  `json:"name"`
+	Fields     []Field `json:"fields"`
+	PKAutoIncr bool    `json:"pk_auto_incr,omitempty"` // see model.Struct.IsPKAutoIncr
+}
+
+// Field is a field of a Struct.
+type Field struct {
+	Name string              `json:"name"`           // Go field name
+	Type string              `json:"type"`           // Go type expression, e.g. "*int64"
+	Tag  string              `json:"tag,omitempty"`  // struct tag, e.g. `db:"id"`
+	Tags map[string][]string `json:"tags,omitempty"` // struct tag keys with values split on commas, e.g. {"json": ["id", "omitempty"]}
+	PK   bool                `json:"pk,omitempty"`   // part of the primary key (see "Primary Keys" in the README)
+}
+
+// NewStruct returns the protocol representation of s.
+func NewStruct(s *model.Struct) *Struct {
+	ret := &Struct{
+		Name:       s.LocalName(),
+		Fields:     []Field{},
+		PKAutoIncr: s.IsPKAutoIncr(),
+	}
+	for _, sf := range s.FieldList() {
+		ret.Fields = append(ret.Fields, Field{
+			Name: sf.GoName(),
+			Type: sf.GoTypeExpr(),
+			Tag:  sf.Tag(),
+			Tags: sf.TagParts(),
+			PK:   sf.IsPK(),
+		})
+	}
+	return ret
+}
+
+// Response is written by the plugin to stdout.
+type Response struct {
+	Describe   *codeflag.Description `json:"describe,omitempty"`   // for "describe"
+	Transforms []Transform           `json:"transforms,omitempty"` // for "generate"
+	Error      string                `json:"error,omitempty"`      // if not empty the request failed
+}
+
+// Transform is a change to make to the package.  Which fields are used depends on Kind.
+type Transform struct {
+	Kind       string   `json:"kind"`                  // "import", "func", "type", "const", "var", "file" or "section"
+	File       string   `json:"file"`                  // file name in the package directory
+	Name       string   `json:"name,omitempty"`        // import: local name ("" for none); func, type and section: the name
+	Path       string   `json:"path,omitempty"`        // import: the import path
+	Receiver   string   `json:"receiver,omitempty"`    // func: receiver type for methods, e.g. "*Widget"
+	Names      []string `json:"names,omitempty"`       // const and var: the names declared
+	Text       string   `json:"text,omitempty"`        // func, type, const, var: declaration including doc comment; file: the whole file; section: the lines in it
+	Replace    bool     `json:"replace,omitempty"`     // replace any existing declaration (or file or section) instead of leaving it as-is
+	Anchor     string   `json:"anchor,omitempty"`      // func, type, const, var: where a new declaration goes, "after", "before" or "with-receiver", see srcedit.DeclAnchor
+	AnchorName string   `json:"anchor_name,omitempty"` // for "after" and "before", the declaration, e.g. "NewWidget" or "(*Widget).Save"
+}
+
+// Transforms converts the transforms from a plugin to srcedit transforms.
+func Transforms(list []Transform) (trs []srcedit.Transform, err error) {
+
+	for i, t := range list {
+
+		if t.File == "" || t.File != path.Base(t.File) || t.File == "." || t.File == ".." || strings.Contains(t.File, `\`) {
+			return nil, fmt.Errorf("transform %d: invalid file name %q, must be a file in the package directory", i, t.File)
+		}
+		if t.Kind == "section" && strings.HasSuffix(t.File, ".go") {
+			return nil, fmt.Errorf("transform %d: section transform for Go file %q", i, t.File)
+		}
+		if t.Kind != "file" && t.Kind != "section" && !strings.HasSuffix(t.File, ".go") {
+			return nil, fmt.Errorf("transform %d: %s transform for non-Go file %q", i, t.Kind, t.File)
+		}
+
+		switch t.Kind {
+
+		case "import":
+			if t.Path == "" {
+				return nil, fmt.Errorf("transform %d: import with no path", i)
+			}
+			trs = append(trs, &srcedit.ImportTransform{Filename: t.File, Name: t.Name, Path: t.Path})
+
+		case "func":
+			if t.Name == "" {
+				return nil, fmt.Errorf("transform %d: func with no name", i)
+			}
+			trs = append(trs, &srcedit.AddFuncDeclTransform{Filename: t.File, Name: t.Name, ReceiverType: t.Receiver, Text: t.Text, Replace: t.Replace, Anchor: srcedit.DeclAnchor(t.Anchor), AnchorName: t.AnchorName})
+
+		case "type":
+			if t.Name == "" {
+				return nil, fmt.Errorf("transform %d: type with no name", i)
+			}
+			trs = append(trs, &srcedit.AddTypeDeclTransform{Filename: t.File, Name: t.Name, Text: t.Text, Replace: t.Replace, Anchor: srcedit.DeclAnchor(t.Anchor), AnchorName: t.AnchorName})
+
+		case "const":
+			if len(t.Names) == 0 {
+				return nil, fmt.Errorf("transform %d: const with no names", i)
+			}
+			trs = append(trs, &srcedit.AddConstDeclTransform{Filename: t.File, NameList: t.Names, Text: t.Text, Replace: t.Replace, Anchor: srcedit.DeclAnchor(t.Anchor), AnchorName: t.AnchorName})
+
+		case "var":
+			if len(t.Names) == 0 {
+				return nil, fmt.Errorf("transform %d: var with no names", i)
+			}
+			trs = append(trs, &srcedit.AddVarDeclTransform{Filename: t.File, NameList: t.Names, Text: t.Text, Replace: t.Replace, Anchor: srcedit.DeclAnchor(t.Anchor), AnchorName: t.AnchorName})
+
+		case "file":
+			if !strings.HasSuffix(t.File, ".go") {
+				trs = append(trs, &srcedit.AddFileTransform{Filename: t.File, Text: t.Text, Replace: t.Replace})
+				continue
+			}
+			fileTrs, err := goFileTransforms(t.File, t.Text)
+			if err != nil {
+				return nil, fmt.Errorf("transform %d: %w", i, err)
+			}
+			if t.Replace {
+				srcedit.SetReplace(fileTrs)
+			}
+			trs = append(trs, fileTrs...)
+
+		case "section":
+			if t.Name == "" {
+				return nil, fmt.Errorf("transform %d: section with no name", i)
+			}
+			trs = append(trs, &srcedit.AppendFileSectionTransform{Filename: t.File, Name: t.Name, Text: t.Text, Replace: t.Replace})
+
+		default:
+			return nil, fmt.Errorf("transform %d: unknown kind %q", i, t.Kind)
+		}
+	}
+
+	return trs, nil
+}
+
+// goFileTransforms parses the source of a whole Go file into transforms.
+func goFileTransforms(fileName, src string) ([]srcedit.Transform, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), fileName, src, parser.PackageClauseOnly)
+	if err != nil {
+		return nil, err
+	}
+	// ParseTransforms wants everything after the package clause
+	return srcedit.ParseTransforms(fileName, src[f.Name.End()-1:])
+}
+
+// Call runs the plugin executable at pluginPath with req and returns its response.
+// The plugin's stderr goes to stderr.  A response with Error set is returned as an error.
+func Call(pluginPath string, req *Request, stderr io.Writer) (*Response, error) {
+
+	req.Protocol = Protocol
+	b, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(pluginPath, "-gocode-plugin")
+	cmd.Stdin = bytes.NewReader(b)
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+	runErr := cmd.Run()
+
+	// a plugin exits non-zero when it responds with an error, which says more than the exit status
+	var res Response
+	err = json.Unmarshal(stdout.Bytes(), &res)
+	if err == nil && res.Error != "" {
+		return &res, fmt.Errorf("plugin %q: %s", pluginPath, res.Error)
+	}
+	if runErr != nil {
+		return nil, fmt.Errorf("running plugin %q: %w", pluginPath, runErr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing response from plugin %q: %w", pluginPath, err)
+	}
+	if req.Op == "describe" && res.Describe == nil {
+		return &res, fmt.Errorf("plugin %q: no description in response to describe", pluginPath)
+	}
+
+	return &res, nil
+}
+
+// Main handles the protocol for plugins written in Go.  It reads the request from stdin,
+// responds to "describe" with desc and to "generate" with the result of calling generate,
+// writes the response to stdout and exits.
+func Main(desc *codeflag.Description, generate func(req *Request) ([]Transform, error)) {
+	os.Exit(serve(os.Stdin, os.Stdout, desc, generate))
+}
+
+func serve(r io.Reader, w io.Writer, desc *codeflag.Description, generate func(req *Request) ([]Transform, error)) int {
+
+	var req Request
+	var res Response
+
+	err := json.NewDecoder(r).Decode(&req)
+	switch {
+	case err != nil:
+		res.Error = fmt.Sprintf("reading request: %v", err)
+	case req.Protocol > Protocol:
+		res.Error = fmt.Sprintf("protocol version %d not supported (max %d), this plugin needs to be updated", req.Protocol, Protocol)
+	case req.Op == "describe":
+		res.Describe = desc
+	case req.Op == "generate":
+		res.Transforms, err = generate(&req)
+		if err != nil {
+			res.Error = err.Error()
+		}
+	default:
+		res.Error = fmt.Sprintf("unknown op %q", req.Op)
+	}
+
+	err = json.NewEncoder(w).Encode(&res)
+	if err != nil || res.Error != "" {
+		return 1
+	}
+	return 0
+}
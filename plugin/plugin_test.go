@@ -0,0 +1,259 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/psanford/memfs"
+
+	"github.com/d0sbit/gocode/codeflag"
+	"github.com/d0sbit/gocode/srcedit"
+	"github.com/d0sbit/gocode/srcedit/model"
+)
+
+func TestTransforms(t *testing.T) {
+
+	type tcase struct {
+		name   string
+		in     []Transform
+		eerr   string
+		efiles map[string]string // expected contents of other files
+		econts []string          // expected in widget.go
+	}
+
+	tcaseList := []tcase{
+		{
+			name: "decls",
+			in: []Transform{
+				{Kind: "import", File: "widget.go", Path: "fmt"},
+				{Kind: "type", File: "widget.go", Name: "WidgetStore", Text: "type WidgetStore struct{}"},
+				{Kind: "func", File: "widget.go", Name: "Hello", Receiver: "*WidgetStore", Text: "func (s *WidgetStore) Hello() { fmt.Println(\"hello\") }"},
+				{Kind: "const", File: "widget.go", Names: []string{"WidgetMax"}, Text: "const WidgetMax = 10"},
+				{Kind: "var", File: "widget.go", Names: []string{"widgetCount"}, Text: "var widgetCount int"},
+			},
+			econts: []string{`import "fmt"`, "type WidgetStore struct{}", "func (s *WidgetStore) Hello()", "const WidgetMax = 10", "var widgetCount int"},
+		},
+		{
+			name: "go_file",
+			in: []Transform{
+				{Kind: "file", File: "widget.go", Text: "package store\n\nimport \"fmt\"\n\n// Hello says hello.\nfunc Hello() { fmt.Println(\"hello\") }\n"},
+				{Kind: "file", File: "widget.sql", Text: "SELECT 1;\n"},
+			},
+			efiles: map[string]string{"widget.sql": "SELECT 1;\n"},
+			econts: []string{`import "fmt"`, "// Hello says hello.\nfunc Hello()"},
+		},
+		{
+			name: "section",
+			in: []Transform{
+				{Kind: "type", File: "widget.go", Name: "WidgetStore", Text: "type WidgetStore struct{}"},
+				{Kind: "section", File: "README.md", Name: "widget", Text: "Widgets are stored."},
+			},
+			efiles: map[string]string{"README.md": "<!-- gocode:section widget begin -->\nWidgets are stored.\n<!-- gocode:section widget end -->\n"},
+			econts: []string{"type WidgetStore struct{}"},
+		},
+		{
+			name: "go_section",
+			in:   []Transform{{Kind: "section", File: "widget.go", Name: "widget", Text: "// widgets"}},
+			eerr: "section transform for Go file",
+		},
+		{
+			name: "bad_file",
+			in:   []Transform{{Kind: "func", File: "../widget.go", Name: "Hello", Text: "func Hello() {}"}},
+			eerr: "invalid file name",
+		},
+		{
+			name: "non_go_decl",
+			in:   []Transform{{Kind: "func", File: "widget.sql", Name: "Hello", Text: "func Hello() {}"}},
+			eerr: "non-Go file",
+		},
+		{
+			name: "bad_kind",
+			in:   []Transform{{Kind: "method", File: "widget.go"}},
+			eerr: "unknown kind",
+		},
+	}
+
+	for _, tc := range tcaseList {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+
+			trs, err := Transforms(tc.in)
+			if tc.eerr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.eerr) {
+					t.Fatalf("expected error containing %q, got %v", tc.eerr, err)
+				}
+				return
+			}
+			must(t, err)
+
+			infs := memfs.New()
+			must(t, infs.MkdirAll("store", 0755))
+			must(t, infs.WriteFile("store/store.go", []byte("package store\n"), 0644))
+			outfs := memfs.New()
+			must(t, outfs.MkdirAll("store", 0755))
+
+			pkg := srcedit.NewPackage(infs, outfs, "example.com/test", "store")
+			must(t, pkg.ApplyTransforms(trs...))
+
+			b, err := fs.ReadFile(outfs, "store/widget.go")
+			must(t, err)
+			t.Logf("widget.go:\n%s", b)
+			for _, s := range tc.econts {
+				if !strings.Contains(string(b), s) {
+					t.Errorf("output missing %q", s)
+				}
+			}
+			for fn, text := range tc.efiles {
+				b, err := fs.ReadFile(outfs, "store/"+fn)
+				must(t, err)
+				if string(b) != text {
+					t.Errorf("%s: expected %q, got %q", fn, text, b)
+				}
+			}
+		})
+	}
+}
+
+func TestNewStruct(t *testing.T) {
+
+	infs := memfs.New()
+	must(t, infs.MkdirAll("store", 0755))
+	must(t, infs.WriteFile("store/widget.go", []byte("package store\n\ntype Widget struct {\n\tWidgetID string `db:\"widget_id\" json:\"widget_id,omitempty\"`\n\tName *string `db:\"name\"`\n}\n"), 0644))
+
+	pkg := srcedit.NewPackage(infs, infs, "example.com/test", "store")
+	ti, err := pkg.FindType("Widget")
+	must(t, err)
+	ms, err := model.NewStruct(ti, "")
+	must(t, err)
+
+	b, err := json.Marshal(NewStruct(ms))
+	must(t, err)
+	expected := `{"name":"Widget","fields":[` +
+		`{"name":"WidgetID","type":"string","tag":"db:\"widget_id\" json:\"widget_id,omitempty\"","tags":{"db":["widget_id"],"json":["widget_id","omitempty"]},"pk":true},` +
+		`{"name":"Name","type":"*string","tag":"db:\"name\"","tags":{"db":["name"]}}]}`
+	if string(b) != expected {
+		t.Errorf("unexpected struct JSON:\n%s", b)
+	}
+}
+
+func TestServe(t *testing.T) {
+
+	desc := &codeflag.Description{Tool: "example", Description: "Example plugin"}
+	generate := func(req *Request) ([]Transform, error) {
+		if req.Type == nil {
+			return nil, errors.New("no type")
+		}
+		return []Transform{{Kind: "type", File: req.File, Name: req.Type.Name + "Store", Text: "type " + req.Type.Name + "Store struct{}"}}, nil
+	}
+
+	type tcase struct {
+		name  string
+		in    string
+		ecode int
+		eout  string
+	}
+
+	tcaseList := []tcase{
+		{
+			name: "describe",
+			in:   `{"protocol":1,"op":"describe"}`,
+			eout: `{"describe":{"tool":"example","description":"Example plugin","flags":null}}`,
+		},
+		{
+			name: "generate",
+			in:   `{"protocol":1,"op":"generate","file":"widget.go","type":{"name":"Widget","fields":[]}}`,
+			eout: `{"transforms":[{"kind":"type","file":"widget.go","name":"WidgetStore","text":"type WidgetStore struct{}"}]}`,
+		},
+		{
+			name:  "generate_error",
+			in:    `{"protocol":1,"op":"generate"}`,
+			ecode: 1,
+			eout:  `{"error":"no type"}`,
+		},
+		{
+			name:  "newer_protocol",
+			in:    `{"protocol":2,"op":"describe"}`,
+			ecode: 1,
+			eout:  `{"error":"protocol version 2 not supported (max 1), this plugin needs to be updated"}`,
+		},
+	}
+
+	for _, tc := range tcaseList {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			code := serve(strings.NewReader(tc.in), &buf, desc, generate)
+			if code != tc.ecode {
+				t.Errorf("unexpected exit code %d", code)
+			}
+			if strings.TrimSpace(buf.String()) != tc.eout {
+				t.Errorf("unexpected output: %s", buf.String())
+			}
+		})
+	}
+}
+
+func TestCall(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("fake plugins are shell scripts")
+	}
+
+	type tcase struct {
+		name   string
+		script string
+		eerr   string
+	}
+
+	tcaseList := []tcase{
+		{
+			name:   "ok",
+			script: `echo '{"transforms":[]}'`,
+		},
+		{
+			name:   "error_response",
+			script: `echo '{"error":"MY HELPFUL MESSAGE"}'; exit 1`,
+			eerr:   "MY HELPFUL MESSAGE",
+		},
+		{
+			name:   "exit_status",
+			script: `exit 2`,
+			eerr:   "exit status 2",
+		},
+		{
+			name:   "bad_response",
+			script: `echo 'not json'`,
+			eerr:   "parsing response",
+		},
+	}
+
+	for _, tc := range tcaseList {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			pluginPath := filepath.Join(t.TempDir(), Prefix+"example")
+			must(t, os.WriteFile(pluginPath, []byte("#!/bin/sh\ncat > /dev/null\n"+tc.script+"\n"), 0755))
+			_, err := Call(pluginPath, &Request{Op: "generate"}, &bytes.Buffer{})
+			if tc.eerr == "" {
+				must(t, err)
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.eerr) {
+				t.Errorf("expected error containing %q, got %v", tc.eerr, err)
+			}
+		})
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,154 @@
+package srcedit
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"strings"
+)
+
+// declPlace is where addDecl puts a declaration in its file.
+type declPlace struct {
+	offset    int    // byte offset to insert at, -1 means the end of the file
+	pre, post string // written before and after the text, e.g. to leave a blank line
+}
+
+// atEnd places a declaration at the end of the file.
+var atEnd = declPlace{offset: -1}
+
+// addDecl adds the text of a declaration to fname at place, with a marker for owner if set.
+func (p *Package) addDecl(fname, text, owner string, place declPlace) error {
+
+	if owner != "" {
+		text = markText(text, owner)
+	}
+
+	b := p.fileBytesOrNew(fname)
+	if place.offset < 0 {
+		b = append(b, text...)
+		b = append(b, "\n"...)
+		return p.writeFileNamed(fname, b)
+	}
+
+	out := make([]byte, 0, len(b)+len(place.pre)+len(text)+len(place.post))
+	out = append(out, b[:place.offset]...)
+	out = append(out, place.pre...)
+	out = append(out, text...)
+	out = append(out, place.post...)
+	out = append(out, b[place.offset:]...)
+	return p.writeFileNamed(fname, out)
+}
+
+// replacedPlace returns where the declaration replacing node goes, once node has been
+// removed from its file with its doc comment: where node started.
+func (p *Package) replacedPlace(node ast.Node) declPlace {
+	start, _ := nodeRange(node)
+	return declPlace{offset: p.fset.Position(start).Offset}
+}
+
+// anchorPlace returns where a new declaration goes in fname for anchor, see DeclAnchor.
+// receiverType is the receiver of a method, "" otherwise.
+func (p *Package) anchorPlace(fname string, anchor DeclAnchor, anchorName, receiverType string) (declPlace, error) {
+
+	var target ast.Decl
+	before := false
+
+	af := p.astf[fname]
+	if af == nil || p.dirty[fname] { // new file, or not parsed since it was written
+		return atEnd, nil
+	}
+
+	switch anchor {
+
+	case DeclAtEnd:
+		return atEnd, nil
+
+	case DeclAfter, DeclBefore:
+		for _, decl := range af.Decls {
+			if hasDeclName(decl, anchorName) {
+				target = decl
+				break
+			}
+		}
+		before = anchor == DeclBefore
+
+	case DeclWithReceiver:
+		if receiverType == "" {
+			return atEnd, nil
+		}
+		baseType := strings.TrimPrefix(receiverType, "*")
+		var typeDecl, lastMethod ast.Decl
+		for _, decl := range af.Decls {
+			if fdecl, ok := decl.(*ast.FuncDecl); ok {
+				recvTypeExpr, _ := splitFuncDecl(fdecl)
+				if recvTypeExpr != "" && strings.TrimPrefix(recvTypeExpr, "*") == baseType {
+					lastMethod = decl
+				}
+			} else if hasDeclName(decl, baseType) {
+				typeDecl = decl
+			}
+		}
+		target = lastMethod
+		if target == nil {
+			target = typeDecl
+		}
+
+	default:
+		return atEnd, fmt.Errorf("unknown anchor %q", anchor)
+	}
+
+	if target == nil {
+		return atEnd, nil
+	}
+
+	start, end := nodeRange(target)
+	if before {
+		return declPlace{offset: p.fset.Position(start).Offset, post: "\n\n"}, nil
+	}
+
+	// after the end of the line, in case there's a comment on it
+	b := p.fileBytes[fname]
+	offset := p.fset.Position(end).Offset
+	if i := bytes.IndexByte(b[offset:], '\n'); i >= 0 {
+		offset += i
+	} else {
+		offset = len(b)
+	}
+	return declPlace{offset: offset, pre: "\n\n"}, nil
+}
+
+// hasDeclName reports whether decl declares name, which for a method is given as in a method
+// expression, e.g. "(*X).F" or "X.F".
+func hasDeclName(decl ast.Decl, name string) bool {
+
+	switch d := decl.(type) {
+
+	case *ast.FuncDecl:
+		recvTypeExpr, funcName := splitFuncDecl(d)
+		if recvTypeExpr == "" {
+			return funcName == name
+		}
+		if name == funcDisplayName(recvTypeExpr, funcName) {
+			return true
+		}
+		return !strings.HasPrefix(recvTypeExpr, "*") && name == recvTypeExpr+"."+funcName
+
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				if s.Name.Name == name {
+					return true
+				}
+			case *ast.ValueSpec:
+				for _, n := range s.Names {
+					if n.Name == name {
+						return true
+					}
+				}
+			}
+		}
+	}
+
+	return false
+}
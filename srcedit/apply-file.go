@@ -0,0 +1,136 @@
+package srcedit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+func (p *Package) applyAddFile(t *AddFileTransform) error {
+
+	err := checkOtherFilename(t.Filename)
+	if err != nil {
+		return err
+	}
+
+	_, exists, err := p.readOtherFile(t.Filename)
+	if err != nil {
+		return err
+	}
+	if exists && !t.Replace {
+		if t.FailIfExists {
+			return fmt.Errorf("%q: %w", t.Filename, fs.ErrExist)
+		}
+		return nil
+	}
+
+	return p.writeOtherFile(t.Filename, []byte(t.Text))
+}
+
+func (p *Package) applyAppendFileSection(t *AppendFileSectionTransform) error {
+
+	err := checkOtherFilename(t.Filename)
+	if err != nil {
+		return err
+	}
+	if t.Name == "" || strings.ContainsAny(t.Name, " \t\r\n") {
+		return fmt.Errorf("invalid section name %q", t.Name)
+	}
+
+	b, _, err := p.readOtherFile(t.Filename)
+	if err != nil {
+		return err
+	}
+
+	begin, end := sectionLines(t.Filename, t.Name)
+	text := strings.TrimSuffix(t.Text, "\n")
+	section := begin + "\n" + text + "\n" + end + "\n"
+
+	// already there, only the lines between the comments are replaced
+	if bi := lineIndex(b, begin); bi >= 0 {
+		ei := lineIndex(b[bi:], end)
+		if ei < 0 {
+			return fmt.Errorf("section %q in %q has no end line %q", t.Name, t.Filename, end)
+		}
+		ei += bi + len(end)
+		if ei < len(b) && b[ei] == '\n' {
+			ei++
+		}
+		if !t.Replace {
+			return nil
+		}
+		out := applyEdits(b, []srcEdit{{start: bi, end: ei, text: section}})
+		return p.writeOtherFile(t.Filename, out)
+	}
+
+	out := append([]byte(nil), b...)
+	if len(out) > 0 && out[len(out)-1] != '\n' {
+		out = append(out, '\n')
+	}
+	out = append(out, section...)
+	return p.writeOtherFile(t.Filename, out)
+}
+
+// sectionLines returns the comment lines that begin and end the named section in fname,
+// written the way comments are in that type of file.
+func sectionLines(fname, name string) (begin, end string) {
+	pfx, sfx := "# ", ""
+	switch strings.ToLower(path.Ext(fname)) {
+	case ".sql":
+		pfx = "-- "
+	case ".md", ".html", ".htm", ".xml":
+		pfx, sfx = "<!-- ", " -->"
+	}
+	return pfx + "gocode:section " + name + " begin" + sfx, pfx + "gocode:section " + name + " end" + sfx
+}
+
+// lineIndex returns the offset in b of the first line which is line, ignoring whitespace around
+// it, or -1 if there isn't one.
+func lineIndex(b []byte, line string) int {
+	off := 0
+	for off < len(b) {
+		next := bytes.IndexByte(b[off:], '\n')
+		if next < 0 {
+			next = len(b) - off
+		}
+		if strings.TrimSpace(string(b[off:off+next])) == line {
+			return off
+		}
+		off += next + 1
+	}
+	return -1
+}
+
+// checkOtherFilename returns an error unless fname is a file in the package directory that
+// isn't Go code.
+func checkOtherFilename(fname string) error {
+	if fname == "" || path.Base(fname) != fname || fname == "." || fname == ".." {
+		return fmt.Errorf("invalid file name %q, must be a file in the package directory", fname)
+	}
+	if strings.HasSuffix(fname, ".go") {
+		return fmt.Errorf("%q is a Go file, use the transforms for declarations instead", fname)
+	}
+	return nil
+}
+
+// readOtherFile returns the contents of a file in the package that isn't Go code, as changed
+// so far, and whether it exists.
+func (p *Package) readOtherFile(fname string) ([]byte, bool, error) {
+	b, err := p.readFile(fname)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+// writeOtherFile writes a file in the package that isn't Go code.  Unlike writeFileNamed it is
+// not recorded to be parsed.
+func (p *Package) writeOtherFile(fname string, data []byte) error {
+	return p.writeFile(path.Join(p.subDir, fname), data, p.getFileModeOrDefault(fname, 0644))
+}
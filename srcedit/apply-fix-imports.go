@@ -0,0 +1,168 @@
+package srcedit
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// import groups, in the order they are written
+const (
+	importGroupStd = iota
+	importGroupThirdParty
+	importGroupModule
+)
+
+// fixImport is an import kept by applyFixImports.
+type fixImport struct {
+	name, path string
+	text       string // the spec with its comments, dedented
+}
+
+func (p *Package) applyFixImports(t *FixImportsTransform) error {
+
+	pkgNames := p.pkgNames()
+
+	for filename, af := range p.astf {
+
+		if t.FilenameList != nil && !stringIn(filename, t.FilenameList) {
+			continue
+		}
+
+		var decls []*ast.GenDecl
+		for _, decl := range af.Decls {
+			if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+				decls = append(decls, genDecl)
+			}
+		}
+		if len(decls) == 0 {
+			continue
+		}
+
+		// cgo's preamble is the doc comment on import "C", leave those alone
+		cgo := false
+		for _, ispec := range af.Imports {
+			if ispec.Path.Value == `"C"` {
+				cgo = true
+			}
+		}
+		if cgo {
+			continue
+		}
+
+		drop := make(map[*ast.ImportSpec]bool)
+		for _, ispec := range unusedImports(af, pkgNames) {
+			drop[ispec] = true
+		}
+
+		b := p.fileBytes[filename]
+		offset := func(pos token.Pos) int { return p.fset.Position(pos).Offset }
+
+		var groups [3][]fixImport
+		seen := make(map[string]bool)
+		for _, ispec := range af.Imports {
+			if drop[ispec] {
+				continue
+			}
+			ipath, err := strconv.Unquote(ispec.Path.Value)
+			if err != nil {
+				return err
+			}
+			fi := fixImport{path: ipath}
+			if ispec.Name != nil {
+				fi.name = ispec.Name.Name
+			}
+			if seen[fi.name+" "+fi.path] {
+				continue
+			}
+			seen[fi.name+" "+fi.path] = true
+
+			start, end := nodeRange(ispec)
+			if ispec.Comment != nil {
+				end = ispec.Comment.End()
+			}
+			fi.text = dedent(string(b[offset(start):offset(end)]), lineIndent(b, offset(start)))
+
+			g := p.importGroup(ipath)
+			groups[g] = append(groups[g], fi)
+		}
+
+		var lines []string
+		count := 0
+		for _, g := range groups {
+			if len(g) == 0 {
+				continue
+			}
+			sort.SliceStable(g, func(i, j int) bool {
+				if g[i].path != g[j].path {
+					return g[i].path < g[j].path
+				}
+				return g[i].name < g[j].name
+			})
+			if len(lines) > 0 {
+				lines = append(lines, "")
+			}
+			for _, fi := range g {
+				lines = append(lines, strings.Split(fi.text, "\n")...)
+			}
+			count += len(g)
+		}
+
+		var block string
+		switch {
+		case count == 1 && len(lines) == 1:
+			block = "import " + lines[0]
+		case count > 0:
+			var sb strings.Builder
+			sb.WriteString("import (\n")
+			for _, line := range lines {
+				if line != "" {
+					sb.WriteString("\t")
+					sb.WriteString(line)
+				}
+				sb.WriteString("\n")
+			}
+			sb.WriteString(")")
+			block = sb.String()
+		}
+
+		// the block replaces the first import declaration, keeping its doc comment,
+		// and the rest are removed
+		var edits []srcEdit
+		for i, decl := range decls {
+			if i == 0 && block != "" {
+				edits = append(edits, srcEdit{start: offset(decl.Pos()), end: offset(decl.End()), text: block})
+				continue
+			}
+			start, end := p.nodeLines(b, decl)
+			edits = append(edits, srcEdit{start: start, end: end})
+		}
+
+		out := applyEdits(b, edits)
+		if string(out) == string(b) {
+			continue
+		}
+		p.fileBytes[filename] = out
+		err := p.writeFileNamed(filename, out)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// importGroup returns which group an import path belongs in: the standard library if the
+// first element of the path has no dot, this module if it is under the module path, and
+// third party otherwise.
+func (p *Package) importGroup(ipath string) int {
+	if p.modulePath != "" && (ipath == p.modulePath || strings.HasPrefix(ipath, p.modulePath+"/")) {
+		return importGroupModule
+	}
+	if !strings.Contains(strings.SplitN(ipath, "/", 2)[0], ".") {
+		return importGroupStd
+	}
+	return importGroupThirdParty
+}
@@ -0,0 +1,202 @@
+package srcedit
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+func (p *Package) applyAddFuncLine(t *AddFuncLineTransform) error {
+
+	filename, funcDecl := p.findFunc(t.ReceiverType, t.Name)
+	if funcDecl == nil {
+		return fmt.Errorf("func %q with receiver %q: %w", t.Name, t.ReceiverType, ErrNotFound)
+	}
+	if funcDecl.Body == nil {
+		return fmt.Errorf("func %q with receiver %q has no body", t.Name, t.ReceiverType)
+	}
+	stmts, err := parseStmts(p.renameImports(filename, t.Text))
+	if err != nil {
+		return err
+	}
+
+	// statements already in the function, anywhere in the body
+	existing := make(map[string]bool)
+	ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+		if stmt, ok := n.(ast.Stmt); ok && n != ast.Node(funcDecl.Body) {
+			existing[stmtString(p.fset, stmt)] = true
+		}
+		return true
+	})
+
+	var add []string
+	for _, s := range stmts {
+		if existing[s.key] {
+			continue
+		}
+		existing[s.key] = true // in case Text has the same one twice
+		add = append(add, s.text)
+	}
+	if len(add) == 0 {
+		return nil
+	}
+
+	// left alone if pinned or changed by hand, otherwise its marker is updated afterwards
+	if p.protected(filename, funcDisplayName(t.ReceiverType, t.Name), funcDecl, funcDecl.Doc) {
+		return nil
+	}
+	clean := markerMatches(p.nodeCode(filename, funcDecl), funcDecl.Doc)
+
+	b := p.fileBytes[filename]
+	offset, indent, err := p.funcLineOffset(b, filename, funcDecl, t)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(b[:offset])
+	if offset > 0 && b[offset-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	for _, text := range add {
+		for _, line := range strings.Split(text, "\n") {
+			if strings.TrimSpace(line) != "" {
+				buf.WriteString(indent)
+				buf.WriteString(line)
+			}
+			buf.WriteByte('\n')
+		}
+	}
+	buf.Write(b[offset:])
+
+	p.fileBytes[filename] = buf.Bytes()
+	err = p.writeFileNamed(filename, buf.Bytes())
+	if err != nil || !clean {
+		return err
+	}
+	return p.remarkFuncDecl(t.ReceiverType, t.Name)
+}
+
+// funcLineOffset returns the offset in b where statements are inserted for t, which is
+// always the start of a line unless the body is all on one line, and the indentation to use.
+func (p *Package) funcLineOffset(b []byte, filename string, funcDecl *ast.FuncDecl, t *AddFuncLineTransform) (offset int, indent string, err error) {
+
+	body := funcDecl.Body
+	list := body.List
+
+	// indent like the existing statements, or one level in from the func
+	indent = lineIndent(b, p.fset.Position(funcDecl.Pos()).Offset) + "\t"
+	if len(list) > 0 {
+		indent = lineIndent(b, p.fset.Position(list[0].Pos()).Offset)
+	}
+
+	// by default the statements go at the start of the line with the closing brace
+	end := p.fset.Position(body.Rbrace).Offset
+	offset = lineStart(b, end)
+	if offset <= p.fset.Position(body.Lbrace).Offset {
+		offset = end // closing brace is on the same line as the opening one
+	}
+
+	switch t.Anchor {
+
+	case FuncLineBeforeReturn:
+		if len(list) == 0 {
+			return offset, indent, nil
+		}
+		ret, ok := list[len(list)-1].(*ast.ReturnStmt)
+		if !ok {
+			return offset, indent, nil
+		}
+		start := p.fset.Position(ret.Pos()).Offset
+		if ls := lineStart(b, start); ls > p.fset.Position(body.Lbrace).Offset {
+			start = ls
+		}
+		return start, indent, nil
+
+	case FuncLineAfterComment:
+		want := strings.TrimSpace(t.Comment)
+		for _, cg := range p.astf[filename].Comments {
+			if cg.Pos() < body.Lbrace || cg.End() > body.Rbrace {
+				continue
+			}
+			for _, c := range cg.List {
+				if strings.TrimSpace(c.Text) != want {
+					continue
+				}
+				cend := p.fset.Position(c.End()).Offset
+				if i := bytes.IndexByte(b[cend:], '\n'); i >= 0 {
+					return cend + i + 1, lineIndent(b, p.fset.Position(c.Pos()).Offset), nil
+				}
+			}
+		}
+		return 0, "", fmt.Errorf("comment %q not found in func %q", t.Comment, t.Name)
+
+	case FuncLineAtEnd:
+		return offset, indent, nil
+
+	}
+
+	return 0, "", fmt.Errorf("unknown anchor %q", t.Anchor)
+}
+
+// parsedStmt is a statement from AddFuncLineTransform.Text.
+type parsedStmt struct {
+	key  string // the formatted statement, for comparison
+	text string // the source text, including the comments before it
+}
+
+// parseStmts splits text into statements.
+func parseStmts(text string) ([]parsedStmt, error) {
+
+	pfx := "package snippet__\n\nfunc snippet__() {\n"
+	src := pfx + text + "\n}\n"
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("parsing statements: %w", err)
+	}
+	body := f.Decls[0].(*ast.FuncDecl).Body
+
+	var ret []parsedStmt
+	prev := len(pfx)
+	for _, stmt := range body.List {
+		end := fset.Position(stmt.End()).Offset
+		ret = append(ret, parsedStmt{
+			key:  stmtString(fset, stmt),
+			text: strings.TrimSpace(src[prev:end]),
+		})
+		prev = end
+	}
+
+	return ret, nil
+}
+
+// stmtString returns the formatted text of a statement without comments.
+func stmtString(fset *token.FileSet, stmt ast.Stmt) string {
+	var buf bytes.Buffer
+	err := format.Node(&buf, fset, stmt)
+	if err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// lineStart returns the offset of the start of the line containing offset.
+func lineStart(b []byte, offset int) int {
+	return bytes.LastIndexByte(b[:offset], '\n') + 1
+}
+
+// lineIndent returns the whitespace at the start of the line containing offset.
+func lineIndent(b []byte, offset int) string {
+	start := lineStart(b, offset)
+	end := start
+	for end < len(b) && (b[end] == ' ' || b[end] == '\t') {
+		end++
+	}
+	return string(b[start:end])
+}
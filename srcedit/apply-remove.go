@@ -0,0 +1,381 @@
+package srcedit
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func (p *Package) applyRemoveFuncDecl(t *RemoveFuncDeclTransform) error {
+	filename, funcDecl := p.findFunc(t.ReceiverType, t.Name)
+	if isInitFunc(t.ReceiverType, t.Name) {
+		if t.Text == "" { // could be any of them
+			return nil
+		}
+		filename, funcDecl = p.findFuncCode(t.ReceiverType, t.Name, t.Text)
+	}
+	if funcDecl == nil || p.protected(filename, funcDisplayName(t.ReceiverType, t.Name), funcDecl, funcDecl.Doc) {
+		return nil
+	}
+	return p.removeNode(filename, funcDecl)
+}
+
+func (p *Package) applyRemoveTypeDecl(t *RemoveTypeDeclTransform) error {
+	filename, typeDecl := p.findTypeDecl(t.Name)
+	if typeDecl == nil || p.protected(filename, t.Name, typeDecl, typeDecl.Doc) {
+		return nil
+	}
+	return p.removeNode(filename, typeDecl)
+}
+
+func (p *Package) applyRemoveVarConstDecl(t *RemoveVarConstDeclTransform) error {
+	for _, tok := range []token.Token{token.VAR, token.CONST} {
+		filename, names, varOrConstDecl := p.findVarOrConstDecl(tok, t.NameList)
+		if allBlank(t.NameList) {
+			filename, names, varOrConstDecl = p.findBlankDeclCode(tok, t.Text)
+		}
+		if varOrConstDecl == nil {
+			continue
+		}
+		if !isStrSubset(names, t.NameList) {
+			return fmt.Errorf("%s block %+v has names not in %+v", tok, names, t.NameList)
+		}
+		if p.protected(filename, strings.Join(names, ", "), varOrConstDecl, varOrConstDecl.Doc) {
+			return nil
+		}
+		return p.removeNode(filename, varOrConstDecl)
+	}
+	return nil
+}
+
+// removeNode removes node from the file along with its doc comment, and the lines it was on
+// if nothing else is on them.
+func (p *Package) removeNode(filename string, node ast.Node) error {
+	b := p.fileBytes[filename]
+	start, end := p.nodeLines(b, node)
+	out := applyEdits(b, []srcEdit{{start: start, end: end}})
+	p.fileBytes[filename] = out
+	return p.writeFileNamed(filename, out)
+}
+
+// nodeLines returns the range of bytes to remove node from b: the node and its doc comment,
+// plus whitespace and the newline around it if it is on lines of its own, plus a following
+// blank line if there is one before it too, so removing a declaration doesn't leave a gap.
+func (p *Package) nodeLines(b []byte, node ast.Node) (start, end int) {
+
+	spos, epos := nodeRange(node)
+	start, end = p.fset.Position(spos).Offset, p.fset.Position(epos).Offset
+
+	if ls := lineStart(b, start); strings.TrimSpace(string(b[ls:start])) == "" {
+		start = ls
+	}
+	le := end
+	for le < len(b) && (b[le] == ' ' || b[le] == '\t' || b[le] == '\r') {
+		le++
+	}
+	if le < len(b) && b[le] == '\n' {
+		end = le + 1
+	} else if le == len(b) {
+		end = le
+	}
+
+	blankBefore := start == 0 || (start >= 2 && b[start-1] == '\n' && b[start-2] == '\n')
+	if start == lineStart(b, start) && blankBefore && end < len(b) && b[end] == '\n' {
+		end++
+	}
+
+	return start, end
+}
+
+func (p *Package) applyRemoveUnusedImports(t *RemoveUnusedImportsTransform) error {
+
+	pkgNames := p.pkgNames()
+
+	for filename, af := range p.astf {
+
+		if t.FilenameList != nil && !stringIn(filename, t.FilenameList) {
+			continue
+		}
+
+		unused := unusedImports(af, pkgNames)
+		if len(unused) == 0 {
+			continue
+		}
+
+		b := p.fileBytes[filename]
+		var edits []srcEdit
+		for _, decl := range af.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.IMPORT {
+				continue
+			}
+			var remove []ast.Node
+			for _, spec := range genDecl.Specs {
+				for _, ispec := range unused {
+					if spec == ispec {
+						remove = append(remove, ispec)
+					}
+				}
+			}
+			if len(remove) == len(genDecl.Specs) {
+				remove = []ast.Node{genDecl}
+			}
+			for _, n := range remove {
+				start, end := p.nodeLines(b, n)
+				edits = append(edits, srcEdit{start: start, end: end})
+			}
+		}
+
+		out := applyEdits(b, edits)
+		p.fileBytes[filename] = out
+		err := p.writeFileNamed(filename, out)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pkgNames returns the names declared at the top level of the package, which are referred
+// to without an import.
+func (p *Package) pkgNames() map[string]bool {
+	ret := make(map[string]bool)
+	for _, af := range p.astf {
+		for _, decl := range af.Decls {
+			for _, id := range declIdents(decl) {
+				ret[id.Name] = true
+			}
+		}
+	}
+	return ret
+}
+
+// unusedImports returns the imports in af that nothing in it refers to, including the second
+// and later imports of the same package.  If the file refers to a package none of its imports
+// seem to be, the names guessed from the import paths can't be trusted and nil is returned.
+// pkgNames are the names declared at the top level of the package.
+func unusedImports(af *ast.File, pkgNames map[string]bool) []*ast.ImportSpec {
+
+	// packages the file refers to: the X in X.Y where X isn't declared in the file
+	used := make(map[string]bool)
+	ast.Inspect(af, func(n ast.Node) bool {
+		se, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if id, ok := se.X.(*ast.Ident); ok && id.Obj == nil {
+			used[id.Name] = true
+		}
+		return true
+	})
+
+	// with nothing left but imports, even blank ones have no purpose
+	empty := true
+	for _, decl := range af.Decls {
+		if gd, ok := decl.(*ast.GenDecl); !ok || gd.Tok != token.IMPORT {
+			empty = false
+		}
+	}
+
+	var unused []*ast.ImportSpec
+	for _, ispec := range af.Imports {
+		if empty {
+			unused = append(unused, ispec)
+			continue
+		}
+		name := importName(ispec)
+		if name == "" || name == "_" || name == "." {
+			continue
+		}
+		if used[name] {
+			delete(used, name)
+			continue
+		}
+		unused = append(unused, ispec)
+	}
+
+	for name := range used {
+		if !pkgNames[name] {
+			return nil
+		}
+	}
+
+	return unused
+}
+
+var majorVersionRE = regexp.MustCompile(`^v[0-9]+$`)
+
+// importName returns the name an import is referred to by in the file, guessing it from
+// the path if it isn't named, or "" if no guess can be made.
+func importName(ispec *ast.ImportSpec) string {
+	if ispec.Name != nil {
+		return ispec.Name.Name
+	}
+	ipath, err := strconv.Unquote(ispec.Path.Value)
+	if err != nil {
+		return ""
+	}
+	return importPathName(ipath)
+}
+
+// importPathName returns the name the package with import path ipath is most likely to have,
+// or "" if it can't be guessed.
+func importPathName(ipath string) string {
+	name := path.Base(ipath)
+	if majorVersionRE.MatchString(name) {
+		name = path.Base(path.Dir(ipath))
+	}
+	if i := strings.Index(name, ".v"); i > 0 { // gopkg.in/yaml.v2
+		name = name[:i]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	name = strings.TrimSuffix(name, "-go")
+	if !token.IsIdentifier(name) {
+		return ""
+	}
+	return name
+}
+
+// Referenced reports whether any of the declarations removed by the Remove transforms in
+// trList is referred to by name from the rest of the package, including its tests.  This is
+// used to decide whether code shared by several generated types can be removed.  It errs on
+// the side of true, since a method, field or local variable with one of the names counts too.
+func (p *Package) Referenced(trList []Transform) (bool, error) {
+
+	err := p.load()
+	if err != nil {
+		return false, err
+	}
+
+	return p.referenced(trList), nil
+}
+
+func (p *Package) applyRemoveUnreferenced(t *RemoveUnreferencedTransform) error {
+
+	if p.referenced(t.TransformList) {
+		return nil
+	}
+
+	for _, tr := range t.TransformList {
+		err := p.ApplyTransform(tr)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// referenced is Referenced for the package as it is now.
+func (p *Package) referenced(trList []Transform) bool {
+
+	names := make(map[string]bool)
+	for _, tr := range trList {
+		switch t := tr.(type) {
+		case *RemoveFuncDeclTransform:
+			names[t.Name] = true
+		case *RemoveTypeDeclTransform:
+			names[t.Name] = true
+		case *RemoveVarConstDeclTransform:
+			for _, n := range t.NameList {
+				names[n] = true
+			}
+		}
+	}
+
+	// whether decl is one of those being removed
+	removed := func(decl ast.Decl) bool {
+		for _, tr := range trList {
+			switch t := tr.(type) {
+			case *RemoveFuncDeclTransform:
+				if fd, ok := decl.(*ast.FuncDecl); ok {
+					if recv, name := splitFuncDecl(fd); recv == t.ReceiverType && name == t.Name {
+						return true
+					}
+				}
+			case *RemoveTypeDeclTransform:
+				if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.TYPE {
+					for _, id := range declIdents(gd) {
+						if id.Name == t.Name {
+							return true
+						}
+					}
+				}
+			case *RemoveVarConstDeclTransform:
+				if gd, ok := decl.(*ast.GenDecl); ok && (gd.Tok == token.VAR || gd.Tok == token.CONST) {
+					for _, id := range declIdents(gd) {
+						if stringIn(id.Name, t.NameList) {
+							return true
+						}
+					}
+				}
+			}
+		}
+		return false
+	}
+
+	for _, af := range p.astf {
+		for _, decl := range af.Decls {
+			if removed(decl) {
+				continue
+			}
+			if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+				continue
+			}
+			declared := make(map[*ast.Ident]bool)
+			for _, id := range declIdents(decl) {
+				declared[id] = true
+			}
+			if fd, ok := decl.(*ast.FuncDecl); ok {
+				declared[fd.Name] = true // a method with the same name isn't a reference
+			}
+			found := false
+			ast.Inspect(decl, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok && names[id.Name] && !declared[id] {
+					found = true
+				}
+				return !found
+			})
+			if found {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// declIdents returns the names declared by a top level declaration, other than methods.
+func declIdents(decl ast.Decl) []*ast.Ident {
+	var ret []*ast.Ident
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv == nil {
+			ret = append(ret, d.Name)
+		}
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				ret = append(ret, s.Name)
+			case *ast.ValueSpec:
+				ret = append(ret, s.Names...)
+			}
+		}
+	}
+	return ret
+}
+
+func stringIn(s string, list []string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,262 @@
+package srcedit
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+func (p *Package) applyAddStructField(t *AddStructFieldTransform) error {
+
+	filename, typeDecl := p.findTypeDecl(t.TypeName)
+	if typeDecl == nil {
+		return fmt.Errorf("type %q: %w", t.TypeName, ErrNotFound)
+	}
+	if hasKeep(typeDecl.Doc) {
+		return nil
+	}
+	st, ok := typeDecl.Specs[0].(*ast.TypeSpec).Type.(*ast.StructType)
+	if !ok {
+		return fmt.Errorf("type %q is not a struct", t.TypeName)
+	}
+
+	src, newSt, err := parseStructText("type snippet__ struct {\n" + p.renameImports(filename, t.Text) + "\n}\n")
+	if err != nil {
+		return err
+	}
+	if newSt == nil || len(newSt.fields.List) == 0 {
+		return fmt.Errorf("no field found in %q", t.Text)
+	}
+
+	return p.mergeStruct(filename, t.TypeName, typeDecl, st, src, newSt, t.Replace)
+}
+
+// mergeTypeDecl merges the fields of the struct type in text into the existing struct
+// declaration typeDecl, and reports whether it did.  If either one is not a struct,
+// nothing is changed.  The tags of fields already there are only updated if updateTags is true.
+func (p *Package) mergeTypeDecl(filename, name string, typeDecl *ast.GenDecl, text string, updateTags bool) (bool, error) {
+
+	st, ok := typeDecl.Specs[0].(*ast.TypeSpec).Type.(*ast.StructType)
+	if !ok {
+		return false, nil
+	}
+
+	src, newSt, err := parseStructText(text)
+	if err != nil {
+		return false, err
+	}
+	if newSt == nil {
+		return false, nil
+	}
+
+	return true, p.mergeStruct(filename, name, typeDecl, st, src, newSt, updateTags)
+}
+
+// mergeStruct merges the fields of newSt into st, the struct declared by typeDecl.  Fields are
+// added even if it was changed by hand since it was generated, but then the tags of the fields
+// already there are left alone, and recorded as a conflict if updateTags would have changed them.
+// Its marker is updated afterwards if it matched before.
+func (p *Package) mergeStruct(filename, name string, typeDecl *ast.GenDecl, st *ast.StructType, src string, newSt *parsedStruct, updateTags bool) error {
+
+	clean := markerMatches(p.nodeCode(filename, typeDecl), typeDecl.Doc)
+	owner := p.editedOwner(filename, typeDecl, typeDecl.Doc)
+
+	tagsLeft, err := p.mergeStructFields(filename, st, src, newSt, updateTags && owner == "")
+	if err != nil {
+		return err
+	}
+	if updateTags && tagsLeft && owner != "" {
+		p.conflicts = append(p.conflicts, Conflict{Filename: filename, Name: name, Owner: owner})
+	}
+
+	if !clean {
+		return nil
+	}
+	return p.remarkTypeDecl(name)
+}
+
+// parsedStruct is a struct type parsed from transform text.
+type parsedStruct struct {
+	fset   *token.FileSet
+	fields *ast.FieldList
+}
+
+// parseStructText parses a type declaration and returns the source it was parsed from and
+// the struct type, or nil if the type is not a struct.
+func parseStructText(text string) (string, *parsedStruct, error) {
+
+	src := "package snippet__\n\n" + text
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return "", nil, fmt.Errorf("parsing struct: %w", err)
+	}
+
+	for _, decl := range f.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE || len(genDecl.Specs) != 1 {
+			continue
+		}
+		st, ok := genDecl.Specs[0].(*ast.TypeSpec).Type.(*ast.StructType)
+		if !ok {
+			return src, nil, nil
+		}
+		return src, &parsedStruct{fset: fset, fields: st.Fields}, nil
+	}
+
+	return src, nil, nil
+}
+
+// srcEdit replaces b[start:end] with text.
+type srcEdit struct {
+	start, end int
+	text       string
+}
+
+// mergeStructFields adds the fields in newSt which are not in st, keeping their doc and line
+// comments, just before the closing brace of st.  If updateTags is true, fields in both get the
+// tag from newSt, otherwise it reports whether any of them has a different tag there.  Other
+// fields in st, and their comments, are left as they are.
+func (p *Package) mergeStructFields(filename string, st *ast.StructType, src string, newSt *parsedStruct, updateTags bool) (tagsLeft bool, err error) {
+
+	b := p.fileBytes[filename]
+	offset := func(pos token.Pos) int { return p.fset.Position(pos).Offset }
+	newOffset := func(pos token.Pos) int { return newSt.fset.Position(pos).Offset }
+
+	existing := make(map[string]*ast.Field)
+	for _, f := range st.Fields.List {
+		for _, k := range fieldKeys(f) {
+			existing[k] = f
+		}
+	}
+
+	var edits []srcEdit
+	var add []string
+
+	for _, nf := range newSt.fields.List {
+
+		keys := fieldKeys(nf)
+		var ef *ast.Field
+		for _, k := range keys {
+			if existing[k] != nil {
+				ef = existing[k]
+				break
+			}
+		}
+
+		if ef != nil {
+			if nf.Tag == nil || (ef.Tag != nil && ef.Tag.Value == nf.Tag.Value) {
+				continue
+			}
+			if !updateTags {
+				tagsLeft = true
+				continue
+			}
+			if ef.Tag != nil {
+				edits = append(edits, srcEdit{start: offset(ef.Tag.Pos()), end: offset(ef.Tag.End()), text: nf.Tag.Value})
+			} else {
+				o := offset(ef.Type.End())
+				edits = append(edits, srcEdit{start: o, end: o, text: " " + nf.Tag.Value})
+			}
+			continue
+		}
+
+		for _, k := range keys {
+			existing[k] = nf
+		}
+
+		start, end := newOffset(nf.Pos()), newOffset(nf.End())
+		if nf.Doc != nil {
+			start = newOffset(nf.Doc.Pos())
+		}
+		if nf.Comment != nil {
+			end = newOffset(nf.Comment.End())
+		}
+		add = append(add, dedent(src[start:end], lineIndent([]byte(src), start)))
+	}
+
+	if len(add) > 0 {
+
+		indent := lineIndent(b, offset(st.Pos())) + "\t"
+		if len(st.Fields.List) > 0 {
+			indent = lineIndent(b, offset(st.Fields.List[0].Pos()))
+		}
+
+		var buf bytes.Buffer
+		closing := offset(st.Fields.Closing)
+		start := lineStart(b, closing)
+		if opening := offset(st.Fields.Opening); start <= opening {
+			start = closing // struct{} on one line
+			buf.WriteByte('\n')
+			if opening > 0 && b[opening-1] != ' ' {
+				edits = append(edits, srcEdit{start: opening, end: opening, text: " "})
+			}
+		}
+		for _, text := range add {
+			for _, line := range strings.Split(text, "\n") {
+				if strings.TrimSpace(line) != "" {
+					buf.WriteString(indent)
+					buf.WriteString(line)
+				}
+				buf.WriteByte('\n')
+			}
+		}
+		edits = append(edits, srcEdit{start: start, end: start, text: buf.String()})
+	}
+
+	if len(edits) == 0 {
+		return tagsLeft, nil
+	}
+
+	out := applyEdits(b, edits)
+	p.fileBytes[filename] = out
+	return tagsLeft, p.writeFileNamed(filename, out)
+}
+
+// applyEdits returns a copy of b with edits applied, which must not overlap.
+func applyEdits(b []byte, edits []srcEdit) []byte {
+	// apply from the end so earlier offsets stay valid
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	out := append([]byte(nil), b...)
+	for _, e := range edits {
+		out = append(out[:e.start], append([]byte(e.text), out[e.end:]...)...)
+	}
+	return out
+}
+
+// fieldKeys returns the names a field is known by: its names, or for an embedded
+// field the name of the type.
+func fieldKeys(f *ast.Field) []string {
+	if len(f.Names) > 0 {
+		ret := make([]string, 0, len(f.Names))
+		for _, n := range f.Names {
+			ret = append(ret, n.Name)
+		}
+		return ret
+	}
+	typ := f.Type
+	if se, ok := typ.(*ast.StarExpr); ok {
+		typ = se.X
+	}
+	switch x := typ.(type) {
+	case *ast.Ident:
+		return []string{x.Name}
+	case *ast.SelectorExpr:
+		return []string{x.Sel.Name}
+	}
+	return nil
+}
+
+// dedent removes indent from the start of each line after the first.
+func dedent(text, indent string) string {
+	lines := strings.Split(text, "\n")
+	for i := 1; i < len(lines); i++ {
+		lines[i] = strings.TrimPrefix(lines[i], indent)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -6,14 +6,17 @@ import (
 	"fmt"
 	"io/fs"
 	"io/ioutil"
+	"strings"
 
 	"github.com/sergi/go-diff/diffmatchpatch"
 )
 
 // Run walks the out fs and compares to in, rooted at rootDir ("." means root of fs),
 // and report differences in a map of filenames to diff output.
-// The outType indicates the output format, "html" will produce HTML output, any other
-// value will product "term" output which is console/terminal output with highlighting.
+// The outType indicates the output format, "html" will produce HTML output, "text" will
+// produce plain text showing changed lines with a "-" or "+" prefix and a few lines of
+// context, any other value will product "term" output which is console/terminal output
+// with highlighting.
 func Run(in, out fs.FS, rootDir string, outType string) (map[string]string, error) {
 
 	ret := make(map[string]string)
@@ -91,6 +94,10 @@ func diffContents(from, to string, outType string) string {
 
 	dmp := diffmatchpatch.New()
 
+	if outType == "text" {
+		return diffLines(dmp, from, to)
+	}
+
 	diffs := dmp.DiffMain(from, to, true)
 
 	diffs = dmp.DiffCleanupSemanticLossless(diffs)
@@ -101,3 +108,87 @@ func diffContents(from, to string, outType string) string {
 
 	return dmp.DiffPrettyText(diffs)
 }
+
+// contextLines is the number of unchanged lines shown around each change in "text" output.
+const contextLines = 3
+
+// diffLines returns a line by line diff of from and to, with unchanged lines
+// away from any change left out.
+func diffLines(dmp *diffmatchpatch.DiffMatchPatch, from, to string) string {
+
+	// each distinct line becomes one rune so the diff is done line by line
+	// (DiffLinesToChars is not used, it does not work in the version of go-diff we use)
+	var lineText []string
+	lineRune := make(map[string]rune)
+	toRunes := func(text string) []rune {
+		var ret []rune
+		for _, l := range strings.SplitAfter(text, "\n") {
+			if l == "" {
+				continue
+			}
+			l = strings.TrimSuffix(l, "\n")
+			r, ok := lineRune[l]
+			if !ok {
+				r = rune(len(lineText))
+				if r >= 0xD800 { // skip surrogates, they are not valid runes
+					r += 0x800
+				}
+				lineRune[l] = r
+				lineText = append(lineText, l)
+			}
+			ret = append(ret, r)
+		}
+		return ret
+	}
+	runeLine := func(r rune) string {
+		if r >= 0xD800 {
+			r -= 0x800
+		}
+		return lineText[r]
+	}
+
+	diffs := dmp.DiffMainRunes(toRunes(from), toRunes(to), false)
+
+	type line struct {
+		prefix byte
+		text   string
+	}
+	var lines []line
+	for _, d := range diffs {
+		prefix := byte(' ')
+		switch d.Type {
+		case diffmatchpatch.DiffDelete:
+			prefix = '-'
+		case diffmatchpatch.DiffInsert:
+			prefix = '+'
+		}
+		for _, r := range d.Text {
+			lines = append(lines, line{prefix: prefix, text: runeLine(r)})
+		}
+	}
+
+	// show each line within contextLines of a change
+	show := make([]bool, len(lines))
+	for i, l := range lines {
+		if l.prefix == ' ' {
+			continue
+		}
+		for j := i - contextLines; j <= i+contextLines; j++ {
+			if j >= 0 && j < len(lines) {
+				show[j] = true
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	for i, l := range lines {
+		if !show[i] {
+			continue
+		}
+		if i > 0 && !show[i-1] {
+			buf.WriteString("...\n")
+		}
+		fmt.Fprintf(&buf, "%c%s\n", l.prefix, l.text)
+	}
+	return buf.String()
+}
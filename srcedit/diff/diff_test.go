@@ -30,6 +30,24 @@ func TestRunDiff(t *testing.T) {
 
 }
 
+func TestRunDiffText(t *testing.T) {
+
+	in := memfs.New()
+	out := memfs.New()
+
+	must(t, in.WriteFile("a.go", []byte("package a\n\n// 1\n// 2\n// 3\n// 4\n// 5\nfunc b() {\n}\n"), 0644))
+	must(t, out.WriteFile("a.go", []byte("package a\n\n// 1\n// 2\n// 3\n// 4\n// 5\nfunc c() {\n}\n"), 0644))
+
+	m, err := Run(in, out, ".", "text")
+	must(t, err)
+
+	expected := "...\n // 3\n // 4\n // 5\n-func b() {\n+func c() {\n }\n"
+	if m["a.go"] != expected {
+		t.Errorf("unexpected diff:\n%s\nexpected:\n%s", m["a.go"], expected)
+	}
+
+}
+
 func must(t *testing.T, err error) {
 	t.Helper()
 	if err != nil {
@@ -58,6 +58,16 @@ func (dir DirFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
 	return os.WriteFile(fullPath, data, perm)
 }
 
+// Rename calls os.Rename with the appropriate prefix, implementing Renamer.
+func (dir DirFS) Rename(oldname, newname string) error {
+	return os.Rename(filepath.Join(string(dir), filepath.FromSlash(oldname)), filepath.Join(string(dir), filepath.FromSlash(newname)))
+}
+
+// Remove calls os.Remove with the appropriate prefix, implementing Remover.
+func (dir DirFS) Remove(name string) error {
+	return os.Remove(filepath.Join(string(dir), filepath.FromSlash(name)))
+}
+
 // MkdirAll calls os.MkdirAll with the appropriate prefix.
 func (dir DirFS) MkdirAll(path string, perm os.FileMode) error {
 	return os.MkdirAll(filepath.Join(string(dir), path), perm)
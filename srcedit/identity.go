@@ -0,0 +1,81 @@
+package srcedit
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// Some declarations can't be told apart by name: a package can have any number of init
+// functions, and of var or const declarations that only declare "_", such as the assertion
+// `var _ I = (*T)(nil)`.  These are identified by their code instead (ignoring formatting and
+// the doc comment), so each different one is added once, an identical one is left as it is (or
+// replaced, e.g. to update its doc comment), and others are never touched.
+
+// isInitFunc reports whether a func with this receiver type and name is an init function.
+func isInitFunc(receiverType, name string) bool {
+	return receiverType == "" && name == "init"
+}
+
+// allBlank reports whether names only has "_" in it.
+func allBlank(names []string) bool {
+	for _, n := range names {
+		if n != "_" {
+			return false
+		}
+	}
+	return len(names) > 0
+}
+
+// codeKey returns what a declaration with text, which may include a doc comment, is identified by.
+func codeKey(text string) string {
+	_, code := splitDoc(text)
+	return declHash(code)
+}
+
+// findFuncCode is like findFunc but only finds a function whose code matches text.
+func (p *Package) findFuncCode(recv, name, text string) (fileName string, funcDecl *ast.FuncDecl) {
+
+	key := codeKey(text)
+	for fn, af := range p.astf {
+		for _, decl := range af.Decls {
+			fdecl, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			recvTypeExpr, funcName := splitFuncDecl(fdecl)
+			if recvTypeExpr == recv && funcName == name && declHash(p.nodeCode(fn, fdecl)) == key {
+				return fn, fdecl
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// findBlankDeclCode returns the var or const declaration, per tok, which only declares "_"
+// and whose code matches text.
+func (p *Package) findBlankDeclCode(tok token.Token, text string) (filename string, names []string, varOrConstDecl *ast.GenDecl) {
+
+	key := codeKey(text)
+	for fn, af := range p.astf {
+		for _, decl := range af.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != tok {
+				continue
+			}
+			names = names[:0]
+			for _, spec := range genDecl.Specs {
+				if valueSpec, ok := spec.(*ast.ValueSpec); ok {
+					for _, n := range valueSpec.Names {
+						names = append(names, n.Name)
+					}
+				}
+			}
+			if allBlank(names) && declHash(p.nodeCode(fn, genDecl)) == key {
+				return fn, names, genDecl
+			}
+		}
+	}
+
+	return "", nil, nil
+}
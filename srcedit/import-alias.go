@@ -0,0 +1,148 @@
+package srcedit
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+)
+
+// ImportAlias is an import that was given a different name than the one asked for (or the one
+// the package would have), because that name was already taken in the file, by an import of
+// another package or a declaration in the package, or because the package was already imported
+// under another name.  Code added to the file afterward is changed to use the alias.
+type ImportAlias struct {
+	Filename string // file in the package
+	Path     string // import path
+	Name     string // the name that was asked for, e.g. "store"
+	Alias    string // the name it was imported as, e.g. "store2"
+}
+
+func (a ImportAlias) String() string {
+	return fmt.Sprintf("%s imports %q as %s since %s is taken, the code added to it uses %s", a.Filename, a.Path, a.Alias, a.Name, a.Alias)
+}
+
+// ImportAliases returns the imports given a different name so far, see ImportAlias.
+func (p *Package) ImportAliases() []ImportAlias {
+	var ret []ImportAlias
+	for _, fn := range sortedKeysAliases(p.aliases) {
+		start := len(ret)
+		for _, a := range p.aliases[fn] {
+			ret = append(ret, a)
+		}
+		fileAliases := ret[start:]
+		sort.Slice(fileAliases, func(i, j int) bool { return fileAliases[i].Name < fileAliases[j].Name })
+	}
+	return ret
+}
+
+// importLocalName returns the name to import t.Path as in t.Filename ("" for none), and whether
+// it is already imported there.  If the name is taken, a number is added to it, e.g. "store2",
+// and the alias is recorded.
+func (p *Package) importLocalName(t *ImportTransform) (name string, imported bool) {
+
+	want := t.Name
+	if want == "" {
+		want = importPathName(t.Path)
+	}
+	if want == "" || want == "_" || want == "." {
+		return t.Name, false
+	}
+
+	taken := make(map[string]bool)
+	if af := p.astf[t.Filename]; af != nil {
+		for _, ispec := range af.Imports {
+			n := importName(ispec)
+			if ipath, _ := strconv.Unquote(ispec.Path.Value); ipath == t.Path && n != "" && n != "_" && n != "." {
+				p.recordAlias(t, want, n)
+				return n, true
+			}
+			taken[n] = true
+		}
+	}
+	for n := range p.pkgNames() {
+		taken[n] = true
+	}
+
+	if !taken[want] {
+		return t.Name, false
+	}
+	name = want
+	for i := 2; taken[name]; i++ {
+		name = want + strconv.Itoa(i)
+	}
+	p.recordAlias(t, want, name)
+	return name, false
+}
+
+// recordAlias records that t.Path is imported as alias in t.Filename, if it isn't want.
+func (p *Package) recordAlias(t *ImportTransform, want, alias string) {
+	if want == alias {
+		return
+	}
+	if p.aliases == nil {
+		p.aliases = make(map[string]map[string]ImportAlias)
+	}
+	if p.aliases[t.Filename] == nil {
+		p.aliases[t.Filename] = make(map[string]ImportAlias)
+	}
+	p.aliases[t.Filename][want] = ImportAlias{Filename: t.Filename, Path: t.Path, Name: want, Alias: alias}
+}
+
+// renameImports returns text, a declaration, statements or struct fields to be added to fname,
+// with references to packages imported under an alias there changed to use it, e.g. store.X
+// becomes store2.X.
+func (p *Package) renameImports(fname, text string) string {
+
+	aliases := p.aliases[fname]
+	if len(aliases) == 0 {
+		return text
+	}
+
+	// whichever of these it parses as
+	wraps := [][2]string{
+		{"package p\n", ""},
+		{"package p\nfunc _() {\n", "\n}"},
+		{"package p\ntype _ struct {\n", "\n}"},
+	}
+	for _, wrap := range wraps {
+
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "", wrap[0]+text+wrap[1], 0)
+		if err != nil {
+			continue
+		}
+
+		// the X in X.Y where X isn't declared in the text
+		var edits []srcEdit
+		ast.Inspect(f, func(n ast.Node) bool {
+			se, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			id, ok := se.X.(*ast.Ident)
+			if !ok || id.Obj != nil {
+				return true
+			}
+			if a, ok := aliases[id.Name]; ok {
+				start := fset.Position(id.Pos()).Offset - len(wrap[0])
+				edits = append(edits, srcEdit{start: start, end: start + len(id.Name), text: a.Alias})
+			}
+			return true
+		})
+		return string(applyEdits([]byte(text), edits))
+	}
+
+	return text
+}
+
+func sortedKeysAliases(m map[string]map[string]ImportAlias) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
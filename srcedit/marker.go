@@ -0,0 +1,236 @@
+package srcedit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"regexp"
+	"strings"
+)
+
+// Declarations written by a transform with an Owner get a marker comment as the last line of their
+// doc comment, with a hash of the declaration as it was generated:
+//
+//	// gocode:generated sqlcrud/TYPEInsert h=3f2a9c0d1b4e5f60
+//
+// When the declaration is to be replaced or removed later, it is left alone if its text no longer
+// matches the hash, i.e. it has been changed by hand, and this is reported as a Conflict.  Structs
+// changed by hand still get new fields merged into them, only their existing fields are left alone.
+// A declaration with a "// gocode:keep" line in its doc comment is always left alone.  Declarations
+// without a marker are replaced and removed as before.
+const (
+	markerGenerated = "gocode:generated"
+	markerKeep      = "gocode:keep"
+)
+
+var markerRE = regexp.MustCompile(`^//\s*gocode:generated\s+(\S+)\s+h=([0-9a-f]+)\s*$`)
+
+// Conflict is a declaration that was left alone instead of being replaced or removed, because it
+// was changed by hand since it was generated.
+type Conflict struct {
+	Filename string // file in the package
+	Name     string // the declaration, e.g. "WidgetStore" or "(*WidgetStore).Insert"
+	Owner    string // what generated it, from its marker
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s in %s was changed by hand since %s generated it, so it was left alone (delete its %s comment to have it replaced)",
+		c.Name, c.Filename, c.Owner, markerGenerated)
+}
+
+// Conflicts returns the declarations left alone so far because they were changed by hand.
+func (p *Package) Conflicts() []Conflict {
+	return p.conflicts
+}
+
+// markText returns the declaration text with a marker for owner added as the last line of its doc
+// comment, replacing any marker it already has.
+func markText(text, owner string) string {
+	doc, code := splitDoc(text)
+	return strings.Join(append(doc, markerLine(owner, declHash(code)), code), "\n")
+}
+
+// markerLine returns the marker comment for owner with hash h.
+func markerLine(owner, h string) string {
+	return fmt.Sprintf("// %s %s h=%s", markerGenerated, owner, h)
+}
+
+// markerMatches reports whether doc has a marker with the hash of code, i.e. the declaration
+// hasn't been changed by hand since it was generated.
+func markerMatches(code string, doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if m := markerRE.FindStringSubmatch(strings.TrimSpace(c.Text)); m != nil {
+			return declHash(code) == m[2]
+		}
+	}
+	return false
+}
+
+// remarkTypeDecl updates the hash in the marker of the type declaration name after gocode
+// changed it in place, e.g. merged fields into it, so it isn't taken for one changed by hand.
+// A declaration without a marker is left without one.
+func (p *Package) remarkTypeDecl(name string) error {
+
+	err := p.sync()
+	if err != nil {
+		return err
+	}
+	filename, typeDecl := p.findTypeDecl(name)
+	if typeDecl == nil {
+		return nil
+	}
+	return p.remark(filename, typeDecl, typeDecl.Doc)
+}
+
+// remarkFuncDecl is remarkTypeDecl for a func or method, e.g. after statements were added to it.
+func (p *Package) remarkFuncDecl(receiverType, name string) error {
+
+	err := p.sync()
+	if err != nil {
+		return err
+	}
+	filename, funcDecl := p.findFunc(receiverType, name)
+	if funcDecl == nil {
+		return nil
+	}
+	return p.remark(filename, funcDecl, funcDecl.Doc)
+}
+
+// remark updates the hash in the marker in doc, if any, to match the code of node.
+func (p *Package) remark(filename string, node ast.Node, doc *ast.CommentGroup) error {
+
+	if doc == nil {
+		return nil
+	}
+
+	for _, c := range doc.List {
+		m := markerRE.FindStringSubmatch(strings.TrimSpace(c.Text))
+		if m == nil {
+			continue
+		}
+		h := declHash(p.nodeCode(filename, node))
+		if h == m[2] {
+			return nil
+		}
+		start, end := p.fset.Position(c.Pos()).Offset, p.fset.Position(c.End()).Offset
+		out := applyEdits(p.fileBytes[filename], []srcEdit{{start: start, end: end, text: markerLine(m[1], h)}})
+		return p.writeFileNamed(filename, out)
+	}
+
+	return nil
+}
+
+// splitDoc splits the text of a declaration into the lines of its doc comment, without any
+// marker, and its code.
+func splitDoc(text string) (doc []string, code string) {
+
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if !markerRE.MatchString(strings.TrimSpace(line)) {
+			lines = append(lines, line)
+		}
+	}
+
+	i := 0
+	for i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), "//") {
+		i++
+	}
+	return lines[:i], strings.Join(lines[i:], "\n")
+}
+
+// declHash returns the hash of a declaration, without its doc comment, for a marker.  The code is
+// gofmt'ed first and whitespace is ignored, so formatting doesn't change it.
+func declHash(code string) string {
+	if b, err := format.Source([]byte(code)); err == nil {
+		code = string(b)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(strings.Fields(code), "")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// hasKeep reports whether doc has a gocode:keep line.
+func hasKeep(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.HasPrefix(strings.TrimSpace(strings.TrimPrefix(c.Text, "//")), markerKeep) {
+			return true
+		}
+	}
+	return false
+}
+
+// protected reports whether the existing declaration node with doc comment doc must be left alone
+// instead of being replaced or removed: it has a gocode:keep comment, or it has a marker and its text
+// no longer matches the hash in it, which is recorded as a conflict.
+func (p *Package) protected(filename, name string, node ast.Node, doc *ast.CommentGroup) bool {
+
+	if hasKeep(doc) {
+		return true
+	}
+	owner := p.editedOwner(filename, node, doc)
+	if owner == "" {
+		return false
+	}
+	p.conflicts = append(p.conflicts, Conflict{Filename: filename, Name: name, Owner: owner})
+	return true
+}
+
+// replaceProtected is protected for replacing node with the declaration text.  One changed by
+// hand is still left alone, but only recorded as a conflict if text would have changed its code.
+func (p *Package) replaceProtected(filename, name string, node ast.Node, doc *ast.CommentGroup, text string) bool {
+
+	if hasKeep(doc) {
+		return true
+	}
+	owner := p.editedOwner(filename, node, doc)
+	if owner == "" {
+		return false
+	}
+	if _, code := splitDoc(text); declHash(code) != declHash(p.nodeCode(filename, node)) {
+		p.conflicts = append(p.conflicts, Conflict{Filename: filename, Name: name, Owner: owner})
+	}
+	return true
+}
+
+// editedOwner returns the owner in the marker in doc if the code of node no longer matches its
+// hash, i.e. it was changed by hand since it was generated, otherwise "".
+func (p *Package) editedOwner(filename string, node ast.Node, doc *ast.CommentGroup) string {
+
+	if doc == nil {
+		return ""
+	}
+
+	for _, c := range doc.List {
+		m := markerRE.FindStringSubmatch(strings.TrimSpace(c.Text))
+		if m == nil {
+			continue
+		}
+		if declHash(p.nodeCode(filename, node)) == m[2] {
+			return ""
+		}
+		return m[1]
+	}
+
+	return ""
+}
+
+// nodeCode returns the code of node in filename, without its doc comment.
+func (p *Package) nodeCode(filename string, node ast.Node) string {
+	b := p.fileBytes[filename]
+	return string(b[p.fset.Position(node.Pos()).Offset:p.fset.Position(node.End()).Offset])
+}
+
+// funcDisplayName returns how a func or method is named in a Conflict.
+func funcDisplayName(receiverType, name string) string {
+	if receiverType == "" {
+		return name
+	}
+	return "(" + receiverType + ")." + name
+}
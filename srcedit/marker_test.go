@@ -0,0 +1,205 @@
+package srcedit
+
+import (
+	"io/fs"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/psanford/memfs"
+)
+
+func TestMarkers(t *testing.T) {
+
+	type tcase struct {
+		name       string
+		edit       func(s string) string // made by hand to a.go after it was generated
+		transforms []Transform
+		econtains  []string // a.go has these afterward
+		enot       []string // and not these
+		econflicts int
+	}
+
+	replaceF := &AddFuncDeclTransform{Filename: "a.go", Name: "F", Text: "// F is generated.\nfunc F() int { return 3 }", Owner: "test/F", Replace: true}
+	lineF := &AddFuncLineTransform{Name: "F", Text: "println()"}
+
+	tcList := []tcase{
+		{
+			name:       "replace_unchanged",
+			edit:       func(s string) string { return strings.Replace(s, "return 1", "return   1", 1) },
+			transforms: []Transform{replaceF},
+			econtains:  []string{"return 3", "// gocode:generated test/F h=" + declHash("func F() int { return 3 }")},
+			enot:       []string{"return 1"},
+		},
+		{
+			name:       "replace_edited",
+			edit:       func(s string) string { return strings.Replace(s, "return 1", "return 2", 1) },
+			transforms: []Transform{replaceF},
+			econtains:  []string{"return 2"},
+			enot:       []string{"return 3"},
+			econflicts: 1,
+		},
+		{
+			name:       "replace_edited_same",
+			edit:       func(s string) string { return strings.Replace(s, "return 1", "return 3", 1) },
+			transforms: []Transform{replaceF},
+			econtains:  []string{"return 3"},
+		},
+		{
+			name: "replace_unmarked",
+			edit: func(s string) string {
+				s = strings.Replace(s, "return 1", "return 2", 1)
+				return regexp.MustCompile(`(?m)^// gocode:generated .*\n`).ReplaceAllString(s, "")
+			},
+			transforms: []Transform{replaceF},
+			econtains:  []string{"return 3"},
+			enot:       []string{"return 2"},
+		},
+		{
+			name: "replace_keep",
+			edit: func(s string) string {
+				return strings.Replace(s, "// F is generated.", "// F is generated.\n// gocode:keep", 1)
+			},
+			transforms: []Transform{replaceF},
+			econtains:  []string{"return 1", "gocode:keep"},
+			enot:       []string{"return 3"},
+		},
+		{
+			name:       "remove_edited",
+			edit:       func(s string) string { return strings.Replace(s, "return 1", "return 2", 1) },
+			transforms: []Transform{&RemoveFuncDeclTransform{Name: "F"}, &RemoveTypeDeclTransform{Name: "T"}},
+			econtains:  []string{"return 2"},
+			enot:       []string{"type T"},
+			econflicts: 1,
+		},
+		{
+			name: "merge_keep",
+			edit: func(s string) string { return strings.Replace(s, "type T struct", "// gocode:keep\ntype T struct", 1) },
+			transforms: []Transform{
+				&AddTypeDeclTransform{Filename: "a.go", Name: "T", Text: "type T struct { A int; B int }", Owner: "test/T", Merge: true},
+				&AddStructFieldTransform{TypeName: "T", Text: "C int"},
+			},
+			econtains: []string{"type T struct"},
+			enot:      []string{"B int", "C int"},
+		},
+		{
+			name: "merge_then_remove",
+			edit: func(s string) string { return s },
+			transforms: []Transform{
+				&AddTypeDeclTransform{Filename: "a.go", Name: "T", Text: "type T struct { A int; B int }", Owner: "test/T", Merge: true},
+				&AddStructFieldTransform{TypeName: "T", Text: "C int"},
+				&RemoveTypeDeclTransform{Name: "T"},
+			},
+			enot: []string{"type T"},
+		},
+		{
+			name: "merge_edited",
+			edit: func(s string) string { return strings.Replace(s, "A int", "A int\n\tX string", 1) },
+			transforms: []Transform{
+				&AddTypeDeclTransform{Filename: "a.go", Name: "T", Text: "type T struct { A int; B int }", Owner: "test/T", Merge: true},
+			},
+			econtains: []string{"X string", "B int"},
+		},
+		{
+			name: "merge_edited_tags_replace",
+			edit: func(s string) string { return strings.Replace(s, "A int", "A int\n\tX string", 1) },
+			transforms: []Transform{
+				&AddTypeDeclTransform{Filename: "a.go", Name: "T", Text: "type T struct { A int `json:\"a\"`; B int }", Owner: "test/T", Merge: true, Replace: true},
+			},
+			econtains:  []string{"X string", "B int"},
+			enot:       []string{`json:"a"`},
+			econflicts: 1,
+		},
+		{
+			name: "merge_edited_unchanged",
+			edit: func(s string) string { return strings.Replace(s, "A int", "A int\n\tX string", 1) },
+			transforms: []Transform{
+				&AddTypeDeclTransform{Filename: "a.go", Name: "T", Text: "type T struct { A int }", Owner: "test/T", Merge: true, Replace: true},
+			},
+			econtains: []string{"X string"},
+		},
+		{
+			name: "merge_edited_then_remove",
+			edit: func(s string) string { return strings.Replace(s, "A int", "A int\n\tX string", 1) },
+			transforms: []Transform{
+				&AddTypeDeclTransform{Filename: "a.go", Name: "T", Text: "type T struct { A int; B int }", Owner: "test/T", Merge: true},
+				&RemoveTypeDeclTransform{Name: "T"},
+			},
+			econtains:  []string{"X string", "B int"},
+			econflicts: 1,
+		},
+		{
+			name:       "func_line_then_replace",
+			edit:       func(s string) string { return s },
+			transforms: []Transform{lineF, replaceF},
+			econtains:  []string{"return 3"},
+			enot:       []string{"println()", "return 1"},
+		},
+		{
+			name:       "func_line_edited",
+			edit:       func(s string) string { return strings.Replace(s, "return 1", "return 2", 1) },
+			transforms: []Transform{lineF},
+			econtains:  []string{"return 2"},
+			enot:       []string{"println()"},
+			econflicts: 1,
+		},
+		{
+			name: "merge_tags",
+			edit: func(s string) string { return s },
+			transforms: []Transform{
+				&AddTypeDeclTransform{Filename: "a.go", Name: "T", Text: "type T struct { A int `json:\"a\"`; B int }", Owner: "test/T", Merge: true},
+			},
+			econtains: []string{"B int"},
+			enot:      []string{`json:"a"`},
+		},
+		{
+			name: "merge_tags_replace",
+			edit: func(s string) string { return s },
+			transforms: []Transform{
+				&AddTypeDeclTransform{Filename: "a.go", Name: "T", Text: "type T struct { A int `json:\"a\"`; B int }", Owner: "test/T", Merge: true, Replace: true},
+			},
+			econtains: []string{"B int", `json:"a"`},
+		},
+	}
+
+	for _, tc := range tcList {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+
+			fsys := memfs.New()
+			must(t, fsys.MkdirAll("a", 0755))
+			p := NewPackage(fsys, fsys, "test1", "a")
+			must(t, p.ApplyTransforms(
+				&AddFuncDeclTransform{Filename: "a.go", Name: "F", Text: "// F is generated.\nfunc F() int { return 1 }", Owner: "test/F"},
+				&AddTypeDeclTransform{Filename: "a.go", Name: "T", Text: "type T struct { A int }", Owner: "test/T"},
+				&GofmtTransform{FilenameList: []string{"a.go"}},
+			))
+			if len(p.Conflicts()) != 0 {
+				t.Fatalf("unexpected conflicts generating: %v", p.Conflicts())
+			}
+
+			b, err := fs.ReadFile(fsys, "a/a.go")
+			must(t, err)
+			must(t, fsys.WriteFile("a/a.go", []byte(tc.edit(string(b))), 0644))
+
+			p = NewPackage(fsys, fsys, "test1", "a")
+			must(t, p.ApplyTransforms(tc.transforms...))
+
+			b, err = fs.ReadFile(fsys, "a/a.go")
+			must(t, err)
+			for _, s := range tc.econtains {
+				if !strings.Contains(string(b), s) {
+					t.Errorf("a.go does not contain %q:\n%s", s, b)
+				}
+			}
+			for _, s := range tc.enot {
+				if strings.Contains(string(b), s) {
+					t.Errorf("a.go contains %q:\n%s", s, b)
+				}
+			}
+			if len(p.Conflicts()) != tc.econflicts {
+				t.Errorf("expected %d conflicts, got: %v", tc.econflicts, p.Conflicts())
+			}
+		})
+	}
+}
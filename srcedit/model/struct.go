@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"go/ast"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/d0sbit/gocode/srcedit"
@@ -74,6 +75,28 @@ func (sf *StructField) TagFirst(tagName string) (n string) {
 	return n
 }
 
+// IsPK returns true if this field is (part of) the primary key.
+func (sf *StructField) IsPK() bool {
+	return sf.isPK
+}
+
+// Tag returns the struct tag without the surrounding quotes, e.g. `db:"id"` gives db:"id".
+func (sf *StructField) Tag() string {
+	if sf.astField == nil || sf.astField.Tag == nil {
+		return ""
+	}
+	tag, err := strconv.Unquote(sf.astField.Tag.Value)
+	if err != nil {
+		return ""
+	}
+	return tag
+}
+
+// TagParts returns each struct tag key with its value split on commas.
+func (sf *StructField) TagParts() map[string][]string {
+	return sf.tagParts
+}
+
 // func (sf *StructField) BSONName() (n string) {
 // 	if len(sf.bsonTagParts) > 0 {
 // 		n = sf.bsonTagParts[0]
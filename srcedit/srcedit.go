@@ -9,15 +9,17 @@ package srcedit
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"go/ast"
+	"go/format"
 	"go/parser"
 	"go/token"
 	"io/fs"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 	"regexp"
@@ -140,7 +142,12 @@ type Package struct {
 
 	fset      *token.FileSet       // Go parser needs this
 	astf      map[string]*ast.File // each file that was parsed in the package with the filename (no path info) as the key
-	fileBytes map[string][]byte    // filename to most recently read contents
+	fileBytes map[string][]byte    // filename to most recently read or written contents
+	dirty     map[string]bool      // files written since they were parsed into astf
+	loaded    bool                 // fset, astf and fileBytes are set, see sync
+
+	conflicts []Conflict                        // declarations left alone because they were changed by hand, see Conflict
+	aliases   map[string]map[string]ImportAlias // by file and the name that was asked for, see ImportAlias
 }
 
 // NewPackage returns a new Package with the specified input and output filesystems and the specified module name/path.
@@ -184,6 +191,33 @@ func (p *Package) LocalName() string {
 	return p.localName
 }
 
+// FileHashes returns the SHA-256 of each Go file in the package, in hex, by file name.
+// This is used to tell whether the package has changed since transforms were worked out for it.
+func (p *Package) FileHashes() (map[string]string, error) {
+	err := p.load()
+	if err != nil {
+		return nil, fmt.Errorf("load failed: %w", err)
+	}
+	ret := make(map[string]string, len(p.fileBytes))
+	for fn, b := range p.fileBytes {
+		sum := sha256.Sum256(b)
+		ret[fn] = hex.EncodeToString(sum[:])
+	}
+	return ret, nil
+}
+
+// FileHash returns the SHA-256 of a file in the package, in hex, like FileHashes but for any
+// file, e.g. one changed by AddFileTransform.  An error wrapping fs.ErrNotExist is returned if
+// there is no such file.
+func (p *Package) FileHash(fname string) (string, error) {
+	b, err := p.readFile(fname)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
 // readFile will read a file from outfs if it exists there and if not from infs.
 // This way if the specified file has been modified you'll get the modified file,
 // otherwise the original unmodified one.  The filename should not have any path
@@ -272,31 +306,29 @@ func (p *Package) writeFileNamed(name string, data []byte) error {
 	if dir != "" {
 		return fmt.Errorf("name %q appears to have a directory, cannot be used with writeFileNamed", name)
 	}
-	return p.writeFile(path.Join(p.subDir, name), data, p.getFileModeOrDefault(name, 0644))
+	err := p.writeFile(path.Join(p.subDir, name), data, p.getFileModeOrDefault(name, 0644))
+	if err != nil {
+		return err
+	}
+	// parsed again before the next transform, see sync
+	if p.fileBytes != nil {
+		p.fileBytes[name] = data
+		p.dirty[name] = true
+	}
+	return nil
 }
 
-// ApplyTransforms calls ApplyTransform for each one provided.  Note that transforms
-// are not atomic and this may result in only a subset of the requested changes being applied
-// upon error.  Consider using a separate output filesystem if you're concerned about this.
+// ApplyTransforms applies each transform in turn, all or nothing: the changes are only written
+// to the output filesystem once they have all succeeded.  See ApplyPackageTransforms.
 func (p *Package) ApplyTransforms(tr ...Transform) error {
-
-	// log.Printf("ApplyTransforms, tr: %#v", tr)
-
-	for _, t := range tr {
-		err := p.ApplyTransform(t)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return ApplyPackageTransforms(PackageTransforms{Package: p, Transforms: tr})
 }
 
 // ApplyTransform will take a transform and apply it to the package,
 // writing whatever output is needed to the output FS.
 func (p *Package) ApplyTransform(tr Transform) error {
 
-	err := p.load()
+	err := p.sync()
 	if err != nil {
 		return fmt.Errorf("package load in ApplyTransform error: %w", err)
 	}
@@ -317,6 +349,13 @@ func (p *Package) ApplyTransform(tr Transform) error {
 		}
 		return nil
 
+	case *FixImportsTransform:
+		err := p.applyFixImports(t)
+		if err != nil {
+			return fmt.Errorf("applyFixImports: %w", err)
+		}
+		return nil
+
 	case *ImportTransform:
 		err := p.applyImport(t)
 		if err != nil {
@@ -324,6 +363,34 @@ func (p *Package) ApplyTransform(tr Transform) error {
 		}
 		return nil
 
+	case *AddFuncLineTransform:
+		err := p.applyAddFuncLine(t)
+		if err != nil {
+			return fmt.Errorf("applyAddFuncLine: %w", err)
+		}
+		return nil
+
+	case *RemoveUnreferencedTransform:
+		err := p.applyRemoveUnreferenced(t)
+		if err != nil {
+			return fmt.Errorf("applyRemoveUnreferenced: %w", err)
+		}
+		return nil
+
+	case *AddFileTransform:
+		err := p.applyAddFile(t)
+		if err != nil {
+			return fmt.Errorf("applyAddFile: %w", err)
+		}
+		return nil
+
+	case *AppendFileSectionTransform:
+		err := p.applyAppendFileSection(t)
+		if err != nil {
+			return fmt.Errorf("applyAppendFileSection: %w", err)
+		}
+		return nil
+
 	case *AddConstDeclTransform:
 		err := p.applyAddConstDecl(t)
 		if err != nil {
@@ -345,10 +412,45 @@ func (p *Package) ApplyTransform(tr Transform) error {
 		}
 		return nil
 
+	case *AddStructFieldTransform:
+		err := p.applyAddStructField(t)
+		if err != nil {
+			return fmt.Errorf("applyAddStructField: %w", err)
+		}
+		return nil
+
+	case *RemoveFuncDeclTransform:
+		err := p.applyRemoveFuncDecl(t)
+		if err != nil {
+			return fmt.Errorf("applyRemoveFuncDecl: %w", err)
+		}
+		return nil
+
+	case *RemoveTypeDeclTransform:
+		err := p.applyRemoveTypeDecl(t)
+		if err != nil {
+			return fmt.Errorf("applyRemoveTypeDecl: %w", err)
+		}
+		return nil
+
+	case *RemoveVarConstDeclTransform:
+		err := p.applyRemoveVarConstDecl(t)
+		if err != nil {
+			return fmt.Errorf("applyRemoveVarConstDecl: %w", err)
+		}
+		return nil
+
+	case *RemoveUnusedImportsTransform:
+		err := p.applyRemoveUnusedImports(t)
+		if err != nil {
+			return fmt.Errorf("applyRemoveUnusedImports: %w", err)
+		}
+		return nil
+
 	case *GofmtTransform:
 		err := p.applyGoFmt(t)
 		if err != nil {
-			return fmt.Errorf("applyAddFuncDecl: %w", err)
+			return fmt.Errorf("applyGoFmt: %w", err)
 		}
 		return nil
 
@@ -382,25 +484,9 @@ func (p *Package) applyGoFmt(t *GofmtTransform) error {
 			}
 		}
 
-		// run gofmt and pipe input from b
-		cmd := exec.Command("gofmt")
-		stdin, err := cmd.StdinPipe()
+		out, err := format.Source(b)
 		if err != nil {
-			return fmt.Errorf("gofmt command StdinPipe error: %w", err)
-		}
-		go func(b []byte) {
-			stdin.Write(b)
-			stdin.Close()
-		}(b)
-
-		out, err := cmd.CombinedOutput()
-		if err != nil {
-			return fmt.Errorf("gofmt command error: %w; full output: %s", err, out)
-		}
-
-		// check for minimum possible length, just as an added check to be sure we don't clobber the file here
-		if len(out) == len("package a") {
-			return fmt.Errorf("gofmt returned contents that are too short to valid: %s", out)
+			return fmt.Errorf("formatting %s: %w", fn, err)
 		}
 
 		// do not write file if no change
@@ -422,49 +508,49 @@ func (p *Package) applyGoFmt(t *GofmtTransform) error {
 
 func (p *Package) applyAddFuncDecl(t *AddFuncDeclTransform) error {
 
+	// written with the names its imports have in the file, see ImportAlias
+	text := p.renameImports(t.Filename, t.Text)
+
 	// check if the func already exists in the package (could be in another file)
 	existingFilename, existingDecl := p.findFunc(t.ReceiverType, t.Name)
+	if isInitFunc(t.ReceiverType, t.Name) {
+		existingFilename, existingDecl = p.findFuncCode(t.ReceiverType, t.Name, text)
+	}
 
 	if existingDecl != nil {
 		// if so and not replacing, no change needed
 		if !t.Replace {
 			return nil
 		} else {
-			// if so and replacing, write the file out with the specific portion omitted
-
-			endPos := existingDecl.End()
-			startPos := existingDecl.Pos()
-			if existingDecl.Doc != nil {
-				startPos = existingDecl.Doc.Pos() // begin at the comment if it exists
+			// unless it was changed by hand or pinned with gocode:keep
+			if p.replaceProtected(existingFilename, funcDisplayName(t.ReceiverType, t.Name), existingDecl, existingDecl.Doc, text) {
+				return nil
 			}
 
-			startOffset := p.fset.Position(startPos).Offset
-			endOffset := p.fset.Position(endPos).Offset
-
-			// local byte slice updated in case the code below writes to the same file
-			b := p.fileBytes[existingFilename]
-			b = append(b[:startOffset], b[endOffset:]...)
-			p.fileBytes[existingFilename] = b
-
-			// existingFilePath := path.Join(p.subDir, existingFilename)
-			err := p.writeFileNamed(existingFilename, b)
-			if err != nil {
-				return err
-			}
+			// if so and replacing, the new func goes where the existing one was, in its file
+			p.fileBytes[existingFilename] = p.fileBytesWithoutBlock(existingFilename, existingDecl)
+			return p.addDecl(existingFilename, text, t.Owner, p.replacedPlace(existingDecl))
 
 		}
 	}
 
-	// then write out the file indicated in the transform with the new func added to the bottom
-	b := p.fileBytesOrNew(t.Filename)
-	b = append(b, t.Text...)
-	b = append(b, "\n"...)
-	return p.writeFileNamed(t.Filename, b)
+	// otherwise it's added to the file indicated in the transform, at the bottom unless anchored
+	place, err := p.anchorPlace(t.Filename, t.Anchor, t.AnchorName, t.ReceiverType)
+	if err != nil {
+		return err
+	}
+	return p.addDecl(t.Filename, text, t.Owner, place)
 
 }
 
 func (p *Package) applyImport(t *ImportTransform) error {
 
+	// already imported, or the name it gets if it's taken
+	name, imported := p.importLocalName(t)
+	if imported {
+		return nil
+	}
+
 	// spin through and find the last import
 	// block and add a line there if it's a block,
 	// otherwise single import line, and if not that then
@@ -497,15 +583,15 @@ func (p *Package) applyImport(t *ImportTransform) error {
 
 		pos := p.fset.Position(lastImport.Rparen)
 
-		buf := make([]byte, 0, len(b)+len(t.Name)+len(t.Path)+16)
+		buf := make([]byte, 0, len(b)+len(name)+len(t.Path)+16)
 		buf = append(buf, b[:pos.Offset]...)
 		// add newline before paren if missing
 		if buf[len(buf)-1] != '\n' {
 			buf = append(buf, "\n"...)
 		}
 		buf = append(buf, "\t"...)
-		if t.Name != "" {
-			buf = append(buf, t.Name...)
+		if name != "" {
+			buf = append(buf, name...)
 			buf = append(buf, " "...)
 		}
 		buf = append(buf, "\""...)
@@ -520,15 +606,15 @@ func (p *Package) applyImport(t *ImportTransform) error {
 
 		pos := p.fset.Position(lastImport.End())
 
-		buf := make([]byte, 0, len(b)+len(t.Name)+len(t.Path)+16)
+		buf := make([]byte, 0, len(b)+len(name)+len(t.Path)+16)
 		buf = append(buf, b[:pos.Offset]...)
 		// add newline before paren if missing
 		if buf[len(buf)-1] != '\n' {
 			buf = append(buf, "\n"...)
 		}
 		buf = append(buf, "import "...)
-		if t.Name != "" {
-			buf = append(buf, t.Name...)
+		if name != "" {
+			buf = append(buf, name...)
 			buf = append(buf, " "...)
 		}
 		buf = append(buf, "\""...)
@@ -552,12 +638,12 @@ func (p *Package) applyImport(t *ImportTransform) error {
 			return fmt.Errorf("unable to find package line in %q", t.Filename)
 		}
 
-		buf := make([]byte, 0, len(b)+len(t.Name)+len(t.Path)+16)
+		buf := make([]byte, 0, len(b)+len(name)+len(t.Path)+16)
 		buf = append(buf, b[:pkgidx[1]]...)
 		buf = append(buf, "\n\n"...)
 		buf = append(buf, "import "...)
-		if t.Name != "" {
-			buf = append(buf, t.Name...)
+		if name != "" {
+			buf = append(buf, name...)
 			buf = append(buf, " "...)
 		}
 		buf = append(buf, "\""...)
@@ -576,10 +662,16 @@ func (p *Package) applyImport(t *ImportTransform) error {
 
 func (p *Package) applyAddConstDecl(t *AddConstDeclTransform) error {
 
+	// written with the names its imports have in the file, see ImportAlias
+	text := p.renameImports(t.Filename, t.Text)
+
 	// names must match or be a superset
 
 	// af := p.astf[t.Filename]
 	filename, names, varOrConstDecl := p.findVarOrConstDecl(token.CONST, t.NameList)
+	if allBlank(t.NameList) {
+		filename, names, varOrConstDecl = p.findBlankDeclCode(token.CONST, text)
+	}
 	// log.Printf("filename=%q, names=%+v, varOrConstDecl=%#v", filename, names, varOrConstDecl)
 	//p.findVarOrConstDecl(tok token.Token, withAnyNames []string) (filename string, names []string, varOrConstDecl *ast.GenDecl)
 
@@ -590,33 +682,36 @@ func (p *Package) applyAddConstDecl(t *AddConstDeclTransform) error {
 			return fmt.Errorf("name list from transform const block %+v is not a subset of existing block %+v", t.NameList, names)
 		}
 
-		// if not replacing, then we're done
-		if !t.Replace {
+		// if not replacing, or it was changed by hand, then we're done
+		if !t.Replace || p.replaceProtected(filename, strings.Join(names, ", "), varOrConstDecl, varOrConstDecl.Doc, text) {
 			return nil
 		}
 
-		// b := p.fileBytes[filename]
-		b := p.fileBytesWithoutBlock(filename, varOrConstDecl)
-		p.fileBytes[filename] = b
-		err := p.writeFileNamed(t.Filename, b)
-		if err != nil {
-			return err
-		}
+		// replaced where it is
+		p.fileBytes[filename] = p.fileBytesWithoutBlock(filename, varOrConstDecl)
+		return p.addDecl(filename, text, t.Owner, p.replacedPlace(varOrConstDecl))
 
 	}
 
-	b := p.fileBytesOrNew(t.Filename)
-	b = append(b, t.Text...)
-	b = append(b, "\n"...)
-	return p.writeFileNamed(t.Filename, b)
+	place, err := p.anchorPlace(t.Filename, t.Anchor, t.AnchorName, "")
+	if err != nil {
+		return err
+	}
+	return p.addDecl(t.Filename, text, t.Owner, place)
 }
 
 func (p *Package) applyAddVarDecl(t *AddVarDeclTransform) error {
 
+	// written with the names its imports have in the file, see ImportAlias
+	text := p.renameImports(t.Filename, t.Text)
+
 	// names must match or be a superset
 
 	// af := p.astf[t.Filename]
 	filename, names, varOrConstDecl := p.findVarOrConstDecl(token.VAR, t.NameList)
+	if allBlank(t.NameList) {
+		filename, names, varOrConstDecl = p.findBlankDeclCode(token.VAR, text)
+	}
 	// log.Printf("filename=%q, names=%+v, varOrConstDecl=%#v", filename, names, varOrConstDecl)
 	//p.findVarOrConstDecl(tok token.Token, withAnyNames []string) (filename string, names []string, varOrConstDecl *ast.GenDecl)
 
@@ -627,52 +722,63 @@ func (p *Package) applyAddVarDecl(t *AddVarDeclTransform) error {
 			return fmt.Errorf("name list from transform var block %+v is not a subset of existing block %+v", t.NameList, names)
 		}
 
-		// if not replacing, then we're done
-		if !t.Replace {
+		// if not replacing, or it was changed by hand, then we're done
+		if !t.Replace || p.replaceProtected(filename, strings.Join(names, ", "), varOrConstDecl, varOrConstDecl.Doc, text) {
 			return nil
 		}
 
-		// b := p.fileBytes[filename]
-		b := p.fileBytesWithoutBlock(filename, varOrConstDecl)
-		p.fileBytes[filename] = b
-		err := p.writeFileNamed(t.Filename, b)
-		if err != nil {
-			return err
-		}
+		// replaced where it is
+		p.fileBytes[filename] = p.fileBytesWithoutBlock(filename, varOrConstDecl)
+		return p.addDecl(filename, text, t.Owner, p.replacedPlace(varOrConstDecl))
 
 	}
 
-	b := p.fileBytesOrNew(t.Filename)
-	b = append(b, t.Text...)
-	b = append(b, "\n"...)
-	return p.writeFileNamed(t.Filename, b)
+	place, err := p.anchorPlace(t.Filename, t.Anchor, t.AnchorName, "")
+	if err != nil {
+		return err
+	}
+	return p.addDecl(t.Filename, text, t.Owner, place)
 }
 
 func (p *Package) applyAddTypeDecl(t *AddTypeDeclTransform) error {
 
+	// written with the names its imports have in the file, see ImportAlias
+	text := p.renameImports(t.Filename, t.Text)
+
 	filename, typeDecl := p.findTypeDecl(t.Name)
 	// log.Printf("applyAddTypeDecl - filename=%q, typeDecl=%v", filename, typeDecl)
 
 	if typeDecl != nil {
 
-		// if not replacing, then we're done
-		if !t.Replace {
+		// if not merging or replacing, or pinned with gocode:keep, then we're done
+		if (!t.Merge && !t.Replace) || hasKeep(typeDecl.Doc) {
 			return nil
 		}
 
-		b := p.fileBytesWithoutBlock(filename, typeDecl)
-		p.fileBytes[filename] = b
-		err := p.writeFileNamed(t.Filename, b)
-		if err != nil {
-			return err
+		// structs are merged so fields added by hand are kept, even when replacing
+		if t.Merge {
+			merged, err := p.mergeTypeDecl(filename, t.Name, typeDecl, text, t.Replace)
+			if err != nil || merged {
+				return err
+			}
 		}
 
+		// unless it was changed by hand
+		if !t.Replace || p.replaceProtected(filename, t.Name, typeDecl, typeDecl.Doc, text) {
+			return nil
+		}
+
+		// replaced where it is
+		p.fileBytes[filename] = p.fileBytesWithoutBlock(filename, typeDecl)
+		return p.addDecl(filename, text, t.Owner, p.replacedPlace(typeDecl))
+
 	}
 
-	b := p.fileBytesOrNew(t.Filename)
-	b = append(b, t.Text...)
-	b = append(b, "\n"...)
-	return p.writeFileNamed(t.Filename, b)
+	place, err := p.anchorPlace(t.Filename, t.Anchor, t.AnchorName, "")
+	if err != nil {
+		return err
+	}
+	return p.addDecl(t.Filename, text, t.Owner, place)
 
 }
 
@@ -693,9 +799,26 @@ func (p *Package) fileBytesWithoutBlock(fname string, node ast.Node) []byte {
 		return nil
 	}
 
+	start, end := nodeRange(node)
+
+	// convert to byte offset
+	startOffset := p.fset.Position(start).Offset
+	endOffset := p.fset.Position(end).Offset
+
+	out := make([]byte, 0, len(b))
+
+	out = append(out, b[:startOffset]...)
+	out = append(out, b[endOffset:]...)
+
+	return out
+}
+
+// nodeRange returns the start and end of node, including its doc comment.
+func nodeRange(node ast.Node) (start, end token.Pos) {
+
 	// all nodes have a start and end
-	start := node.Pos()
-	end := node.End()
+	start = node.Pos()
+	end = node.End()
 
 	// but if there's a comment block, move to start of that
 	var cg *ast.CommentGroup
@@ -718,16 +841,7 @@ func (p *Package) fileBytesWithoutBlock(fname string, node ast.Node) []byte {
 		start = cg.Pos()
 	}
 
-	// convert to byte offset
-	startOffset := p.fset.Position(start).Offset
-	endOffset := p.fset.Position(end).Offset
-
-	out := make([]byte, 0, len(b))
-
-	out = append(out, b[:startOffset]...)
-	out = append(out, b[endOffset:]...)
-
-	return out
+	return start, end
 }
 
 // findFunc looks through what has been load()ed and searches for a function
@@ -761,6 +875,9 @@ func (p *Package) findVarOrConstDecl(tok token.Token, withAnyNames []string) (fi
 
 	nmap := make(map[string]struct{}, len(withAnyNames))
 	for _, n := range withAnyNames {
+		if n == "_" { // doesn't identify anything, see findBlankDeclCode
+			continue
+		}
 		nmap[n] = struct{}{}
 	}
 
@@ -904,6 +1021,8 @@ func (p *Package) findTypeDeclMatch(matchFunc func(typeName string) bool) (filen
 // load will read in the package files and parse everything.
 func (p *Package) load() error {
 
+	p.loaded = false
+
 	fnl, err := p.fileNames()
 	if err != nil {
 		return fmt.Errorf("fileNames error: %w", err)
@@ -912,10 +1031,8 @@ func (p *Package) load() error {
 	p.fset = &token.FileSet{}
 	p.astf = make(map[string]*ast.File, len(fnl))
 	p.fileBytes = make(map[string][]byte, len(fnl))
-	p.localName = ""
+	p.dirty = make(map[string]bool)
 
-	pkgNames := make([]string, 0, 1)
-	pkgNameMap := make(map[string]struct{}, 2)
 	for _, fn := range fnl {
 		b, err := p.readFile(fn)
 		if err != nil {
@@ -927,15 +1044,69 @@ func (p *Package) load() error {
 			return err
 		}
 		p.astf[fn] = af
-		pname := af.Name.Name
+		// NOTE: ParseDir returns an ast.Package but it doesn't have any additional info,
+		// a simple slice of *ast.File is just as well (plus we need the separate filesystem support)
+		// NOTE: if we need SSA we'll just call sslutil.BuildPackage somewhere around here
+	}
+
+	err = p.setLocalName()
+	if err != nil {
+		return err
+	}
+
+	p.loaded = true
+	return nil
+}
+
+// sync brings astf up to date with fileBytes by parsing just the files written since they
+// were last parsed, or loads the package if it hasn't been yet.  Since everything written goes
+// through writeFileNamed, this keeps fset, astf and fileBytes consistent without reading the
+// rest of the package again.  Changes made to the files by other means are not seen until the
+// package is loaded again, which ApplyPackageTransforms and FindType do.
+func (p *Package) sync() error {
+
+	if !p.loaded {
+		return p.load()
+	}
+	if len(p.dirty) == 0 {
+		return nil
+	}
+
+	for _, fn := range sortedKeysBool(p.dirty) {
+		// positions from the old parse stay valid in fset, the new ones just come after them
+		af, err := parser.ParseFile(p.fset, fn, p.fileBytes[fn], parser.ParseComments)
+		if err != nil {
+			p.loaded = false // so the next call starts again from what is on disk
+			return err
+		}
+		p.astf[fn] = af
+	}
+	p.dirty = make(map[string]bool)
+
+	return p.setLocalName()
+}
+
+// setLocalName sets localName from the package clauses of the parsed files, or derives it
+// from the subdir or module path if there aren't any.
+func (p *Package) setLocalName() error {
+
+	p.localName = ""
+
+	fnl := make([]string, 0, len(p.astf))
+	for fn := range p.astf {
+		fnl = append(fnl, fn)
+	}
+	sort.Strings(fnl)
+
+	pkgNames := make([]string, 0, 1)
+	pkgNameMap := make(map[string]struct{}, 2)
+	for _, fn := range fnl {
+		pname := p.astf[fn].Name.Name
 		_, ok := pkgNameMap[pname]
 		if !ok {
 			pkgNames = append(pkgNames, pname)
 			pkgNameMap[pname] = struct{}{}
 		}
-		// NOTE: ParseDir returns an ast.Package but it doesn't have any additional info,
-		// a simple slice of *ast.File is just as well (plus we need the separate filesystem support)
-		// NOTE: if we need SSA we'll just call sslutil.BuildPackage somewhere around here
 	}
 
 	switch len(pkgNames) {
@@ -3,9 +3,12 @@ package srcedit
 import (
 	"bytes"
 	"fmt"
+	"go/format"
 	"io/fs"
 	"io/ioutil"
 	"path"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/psanford/memfs"
@@ -90,6 +93,7 @@ func TestApplyTransforms(t *testing.T) {
 		in         files       // input files
 		transforms []Transform // transforms to apply
 		eout       files       // expected output
+		eerr       string      // if set, the error expected from ApplyTransforms
 	}
 
 	tcaseList := []tcase{
@@ -111,8 +115,9 @@ func TestApplyTransforms(t *testing.T) {
 				},
 			},
 			eout: files{
-				"a.go": `package test1` + lf + lf,
-				"b.go": `package test1` + lf + lf + `func A() (err error) { return }` + lf,
+				// replaced where it was, not moved to b.go
+				"a.go": `package test1` + lf +
+					`func A() (err error) { return }` + lf,
 			},
 		},
 
@@ -183,6 +188,129 @@ func TestApplyTransforms(t *testing.T) {
 			},
 		},
 
+		{
+			name:   "alias01",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf + lf +
+					`import "example.com/x/store"` + lf + lf +
+					`var _ = store.A` + lf,
+				"b.go": `package test1` + lf + lf +
+					`func diff() {}` + lf,
+			},
+			transforms: []Transform{
+				&ImportTransform{Filename: "a.go", Path: "example.com/y/store"},
+				&ImportTransform{Filename: "a.go", Path: "example.com/z/diff"},
+				&AddFuncDeclTransform{Filename: "a.go", Name: "F", Text: `func F(diff int) { store.B(); diff.Run() }`},
+				&AddFuncDeclTransform{Filename: "a.go", Name: "G", Text: `func G() { store.B(); diff.Run() }`},
+			},
+			eout: files{
+				"a.go": `package test1` + lf + lf +
+					`import "example.com/x/store"` + lf +
+					`import store2 "example.com/y/store"` + lf +
+					`import diff2 "example.com/z/diff"` + lf + lf +
+					`var _ = store.A` + lf +
+					`func F(diff int) { store2.B(); diff.Run() }` + lf +
+					`func G() { store2.B(); diff2.Run() }` + lf,
+			},
+		},
+
+		{
+			name:   "alias02",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf + lf +
+					`import st "example.com/x/store"` + lf + lf +
+					`type T struct{}` + lf,
+			},
+			transforms: []Transform{
+				&ImportTransform{Filename: "a.go", Path: "example.com/x/store"},
+				&AddStructFieldTransform{TypeName: "T", Text: `S *store.S`},
+			},
+			eout: files{
+				"a.go": `package test1` + lf + lf +
+					`import st "example.com/x/store"` + lf + lf +
+					`type T struct {` + lf +
+					tab + `S *st.S` + lf +
+					`}` + lf,
+			},
+		},
+
+		{
+			name:   "file01",
+			subDir: "test1",
+			in: files{
+				"a.go":    `package test1` + lf,
+				"old.sql": `SELECT 1;` + lf,
+			},
+			transforms: []Transform{
+				&AddFileTransform{Filename: "new.sql", Text: `SELECT 2;` + lf},
+				&AddFileTransform{Filename: "new.sql", Text: `SELECT 3;` + lf}, // exists now, left alone
+				&AddFileTransform{Filename: "old.sql", Text: `SELECT 4;` + lf, Replace: true},
+			},
+			eout: files{
+				"new.sql": `SELECT 2;` + lf,
+				"old.sql": `SELECT 4;` + lf,
+			},
+		},
+
+		{
+			name:   "file02",
+			subDir: "test1",
+			in: files{
+				"a.go":    `package test1` + lf,
+				"old.sql": `SELECT 1;` + lf,
+			},
+			transforms: []Transform{
+				&AddFileTransform{Filename: "old.sql", Text: `SELECT 2;` + lf, FailIfExists: true},
+			},
+			eerr: "file already exists",
+		},
+
+		{
+			name:   "file03",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf,
+			},
+			transforms: []Transform{
+				&AddFileTransform{Filename: "b.go", Text: `package test1` + lf},
+			},
+			eerr: "is a Go file",
+		},
+
+		{
+			name:   "section01",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf,
+				"config.yaml": `name: test` + lf +
+					`# gocode:section b begin` + lf +
+					`b: 1` + lf +
+					`# gocode:section b end` + lf +
+					`other: 2`,
+			},
+			transforms: []Transform{
+				&AppendFileSectionTransform{Filename: "config.yaml", Name: "a", Text: `a: 1` + lf},
+				&AppendFileSectionTransform{Filename: "config.yaml", Name: "a", Text: `a: 2` + lf}, // only added once
+				&AppendFileSectionTransform{Filename: "config.yaml", Name: "b", Text: `b: 2`, Replace: true},
+				&AppendFileSectionTransform{Filename: "README.md", Name: "a", Text: `# A`},
+			},
+			eout: files{
+				"config.yaml": `name: test` + lf +
+					`# gocode:section b begin` + lf +
+					`b: 2` + lf +
+					`# gocode:section b end` + lf +
+					`other: 2` + lf +
+					`# gocode:section a begin` + lf +
+					`a: 1` + lf +
+					`# gocode:section a end` + lf,
+				"README.md": `<!-- gocode:section a begin -->` + lf +
+					`# A` + lf +
+					`<!-- gocode:section a end -->` + lf,
+			},
+		},
+
 		{
 			name:   "dedupimport01",
 			subDir: "test1",
@@ -260,7 +388,7 @@ func TestApplyTransforms(t *testing.T) {
 				},
 			},
 			eout: files{
-				"a.go": `package test1` + lf + lf + lf +
+				"a.go": `package test1` + lf + lf +
 					`const (` + lf +
 					tab + `x = 10` + lf +
 					tab + `y = 20` + lf +
@@ -326,11 +454,158 @@ func TestApplyTransforms(t *testing.T) {
 				},
 			},
 			eout: files{
-				"a.go": `package test1` + lf + lf + lf +
+				"a.go": `package test1` + lf + lf +
 					`type X int` + lf + lf,
 			},
 		},
 
+		{
+			name:   "anchor01",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf + lf +
+					`func A() {}` + lf + lf +
+					`func B() {}` + lf + lf +
+					`func C() {}` + lf,
+			},
+			transforms: []Transform{
+				// replaced where it is, not moved to the end
+				&AddFuncDeclTransform{Filename: "a.go", Name: "B", Text: `func B() { A() }`, Replace: true},
+			},
+			eout: files{
+				"a.go": `package test1` + lf + lf +
+					`func A() {}` + lf + lf +
+					`func B() { A() }` + lf + lf +
+					`func C() {}` + lf,
+			},
+		},
+
+		{
+			name:   "anchor02",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf + lf +
+					`func A() {} // a` + lf + lf +
+					`func C() {}` + lf,
+			},
+			transforms: []Transform{
+				&AddFuncDeclTransform{Filename: "a.go", Name: "B", Text: `func B() {}`, Anchor: DeclAfter, AnchorName: "A"},
+				&AddConstDeclTransform{Filename: "a.go", NameList: []string{"x"}, Text: `const x = 1`, Anchor: DeclBefore, AnchorName: "C"},
+			},
+			eout: files{
+				"a.go": `package test1` + lf + lf +
+					`func A() {} // a` + lf + lf +
+					`func B() {}` + lf + lf +
+					`const x = 1` + lf + lf +
+					`func C() {}` + lf,
+			},
+		},
+
+		{
+			name:   "anchor03",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf + lf +
+					`type X struct{}` + lf + lf +
+					`func (x *X) A() {}` + lf + lf +
+					`type Y struct{}` + lf + lf +
+					`func F() {}` + lf,
+			},
+			transforms: []Transform{
+				&AddFuncDeclTransform{Filename: "a.go", Name: "B", ReceiverType: "X", Text: `func (x X) B() {}`, Anchor: DeclWithReceiver},
+				&AddFuncDeclTransform{Filename: "a.go", Name: "C", ReceiverType: "*Y", Text: `func (y *Y) C() {}`, Anchor: DeclWithReceiver},
+				&AddFuncDeclTransform{Filename: "a.go", Name: "D", ReceiverType: "X", Text: `func (x X) D() {}`, Anchor: DeclAfter, AnchorName: "X.B"},
+				// not found, so at the end
+				&AddVarDeclTransform{Filename: "a.go", NameList: []string{"z"}, Text: `var z = 1`, Anchor: DeclAfter, AnchorName: "Nope"},
+			},
+			eout: files{
+				"a.go": `package test1` + lf + lf +
+					`type X struct{}` + lf + lf +
+					`func (x *X) A() {}` + lf + lf +
+					`func (x X) B() {}` + lf + lf +
+					`func (x X) D() {}` + lf + lf +
+					`type Y struct{}` + lf + lf +
+					`func (y *Y) C() {}` + lf + lf +
+					`func F() {}` + lf +
+					`var z = 1` + lf,
+			},
+		},
+
+		{
+			name:   "anchor04",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf,
+			},
+			transforms: []Transform{
+				&AddFuncDeclTransform{Filename: "a.go", Name: "B", Text: `func B() {}`, Anchor: "sideways"},
+			},
+			eerr: `unknown anchor "sideways"`,
+		},
+
+		{
+			name:   "init01",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf + lf +
+					`func init() { a() }` + lf,
+			},
+			transforms: []Transform{
+				&AddFuncDeclTransform{Filename: "a.go", Name: "init", Text: `func init() { b() }`},
+				&AddFuncDeclTransform{Filename: "a.go", Name: "init", Text: `func init() {  a()  }`},
+			},
+			eout: files{
+				"a.go": `package test1` + lf + lf +
+					`func init() { a() }` + lf +
+					`func init() { b() }` + lf,
+			},
+		},
+
+		{
+			name:   "init02",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf + lf +
+					`func init() { a() }` + lf + lf +
+					`// old` + lf +
+					`func init() { b() }` + lf + lf +
+					`func init() { c() }` + lf,
+			},
+			transforms: []Transform{
+				&AddFuncDeclTransform{Filename: "a.go", Name: "init", Text: `// new` + lf + `func init() { b() }`, Replace: true},
+				&RemoveFuncDeclTransform{Name: "init", Text: `func init() { c() }`},
+				&RemoveFuncDeclTransform{Name: "init"}, // no code given, so none are removed
+			},
+			eout: files{
+				"a.go": `package test1` + lf + lf +
+					`func init() { a() }` + lf + lf +
+					`// new` + lf +
+					`func init() { b() }` + lf + lf,
+			},
+		},
+
+		{
+			name:   "blank01",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf + lf +
+					`var x, _ = f()` + lf + lf +
+					`var _ I = (*T)(nil)` + lf + lf +
+					`var _ K = (*T)(nil)` + lf,
+			},
+			transforms: []Transform{
+				&AddVarDeclTransform{Filename: "a.go", NameList: []string{"_"}, Text: `var _ I = (*T)(nil)`},
+				&AddVarDeclTransform{Filename: "a.go", NameList: []string{"_"}, Text: `var _ J = (*T)(nil)`},
+				&RemoveVarConstDeclTransform{NameList: []string{"_"}, Text: `var _ K = (*T)(nil)`},
+			},
+			eout: files{
+				"a.go": `package test1` + lf + lf +
+					`var x, _ = f()` + lf + lf +
+					`var _ I = (*T)(nil)` + lf + lf +
+					`var _ J = (*T)(nil)` + lf,
+			},
+		},
+
 		{
 			name:   "type03",
 			subDir: "test1",
@@ -349,6 +624,376 @@ func TestApplyTransforms(t *testing.T) {
 			eout: files{}, // no changed files
 		},
 
+		{
+			name:   "funcline01",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf + lf +
+					`func Setup() error {` + lf +
+					tab + `init1()` + lf +
+					tab + `return nil` + lf +
+					`}` + lf,
+			},
+			transforms: []Transform{
+				&AddFuncLineTransform{
+					Name: "Setup",
+					Text: `init2()` + lf + `init1()`,
+				},
+			},
+			eout: files{
+				"a.go": `package test1` + lf + lf +
+					`func Setup() error {` + lf +
+					tab + `init1()` + lf +
+					tab + `init2()` + lf +
+					tab + `return nil` + lf +
+					`}` + lf,
+			},
+		},
+
+		{
+			name:   "funcline02",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf + lf +
+					`func (r *R) Routes() {` + lf +
+					tab + `// routes` + lf +
+					tab + `r.a()` + lf +
+					`}` + lf,
+			},
+			transforms: []Transform{
+				&AddFuncLineTransform{
+					Name:         "Routes",
+					ReceiverType: "*R",
+					Text:         `// b route` + lf + `r.b()`,
+					Anchor:       FuncLineAfterComment,
+					Comment:      `// routes`,
+				},
+			},
+			eout: files{
+				"a.go": `package test1` + lf + lf +
+					`func (r *R) Routes() {` + lf +
+					tab + `// routes` + lf +
+					tab + `// b route` + lf +
+					tab + `r.b()` + lf +
+					tab + `r.a()` + lf +
+					`}` + lf,
+			},
+		},
+
+		{
+			name:   "funcline03",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf + lf +
+					`func (r *R) Routes() {` + lf +
+					tab + `if r != nil {` + lf +
+					tab + tab + `r.a( )` + lf +
+					tab + `}` + lf +
+					`}` + lf,
+			},
+			transforms: []Transform{
+				&AddFuncLineTransform{
+					Name:         "Routes",
+					ReceiverType: "*R",
+					Text:         `r.a()`,
+					Anchor:       FuncLineAtEnd,
+				},
+			},
+			eout: files{}, // already there
+		},
+
+		{
+			name:   "funcline04",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf + lf +
+					`func Init() {}` + lf,
+			},
+			transforms: []Transform{
+				&AddFuncLineTransform{
+					Name:   "Init",
+					Text:   `x := 1` + lf + `_ = x`,
+					Anchor: FuncLineAtEnd,
+				},
+			},
+			eout: files{
+				"a.go": `package test1` + lf + lf +
+					`func Init() {` + lf +
+					tab + `x := 1` + lf +
+					tab + `_ = x` + lf +
+					`}` + lf,
+			},
+		},
+
+		{
+			name:   "structfield01",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf + lf +
+					`type X struct {` + lf +
+					tab + `A string ` + "`db:\"a\"`" + lf +
+					tab + `// U was added by hand` + lf +
+					tab + `U int` + lf +
+					`}` + lf,
+			},
+			transforms: []Transform{
+				&AddStructFieldTransform{
+					TypeName: "X",
+					Text: `A string ` + "`db:\"a2\"`" + lf +
+						`// B is new` + lf +
+						`B int // b` + lf +
+						`*Y`,
+				},
+			},
+			eout: files{
+				"a.go": `package test1` + lf + lf +
+					`type X struct {` + lf +
+					tab + `A string ` + "`db:\"a\"`" + lf +
+					tab + `// U was added by hand` + lf +
+					tab + `U int` + lf +
+					tab + `// B is new` + lf +
+					tab + `B int // b` + lf +
+					tab + `*Y` + lf +
+					`}` + lf,
+			},
+		},
+
+		{
+			name:   "structfield02",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf + lf +
+					`type X struct {` + lf +
+					tab + `A string ` + "`db:\"a\"`" + lf +
+					tab + `B int` + lf +
+					`}` + lf,
+			},
+			transforms: []Transform{
+				&AddStructFieldTransform{
+					TypeName: "X",
+					Text:     `A string ` + "`db:\"a2\"`" + lf + `B int ` + "`db:\"b\"`",
+					Replace:  true,
+				},
+			},
+			eout: files{
+				"a.go": `package test1` + lf + lf +
+					`type X struct {` + lf +
+					tab + `A string ` + "`db:\"a2\"`" + lf +
+					tab + `B int ` + "`db:\"b\"`" + lf +
+					`}` + lf,
+			},
+		},
+
+		{
+			name:   "structfield03",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf + lf +
+					`type X int` + lf,
+			},
+			transforms: []Transform{
+				&AddStructFieldTransform{
+					TypeName: "X",
+					Text:     `A string`,
+				},
+			},
+			eerr: "not a struct",
+		},
+
+		{
+			name:   "typemerge01",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf + lf +
+					`// Store is generated.` + lf +
+					`type Store struct {` + lf +
+					tab + `db *DB ` + "`x:\"1\"`" + lf +
+					lf +
+					tab + `// cache was added by hand` + lf +
+					tab + `cache map[string]string` + lf +
+					`}` + lf,
+			},
+			transforms: []Transform{
+				&AddTypeDeclTransform{
+					Filename: "a.go",
+					Name:     "Store",
+					Text: `// Store is generated.` + lf +
+						`type Store struct {` + lf +
+						tab + `db *DB ` + "`x:\"2\"`" + lf +
+						tab + `// log is new` + lf +
+						tab + `log Logger` + lf +
+						`}` + lf,
+					Replace: true,
+					Merge:   true,
+				},
+			},
+			eout: files{
+				"a.go": `package test1` + lf + lf +
+					`// Store is generated.` + lf +
+					`type Store struct {` + lf +
+					tab + `db *DB ` + "`x:\"2\"`" + lf +
+					lf +
+					tab + `// cache was added by hand` + lf +
+					tab + `cache map[string]string` + lf +
+					tab + `// log is new` + lf +
+					tab + `log Logger` + lf +
+					`}` + lf,
+			},
+		},
+
+		{
+			name:   "typemerge02",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf + lf +
+					`type X struct{}` + lf,
+			},
+			transforms: []Transform{
+				&AddTypeDeclTransform{
+					Filename: "a.go",
+					Name:     "X",
+					Text:     `type X struct {` + lf + tab + `A int` + lf + `}` + lf,
+					Merge:    true,
+				},
+			},
+			eout: files{
+				"a.go": `package test1` + lf + lf +
+					`type X struct {` + lf +
+					tab + `A int` + lf +
+					`}` + lf,
+			},
+		},
+
+		{
+			name:   "remove01",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf + lf +
+					`// X is a type.` + lf +
+					`type X struct{}` + lf + lf +
+					`// F does something.` + lf +
+					`func (x *X) F() {}` + lf + lf +
+					`func (x *X) G() {}` + lf + lf +
+					`const (` + lf +
+					tab + `A = 1` + lf +
+					tab + `B = 2` + lf +
+					`)` + lf + lf +
+					`var v = 1` + lf,
+			},
+			transforms: []Transform{
+				&RemoveFuncDeclTransform{Name: "F", ReceiverType: "*X"},
+				&RemoveFuncDeclTransform{Name: "H", ReceiverType: "*X"}, // not there
+				&RemoveTypeDeclTransform{Name: "X"},
+				&RemoveVarConstDeclTransform{NameList: []string{"A", "B"}},
+			},
+			eout: files{
+				"a.go": `package test1` + lf + lf +
+					`func (x *X) G() {}` + lf + lf +
+					`var v = 1` + lf,
+			},
+		},
+
+		{
+			name:   "remove02",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf + lf +
+					`const (` + lf +
+					tab + `A = 1` + lf +
+					tab + `B = 2` + lf +
+					`)` + lf,
+			},
+			transforms: []Transform{
+				&RemoveVarConstDeclTransform{NameList: []string{"A"}},
+			},
+			eerr: "has names not in",
+		},
+
+		{
+			name:   "removeimports01",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf + lf +
+					`import (` + lf +
+					tab + `"fmt"` + lf +
+					tab + `"strings"` + lf + lf +
+					tab + `_ "embed"` + lf +
+					tab + `"github.com/pressly/goose/v3"` + lf +
+					tab + `"github.com/go-sql-driver/mysql"` + lf +
+					`)` + lf + lf +
+					`import "os"` + lf + lf +
+					`func F() { fmt.Println(goose.X, v.Y) }` + lf,
+				"b.go": `package test1` + lf + lf +
+					`import (` + lf +
+					tab + `"fmt"` + lf +
+					tab + `"example.com/pkg/weird-name"` + lf +
+					`)` + lf + lf +
+					`var v = weird.Z` + lf,
+				"c.go": `package test1` + lf + lf +
+					`import _ "embed"` + lf,
+			},
+			transforms: []Transform{
+				&RemoveUnusedImportsTransform{},
+			},
+			eout: files{
+				"a.go": `package test1` + lf + lf +
+					`import (` + lf +
+					tab + `"fmt"` + lf + lf +
+					tab + `_ "embed"` + lf +
+					tab + `"github.com/pressly/goose/v3"` + lf +
+					`)` + lf + lf +
+					`func F() { fmt.Println(goose.X, v.Y) }` + lf,
+				// b.go refers to weird, which can't be matched to an import, so it is left alone
+				"c.go": `package test1` + lf + lf, // nothing but imports left, so blank ones go too
+			},
+		},
+
+		{
+			name:   "fiximports01",
+			subDir: "test1",
+			in: files{
+				"a.go": `package test1` + lf + lf +
+					`// imports used by F` + lf +
+					`import store "testcase/store"` + lf +
+					`import "net/http"` + lf + lf +
+					`import (` + lf +
+					tab + `"github.com/pressly/goose/v3"` + lf +
+					tab + `// for the driver` + lf +
+					tab + `_ "github.com/go-sql-driver/mysql"` + lf +
+					tab + `"strings" // unused` + lf +
+					tab + `"net/http"` + lf +
+					`)` + lf + lf +
+					`func F(w http.ResponseWriter) { goose.X(store.Y) }` + lf,
+				"b.go": `package test1` + lf + lf +
+					`import "fmt"` + lf +
+					`import "strings"` + lf + lf +
+					`var v = fmt.Sprint(1)` + lf,
+				"c.go": `package test1` + lf + lf +
+					`// #include <stdio.h>` + lf +
+					`import "C"` + lf +
+					`import "strings"` + lf,
+			},
+			transforms: []Transform{
+				&FixImportsTransform{},
+			},
+			eout: files{
+				"a.go": `package test1` + lf + lf +
+					`// imports used by F` + lf +
+					`import (` + lf +
+					tab + `"net/http"` + lf + lf +
+					tab + `// for the driver` + lf +
+					tab + `_ "github.com/go-sql-driver/mysql"` + lf +
+					tab + `"github.com/pressly/goose/v3"` + lf + lf +
+					tab + `store "testcase/store"` + lf +
+					`)` + lf + lf +
+					`func F(w http.ResponseWriter) { goose.X(store.Y) }` + lf,
+				"b.go": `package test1` + lf + lf +
+					`import "fmt"` + lf + lf +
+					`var v = fmt.Sprint(1)` + lf,
+				// c.go uses cgo so it is left alone
+			},
+		},
+
 		{
 			name:   "gofmt01",
 			subDir: "test1",
@@ -382,7 +1027,14 @@ func TestApplyTransforms(t *testing.T) {
 
 			// create Package and apply Transforms
 			p := NewPackage(infs, outfs, "testcase", tc.subDir)
-			must(t, p.ApplyTransforms(tc.transforms...))
+			err := p.ApplyTransforms(tc.transforms...)
+			if tc.eerr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.eerr) {
+					t.Fatalf("expected error containing %q, got: %v", tc.eerr, err)
+				}
+				return
+			}
+			must(t, err)
 
 			// walk the output fs, skip dirs, compare each file, then remove from eout map
 			must(t, fs.WalkDir(outfs, tc.subDir, fs.WalkDirFunc(func(fpath string, dirEntry fs.DirEntry, err error) error {
@@ -458,7 +1110,175 @@ func TestApplyTransforms(t *testing.T) {
 // 	t.Logf("result: %v", ok)
 // }
 
-func must(t *testing.T, err error) {
+func TestReferenced(t *testing.T) {
+
+	infs := memfs.New()
+	must(t, infs.MkdirAll("test1", 0755))
+	must(t, infs.WriteFile("test1/store.go", []byte(`package test1
+
+type Store struct{}
+
+func (s *Store) Tx() {}
+
+func helper() {}
+`), 0644))
+	must(t, infs.WriteFile("test1/a.go", []byte(`package test1
+
+type AStore struct{ *Store }
+
+// Tx is not a reference to the one on Store.
+func (s *AStore) Tx() {}
+`), 0644))
+
+	shared := []Transform{
+		&RemoveTypeDeclTransform{Name: "Store"},
+		&RemoveFuncDeclTransform{Name: "Tx", ReceiverType: "*Store"},
+		&RemoveFuncDeclTransform{Name: "helper"},
+	}
+
+	p := NewPackage(infs, infs, "testcase", "test1")
+	ref, err := p.Referenced(shared)
+	must(t, err)
+	if !ref {
+		t.Errorf("expected Store to be referenced by AStore")
+	}
+
+	// left alone while AStore uses it
+	must(t, p.ApplyTransforms(&RemoveUnreferencedTransform{TransformList: shared}))
+	b, err := fs.ReadFile(infs, "test1/store.go")
+	must(t, err)
+	if !strings.Contains(string(b), "type Store struct") {
+		t.Errorf("Store was removed while AStore uses it:\n%s", b)
+	}
+
+	// and removed along with AStore when they are applied together
+	must(t, p.ApplyTransforms(
+		&RemoveTypeDeclTransform{Name: "AStore"},
+		&RemoveFuncDeclTransform{Name: "Tx", ReceiverType: "*AStore"},
+		&RemoveUnreferencedTransform{TransformList: shared},
+	))
+	ref, err = p.Referenced(shared)
+	must(t, err)
+	if ref {
+		t.Errorf("expected nothing to be referenced once AStore is removed")
+	}
+	b, err = fs.ReadFile(infs, "test1/store.go")
+	must(t, err)
+	if strings.Contains(string(b), "Store") || strings.Contains(string(b), "helper") {
+		t.Errorf("shared code was not removed:\n%s", b)
+	}
+}
+
+func TestImportAliases(t *testing.T) {
+
+	fsys := memfs.New()
+	must(t, fsys.MkdirAll("a", 0755))
+	must(t, fsys.WriteFile("a/a.go", []byte("package a\n\nimport \"example.com/x/store\"\n\nvar _ = store.A\n"), 0644))
+
+	p := NewPackage(fsys, fsys, "test1", "a")
+	must(t, p.ApplyTransforms(
+		&ImportTransform{Filename: "a.go", Path: "example.com/y/store"},
+		&ImportTransform{Filename: "b.go", Path: "example.com/y/store"}, // nothing else called store there
+	))
+
+	aliases := p.ImportAliases()
+	ealiases := []ImportAlias{{Filename: "a.go", Path: "example.com/y/store", Name: "store", Alias: "store2"}}
+	if !reflect.DeepEqual(aliases, ealiases) {
+		t.Errorf("expected %+v, got %+v", ealiases, aliases)
+	}
+}
+
+func TestApplyTransformIncremental(t *testing.T) {
+
+	fsys := memfs.New()
+	must(t, fsys.MkdirAll("a", 0755))
+	must(t, fsys.WriteFile("a/a.go", []byte("package a\n\nfunc F() {}\n"), 0644))
+	must(t, fsys.WriteFile("a/b.go", []byte("package a\n\ntype B struct{}\n"), 0644))
+
+	p := NewPackage(fsys, fsys, "test1", "a")
+	must(t, p.load())
+	for _, tr := range []Transform{
+		&AddFuncDeclTransform{Filename: "a.go", Name: "G", Text: "func G() {}"},
+		&AddFuncLineTransform{Name: "F", Text: "G()"},
+		&AddFuncDeclTransform{Filename: "c.go", Name: "H", Text: "func H() {}"},
+		&AddStructFieldTransform{TypeName: "B", Text: "X int"},
+		&AddFuncLineTransform{Name: "H", Text: "F()"}, // in a file added since the load
+		&GofmtTransform{},
+	} {
+		must(t, p.ApplyTransform(tr))
+	}
+	must(t, p.sync())
+
+	// what was built up a file at a time must match loading it all again
+	astf, fileBytes := p.astf, p.fileBytes
+	fset := p.fset
+	must(t, p.load())
+	if len(astf) != len(p.astf) || len(fileBytes) != len(p.fileBytes) {
+		t.Fatalf("expected %d files, got %d", len(p.astf), len(astf))
+	}
+	for fn, af := range p.astf {
+		if !bytes.Equal(fileBytes[fn], p.fileBytes[fn]) {
+			t.Errorf("%s: expected %q, got %q", fn, p.fileBytes[fn], fileBytes[fn])
+		}
+		var want, got bytes.Buffer
+		must(t, format.Node(&want, p.fset, af))
+		must(t, format.Node(&got, fset, astf[fn]))
+		if want.String() != got.String() {
+			t.Errorf("%s: parsed file does not match, expected:\n%s\ngot:\n%s", fn, want.String(), got.String())
+		}
+	}
+}
+
+// BenchmarkApplyTransforms applies the kind of transforms generating a type does to a package
+// with a few hundred files, parsing just the files changed as it goes ("incremental") and loading
+// the whole package before each one ("reload"), which is what ApplyTransform used to do.
+func BenchmarkApplyTransforms(b *testing.B) {
+
+	const nfiles = 300
+
+	infs := memfs.New()
+	must(b, infs.MkdirAll("a", 0755))
+	for i := 0; i < nfiles; i++ {
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "package a\n\nimport \"fmt\"\n\n// T%d is a type.\ntype T%d struct {\n\tID   string\n\tName string\n}\n", i, i)
+		for j := 0; j < 10; j++ {
+			fmt.Fprintf(&buf, "\nfunc (t *T%d) M%d() string {\n\treturn fmt.Sprint(t.ID, %d)\n}\n", i, j, j)
+		}
+		must(b, infs.WriteFile(fmt.Sprintf("a/t%03d.go", i), buf.Bytes(), 0644))
+	}
+
+	var trs []Transform
+	trs = append(trs, &AddTypeDeclTransform{Filename: "widget.go", Name: "WidgetStore", Text: "type WidgetStore struct{}"})
+	for i := 0; i < 40; i++ {
+		trs = append(trs, &ImportTransform{Filename: "widget.go", Path: "fmt"})
+		trs = append(trs, &AddFuncDeclTransform{Filename: "widget.go", Name: fmt.Sprintf("M%d", i), ReceiverType: "*WidgetStore",
+			Text: fmt.Sprintf("func (s *WidgetStore) M%d() string { return fmt.Sprint(%d) }", i, i)})
+	}
+	trs = append(trs, &FixImportsTransform{FilenameList: []string{"widget.go"}}, &GofmtTransform{FilenameList: []string{"widget.go"}})
+
+	for _, reload := range []bool{false, true} {
+		name := "incremental"
+		if reload {
+			name = "reload"
+		}
+		b.Run(name, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				outfs := memfs.New()
+				must(b, outfs.MkdirAll("a", 0755))
+				p := NewPackage(infs, outfs, "test1", "a")
+				must(b, p.load())
+				for _, tr := range trs {
+					if reload {
+						p.loaded = false
+					}
+					must(b, p.ApplyTransform(tr))
+				}
+			}
+		})
+	}
+}
+
+func must(t testing.TB, err error) {
 	t.Helper()
 	if err != nil {
 		t.Fatal(err)
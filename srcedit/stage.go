@@ -0,0 +1,396 @@
+package srcedit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"testing/fstest"
+)
+
+// JournalPath is where the files about to be replaced are recorded while changes are written,
+// relative to the root of the output filesystem, so an interrupted write can be finished by
+// RecoverJournal.
+const JournalPath = ".gocode/journal.json"
+
+// tempSuffix is added to the name of each file while it is being written, with a "." in
+// front so the go tool ignores it.
+const tempSuffix = ".gocode-tmp"
+
+// Renamer is an FS that can rename a file, replacing any existing one, like os.Rename.
+type Renamer interface {
+	Rename(oldname, newname string) error
+}
+
+// Remover is an FS that can remove a file, like os.Remove.
+type Remover interface {
+	Remove(name string) error
+}
+
+// PackageTransforms is a package and the transforms to apply to it, for ApplyPackageTransforms.
+type PackageTransforms struct {
+	Package    *Package
+	Transforms []Transform
+}
+
+// ApplyPackageTransforms applies the transforms to each package, all or nothing.  Everything is
+// written to an overlay first, and only once all of the transforms have succeeded and every file
+// they touched still parses is anything written to the output filesystem.  Packages which share
+// an output filesystem are written together, and the directory of a new package is created then.
+//
+// Where the output filesystem supports it (DirFS does), each file is written to a temporary file
+// next to it and renamed into place, with a journal at JournalPath so that if this is interrupted
+// the next call finishes the job (see RecoverJournal).  Other filesystems, e.g. memfs for a dry run,
+// are written to directly.
+func ApplyPackageTransforms(ptList ...PackageTransforms) error {
+
+	var stages []*stage
+	for _, pt := range ptList {
+		p := pt.Package
+		var st *stage
+		for _, s := range stages {
+			if sameFS(s.base, p.outfs) {
+				st = s
+			}
+		}
+		if st == nil {
+			st = newStage(p.outfs)
+			stages = append(stages, st)
+		}
+		orig := p.outfs
+		p.outfs = st
+		defer func() { p.outfs = orig }()
+
+		// a new package's directory is only created along with its files
+		if p.subDir != "" {
+			if _, err := fs.Stat(orig, p.subDir); errors.Is(err, fs.ErrNotExist) {
+				st.MkdirAll(p.subDir, 0755)
+			}
+		}
+	}
+
+	// read everything once, each transform then only parses the files it changed; if anything
+	// fails what is in memory was never written, so it's read again next time
+	ok := false
+	defer func() {
+		if !ok {
+			for _, pt := range ptList {
+				pt.Package.loaded = false
+				pt.Package.conflicts = nil
+			}
+		}
+	}()
+	for _, pt := range ptList {
+		err := pt.Package.load()
+		if err != nil {
+			return fmt.Errorf("package load error: %w", err)
+		}
+	}
+
+	for _, pt := range ptList {
+		for _, t := range pt.Transforms {
+			err := pt.Package.ApplyTransform(t)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, st := range stages {
+		err := st.validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, st := range stages {
+		err := st.commit()
+		if err != nil {
+			return err
+		}
+	}
+
+	ok = true
+	return nil
+}
+
+// sameFS reports whether a and b are the same filesystem, without panicking on ones that
+// can't be compared.
+func sameFS(a, b fs.FS) bool {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+type stagedFile struct {
+	data []byte
+	perm fs.FileMode
+}
+
+// stage is an overlay on an output filesystem which keeps the files written to it in memory
+// until commit.
+type stage struct {
+	base  fs.FS
+	files map[string]stagedFile
+	dirs  map[string]bool
+}
+
+func newStage(base fs.FS) *stage {
+	return &stage{
+		base:  base,
+		files: make(map[string]stagedFile),
+		dirs:  make(map[string]bool),
+	}
+}
+
+// mapFS returns the staged files and directories as an fstest.MapFS, which does the work of
+// implementing fs.File for them.
+func (s *stage) mapFS() fstest.MapFS {
+	m := make(fstest.MapFS, len(s.files)+len(s.dirs))
+	for name := range s.dirs {
+		m[name] = &fstest.MapFile{Mode: fs.ModeDir | 0755}
+	}
+	for name, f := range s.files {
+		m[name] = &fstest.MapFile{Data: f.data, Mode: f.perm}
+	}
+	return m
+}
+
+// Open implements fs.FS.
+func (s *stage) Open(name string) (fs.File, error) {
+	if _, ok := s.files[name]; ok {
+		return s.mapFS().Open(name)
+	}
+	f, err := s.base.Open(name)
+	if err != nil && errors.Is(err, fs.ErrNotExist) && s.dirs[name] {
+		return s.mapFS().Open(name)
+	}
+	return f, err
+}
+
+// ReadDir implements fs.ReadDirFS, listing the staged files along with those in the base.
+func (s *stage) ReadDir(name string) ([]fs.DirEntry, error) {
+
+	entries := make(map[string]fs.DirEntry)
+
+	baseList, err := fs.ReadDir(s.base, name)
+	if err != nil && !(errors.Is(err, fs.ErrNotExist) && s.dirs[name]) {
+		return nil, err
+	}
+	for _, de := range baseList {
+		entries[de.Name()] = de
+	}
+
+	stagedList, _ := s.mapFS().ReadDir(name)
+	for _, de := range stagedList {
+		entries[de.Name()] = de
+	}
+
+	ret := make([]fs.DirEntry, 0, len(entries))
+	for _, de := range entries {
+		ret = append(ret, de)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name() < ret[j].Name() })
+	return ret, nil
+}
+
+// WriteFile implements FileWriter.
+func (s *stage) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	s.files[name] = stagedFile{data: append([]byte(nil), data...), perm: perm}
+	return nil
+}
+
+// MkdirAll implements MkdirAller.
+func (s *stage) MkdirAll(p string, perm os.FileMode) error {
+	for ; p != "." && p != "" && p != "/"; p = path.Dir(p) {
+		s.dirs[p] = true
+	}
+	return nil
+}
+
+// validate checks that every staged Go file parses.
+func (s *stage) validate() error {
+	fset := token.NewFileSet()
+	for name, f := range s.files {
+		if path.Ext(name) != ".go" {
+			continue
+		}
+		_, err := parser.ParseFile(fset, name, f.data, parser.ParseComments)
+		if err != nil {
+			return fmt.Errorf("%s does not parse after applying transforms, nothing was written: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// journal is the contents of JournalPath.
+type journal struct {
+	Committed bool          `json:"committed"` // all temporary files are written, they just need renaming
+	Files     []journalFile `json:"files"`
+}
+
+type journalFile struct {
+	Path string `json:"path"`
+	Temp string `json:"temp"`
+}
+
+// commit writes the staged directories and files to the base filesystem.
+func (s *stage) commit() error {
+
+	fw, ok := s.base.(FileWriter)
+	if !ok {
+		return fmt.Errorf("output filesystem does not implement FileWriter, cannot write changes")
+	}
+
+	if len(s.dirs) > 0 {
+		mda, ok := s.base.(MkdirAller)
+		if !ok {
+			return fmt.Errorf("output filesystem does not implement MkdirAller, cannot create directories")
+		}
+		for _, dir := range sortedKeysBool(s.dirs) {
+			err := mda.MkdirAll(dir, 0755)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	names := make([]string, 0, len(s.files))
+	for name := range s.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rn, okR := s.base.(Renamer)
+	rm, okM := s.base.(Remover)
+	mda, okD := s.base.(MkdirAller)
+	if !(okR && okM && okD) {
+		for _, name := range names {
+			err := fw.WriteFile(name, s.files[name].data, s.files[name].perm)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// finish anything left over from last time before starting
+	_, err := RecoverJournal(s.base)
+	if err != nil {
+		return err
+	}
+
+	var j journal
+	for _, name := range names {
+		dir, file := path.Split(name)
+		j.Files = append(j.Files, journalFile{Path: name, Temp: dir + "." + file + tempSuffix})
+	}
+
+	err = mda.MkdirAll(path.Dir(JournalPath), 0755)
+	if err != nil {
+		return err
+	}
+	err = writeJournal(fw, &j)
+	if err != nil {
+		return err
+	}
+
+	for _, jf := range j.Files {
+		f := s.files[jf.Path]
+		err := fw.WriteFile(jf.Temp, f.data, f.perm)
+		if err != nil {
+			return fmt.Errorf("writing %s: %w", jf.Temp, err)
+		}
+	}
+
+	// from here on the changes will be finished by RecoverJournal if this is interrupted
+	j.Committed = true
+	err = writeJournal(fw, &j)
+	if err != nil {
+		return err
+	}
+
+	for _, jf := range j.Files {
+		err := rn.Rename(jf.Temp, jf.Path)
+		if err != nil {
+			return fmt.Errorf("renaming %s: %w", jf.Temp, err)
+		}
+	}
+
+	return rm.Remove(JournalPath)
+}
+
+func writeJournal(fw FileWriter, j *journal) error {
+	b, err := json.MarshalIndent(j, "", "\t")
+	if err != nil {
+		return err
+	}
+	return fw.WriteFile(JournalPath, b, 0644)
+}
+
+// RecoverJournal finishes or undoes changes that were being written when a previous
+// ApplyTransforms was interrupted, as recorded in JournalPath in fsys, and reports whether there
+// was anything to do.  If all of the changes had been written to temporary files they are renamed
+// into place, otherwise the temporary files are removed and the original files are left as they
+// were.  This is done automatically before changes are next written to fsys.
+func RecoverJournal(fsys fs.FS) (bool, error) {
+
+	b, err := fs.ReadFile(fsys, JournalPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	rn, okR := fsys.(Renamer)
+	rm, okM := fsys.(Remover)
+	if !(okR && okM) {
+		return false, fmt.Errorf("%s found but the filesystem does not implement Renamer and Remover", JournalPath)
+	}
+
+	var j journal
+	err = json.Unmarshal(b, &j)
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", JournalPath, err)
+	}
+
+	for _, jf := range j.Files {
+		if !strings.HasSuffix(jf.Temp, tempSuffix) {
+			return false, fmt.Errorf("unexpected temporary file name %q in %s", jf.Temp, JournalPath)
+		}
+		if _, err := fs.Stat(fsys, jf.Temp); err != nil {
+			continue // already renamed, or never written
+		}
+		if j.Committed {
+			err = rn.Rename(jf.Temp, jf.Path)
+		} else {
+			err = rm.Remove(jf.Temp)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, rm.Remove(JournalPath)
+}
+
+func sortedKeysBool(m map[string]bool) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,170 @@
+package srcedit
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/psanford/memfs"
+)
+
+func TestApplyTransformsAtomic(t *testing.T) {
+
+	type tcase struct {
+		name       string
+		transforms []PackageTransforms // Package is filled in from pkgDir
+		pkgDirs    []string
+		eerr       string
+	}
+
+	tcList := []tcase{
+		{
+			name:    "missing_func",
+			pkgDirs: []string{"a"},
+			transforms: []PackageTransforms{{Transforms: []Transform{
+				&AddFuncDeclTransform{Filename: "a.go", Name: "F", Text: "func F() {}"},
+				&AddFuncLineTransform{Name: "G", Text: "F()"},
+			}}},
+			eerr: "not found",
+		},
+		{
+			name:    "does_not_parse",
+			pkgDirs: []string{"a"},
+			transforms: []PackageTransforms{{Transforms: []Transform{
+				&AddFuncDeclTransform{Filename: "a.go", Name: "F", Text: "func F() {"},
+			}}},
+			eerr: "does not parse",
+		},
+		{
+			name:    "second_package",
+			pkgDirs: []string{"a", "b"},
+			transforms: []PackageTransforms{
+				{Transforms: []Transform{&AddFuncDeclTransform{Filename: "a.go", Name: "F", Text: "func F() {}"}}},
+				{Transforms: []Transform{&AddFuncLineTransform{Name: "G", Text: "F()"}}},
+			},
+			eerr: "not found",
+		},
+	}
+
+	for _, tc := range tcList {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := memfs.New()
+			for i, dir := range tc.pkgDirs {
+				must(t, fsys.MkdirAll(dir, 0755))
+				must(t, fsys.WriteFile(dir+"/x.go", []byte("package "+dir+"\n"), 0644))
+				tc.transforms[i].Package = NewPackage(fsys, fsys, "test1", dir)
+			}
+			err := ApplyPackageTransforms(tc.transforms...)
+			if err == nil || !strings.Contains(err.Error(), tc.eerr) {
+				t.Fatalf("expected error containing %q, got: %v", tc.eerr, err)
+			}
+			if _, err := fs.Stat(fsys, "a/a.go"); err == nil {
+				t.Errorf("a/a.go was written even though the transforms failed")
+			}
+		})
+	}
+}
+
+func TestApplyTransformsNewPackage(t *testing.T) {
+
+	dir := t.TempDir()
+	fsys := DirFS(dir)
+
+	// not created when the transforms fail
+	p := NewPackage(fsys, fsys, "test1", "b/c")
+	err := p.ApplyTransforms(&AddFuncDeclTransform{Filename: "c.go", Name: "F", Text: "func F() {"})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b")); err == nil {
+		t.Errorf("b was created even though the transforms failed")
+	}
+
+	p = NewPackage(fsys, fsys, "test1", "b/c")
+	must(t, p.ApplyTransforms(&AddFuncDeclTransform{Filename: "c.go", Name: "F", Text: "func F() {}"}))
+	b, err := os.ReadFile(filepath.Join(dir, "b", "c", "c.go"))
+	must(t, err)
+	if !strings.Contains(string(b), "package c") || !strings.Contains(string(b), "func F() {}") {
+		t.Errorf("unexpected c.go:\n%s", b)
+	}
+}
+
+func TestApplyTransformsJournal(t *testing.T) {
+
+	dir := t.TempDir()
+	fsys := DirFS(dir)
+	must(t, fsys.MkdirAll("a", 0755))
+	must(t, fsys.WriteFile("a/a.go", []byte("package a\n"), 0644))
+
+	p := NewPackage(fsys, fsys, "test1", "a")
+	must(t, p.ApplyTransforms(
+		&AddFuncDeclTransform{Filename: "a.go", Name: "F", Text: "func F() {}"},
+		&AddFuncDeclTransform{Filename: "b.go", Name: "G", Text: "func G() {}"},
+	))
+
+	for fn, want := range map[string]string{"a.go": "func F() {}", "b.go": "func G() {}"} {
+		b, err := os.ReadFile(filepath.Join(dir, "a", fn))
+		must(t, err)
+		if !strings.Contains(string(b), want) {
+			t.Errorf("%s: expected %q, got:\n%s", fn, want, b)
+		}
+	}
+
+	// nothing left behind
+	must(t, filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasSuffix(p, tempSuffix) || strings.HasSuffix(p, "journal.json") {
+			t.Errorf("left behind: %s", p)
+		}
+		return nil
+	}))
+}
+
+func TestRecoverJournal(t *testing.T) {
+
+	for _, committed := range []bool{true, false} {
+
+		dir := t.TempDir()
+		fsys := DirFS(dir)
+		must(t, fsys.MkdirAll(".gocode", 0755))
+		must(t, fsys.WriteFile("a.go", []byte("old"), 0644))
+		must(t, fsys.WriteFile(".a.go"+tempSuffix, []byte("new"), 0644))
+		j := &journal{Committed: committed, Files: []journalFile{
+			{Path: "a.go", Temp: ".a.go" + tempSuffix},
+			{Path: "b.go", Temp: ".b.go" + tempSuffix}, // never written
+		}}
+		must(t, writeJournal(fsys, j))
+
+		ok, err := RecoverJournal(fsys)
+		must(t, err)
+		if !ok {
+			t.Errorf("committed=%v: expected the journal to be found", committed)
+		}
+
+		want := "old"
+		if committed {
+			want = "new"
+		}
+		b, err := fs.ReadFile(fsys, "a.go")
+		must(t, err)
+		if string(b) != want {
+			t.Errorf("committed=%v: expected a.go to have %q, got %q", committed, want, b)
+		}
+		for _, fn := range []string{".a.go" + tempSuffix, JournalPath, "b.go"} {
+			if _, err := fs.Stat(fsys, fn); err == nil {
+				t.Errorf("committed=%v: %s should not exist", committed, fn)
+			}
+		}
+
+		ok, err = RecoverJournal(fsys)
+		must(t, err)
+		if ok {
+			t.Errorf("committed=%v: expected nothing to do the second time", committed)
+		}
+	}
+}
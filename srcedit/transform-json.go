@@ -0,0 +1,110 @@
+package srcedit
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// transformTypes are the transforms that TransformList can encode.  In JSON each is identified
+// by its type name without the "Transform" suffix, e.g. "AddFuncDecl", and has its fields as they are.
+var transformTypes = []Transform{
+	&ImportTransform{},
+	&DedupImportsTransform{},
+	&FixImportsTransform{},
+	&AddFuncDeclTransform{},
+	&AddConstDeclTransform{},
+	&AddVarDeclTransform{},
+	&AddTypeDeclTransform{},
+	&AddStructFieldTransform{},
+	&AddFuncLineTransform{},
+	&AddFileTransform{},
+	&AppendFileSectionTransform{},
+	&RemoveFuncDeclTransform{},
+	&RemoveTypeDeclTransform{},
+	&RemoveVarConstDeclTransform{},
+	&RemoveUnusedImportsTransform{},
+	&GofmtTransform{},
+}
+
+// transformTypeName returns the name t is identified by in JSON.
+func transformTypeName(t Transform) string {
+	return strings.TrimSuffix(reflect.TypeOf(t).Elem().Name(), "Transform")
+}
+
+// TransformList is a list of transforms which can be converted to and from JSON, so they can be
+// saved and applied later.  Each is an object with its type in "type" and the rest of its fields, e.g.:
+//
+//	{"type":"Import","Filename":"widget.go","Name":"","Path":"fmt"}
+type TransformList []Transform
+
+// MarshalJSON implements json.Marshaler.
+func (l TransformList) MarshalJSON() ([]byte, error) {
+
+	ret := make([]map[string]interface{}, 0, len(l))
+	for i, t := range l {
+
+		b, err := json.Marshal(t)
+		if err != nil {
+			return nil, fmt.Errorf("transform %d: %w", i, err)
+		}
+		m := make(map[string]interface{})
+		err = json.Unmarshal(b, &m)
+		if err != nil {
+			return nil, fmt.Errorf("transform %d: %w", i, err)
+		}
+		for k := range m {
+			// field names are matched without regard to case when decoding
+			if strings.EqualFold(k, "type") {
+				return nil, fmt.Errorf("transform %d: %T has a field that conflicts with \"type\"", i, t)
+			}
+		}
+		m["type"] = transformTypeName(t)
+
+		ret = append(ret, m)
+	}
+
+	return json.Marshal(ret)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (l *TransformList) UnmarshalJSON(b []byte) error {
+
+	var rawList []json.RawMessage
+	err := json.Unmarshal(b, &rawList)
+	if err != nil {
+		return err
+	}
+
+	ret := make(TransformList, 0, len(rawList))
+	for i, raw := range rawList {
+
+		var typ struct {
+			Type string `json:"type"`
+		}
+		err := json.Unmarshal(raw, &typ)
+		if err != nil {
+			return fmt.Errorf("transform %d: %w", i, err)
+		}
+
+		var t Transform
+		for _, tt := range transformTypes {
+			if transformTypeName(tt) == typ.Type {
+				t = reflect.New(reflect.TypeOf(tt).Elem()).Interface().(Transform)
+			}
+		}
+		if t == nil {
+			return fmt.Errorf("transform %d: unknown type %q", i, typ.Type)
+		}
+
+		err = json.Unmarshal(raw, t)
+		if err != nil {
+			return fmt.Errorf("transform %d (%s): %w", i, typ.Type, err)
+		}
+		ret = append(ret, t)
+	}
+
+	*l = ret
+	return nil
+}
@@ -18,6 +18,10 @@ type Transform interface {
 }
 
 // ImportTransform ensures a particular package is imported, optionally with a specific local name.
+// If the file already imports it, under any name, nothing is added.  If the name is taken in the
+// file, by an import of another package or a declaration in the package, it is imported with a
+// number added to the name instead, e.g. store2, and code added to the file afterward is changed to
+// use that, see ImportAlias.
 type ImportTransform struct {
 	Filename string // write code to this file
 	Name     string // local import name - "" means none, "_" is valid, or otherwise local name
@@ -27,61 +31,303 @@ type ImportTransform struct {
 func (t *ImportTransform) xform() {}
 
 // DedupImportsTransform ensures only one of each import.
+//
+// Deprecated: FixImportsTransform also removes duplicate imports, along with unused ones.
 type DedupImportsTransform struct {
 	FilenameList []string // dedup imports in these files, ignore if missing, nil means all
 }
 
 func (t *DedupImportsTransform) xform() {}
 
+// FixImportsTransform removes unused and duplicate imports and puts the rest in a single
+// import block, grouped as standard library, third party and then packages in this module,
+// the way goimports does.  Files that import "C" are left alone.
+type FixImportsTransform struct {
+	FilenameList []string // fix imports in these files, ignore if missing, nil means all
+}
+
+func (t *FixImportsTransform) xform() {}
+
 // AddFuncDeclTransform is used to add a function or method.
 type AddFuncDeclTransform struct {
-	Filename     string // write code to this file
-	Name         string // the name of the function
-	ReceiverType string // the receiver type, e.g. "*X" meaning pointer to type X
-	Text         string // full function text including comments
-	Replace      bool   // if true then any existing function or method with this name/name+receiver will be replaced
+	Filename     string     // write code to this file
+	Name         string     // the name of the function
+	ReceiverType string     // the receiver type, e.g. "*X" meaning pointer to type X
+	Text         string     // full function text including comments
+	Replace      bool       // if true then any existing function or method with this name/name+receiver will be replaced, where it is
+	Owner        string     // if set, e.g. "sqlcrud/TYPEInsert", the function is marked as generated by it, see Conflict
+	Anchor       DeclAnchor // where a new function goes in Filename
+	AnchorName   string     // for DeclAfter and DeclBefore, the declaration, e.g. "F" or "(*X).F"
 }
 
 func (t *AddFuncDeclTransform) xform() {}
 
 // AddConstDeclTransform adds a const declaration.
 type AddConstDeclTransform struct {
-	Filename string   // write code to this file
-	NameList []string // the names
-	Text     string   // the full declaration text including comments
-	Replace  bool     // if true then any existing declaration with the same name is replaced
+	Filename   string     // write code to this file
+	NameList   []string   // the names
+	Text       string     // the full declaration text including comments
+	Replace    bool       // if true then any existing declaration with the same name is replaced, where it is
+	Owner      string     // if set the declaration is marked as generated by it, see Conflict
+	Anchor     DeclAnchor // where a new declaration goes in Filename
+	AnchorName string     // for DeclAfter and DeclBefore, the declaration, e.g. "F" or "(*X).F"
 }
 
 func (t *AddConstDeclTransform) xform() {}
 
 // AddVarDeclTransform adds a var declaration.
 type AddVarDeclTransform struct {
-	Filename string   // write code to this file
-	NameList []string // the names
-	Text     string   // the full declaration text including comments
-	Replace  bool     // if true then any existing declaration with the same name is replaced
+	Filename   string     // write code to this file
+	NameList   []string   // the names
+	Text       string     // the full declaration text including comments
+	Replace    bool       // if true then any existing declaration with the same name is replaced, where it is
+	Owner      string     // if set the declaration is marked as generated by it, see Conflict
+	Anchor     DeclAnchor // where a new declaration goes in Filename
+	AnchorName string     // for DeclAfter and DeclBefore, the declaration, e.g. "F" or "(*X).F"
 }
 
 func (t *AddVarDeclTransform) xform() {}
 
 // AddTypeDeclTransform adds a type declaration.
 type AddTypeDeclTransform struct {
-	Filename string // write code to this file
-	Name     string // the type name
-	Text     string // the full declaration text including comments
-	Replace  bool   // if true then any existing declaration with the same name is replaced
+	Filename   string     // write code to this file
+	Name       string     // the type name
+	Text       string     // the full declaration text including comments
+	Replace    bool       // if true then any existing declaration with the same name is replaced, where it is
+	Merge      bool       // if true and both are structs, missing fields are added to the existing one (and tags updated if Replace), instead of Replace
+	Owner      string     // if set the declaration is marked as generated by it, see Conflict
+	Anchor     DeclAnchor // where a new declaration goes in Filename
+	AnchorName string     // for DeclAfter and DeclBefore, the declaration, e.g. "F" or "(*X).F"
 }
 
 func (t *AddTypeDeclTransform) xform() {}
 
-// GofmtTransform runs "gofmt" on the indicate files or all.
+// AddStructFieldTransform adds one or more fields to an existing struct type.  Fields
+// that are already present (by name, or type name for embedded fields) are left alone
+// unless Replace is set, in which case their tag is updated.  Fields not in Text and
+// any comments on existing fields are not touched.  It is an error if the type does not exist.
+type AddStructFieldTransform struct {
+	TypeName string // the name of the struct type
+	Text     string // the fields including doc comments and tags, e.g. "Name string `db:\"name\"`"
+	Replace  bool   // if true then the tag of an existing field with the same name is replaced
+}
+
+func (t *AddStructFieldTransform) xform() {}
+
+// AddFileTransform creates a file in the package directory which isn't Go code, such as a SQL
+// migration or a YAML or Markdown file, if it doesn't exist.  Go files are made with the other
+// transforms.
+type AddFileTransform struct {
+	Filename     string // the file name, not a .go file
+	Text         string // the whole file
+	Replace      bool   // if true an existing file is overwritten
+	FailIfExists bool   // if true and not replacing, it is an error if the file exists, instead of leaving it alone
+}
+
+func (t *AddFileTransform) xform() {}
+
+// AppendFileSectionTransform adds a section to a file in the package directory which isn't Go
+// code, creating the file if needed.  The section is put between comment lines naming it, e.g.
+// for a YAML file:
+//
+//	# gocode:section widget begin
+//	...
+//	# gocode:section widget end
+//
+// so it is only added once, and can be replaced.  The comments are written as "--" in .sql files,
+// "<!-- ... -->" in .md and .html files and "#" in others.
+type AppendFileSectionTransform struct {
+	Filename string // the file name, not a .go file
+	Name     string // identifies the section in the file, must not have spaces
+	Text     string // the section, without the comment lines
+	Replace  bool   // if true the text of an existing section is replaced
+}
+
+func (t *AppendFileSectionTransform) xform() {}
+
+// RemoveFuncDeclTransform removes a function or method, along with its doc comment.
+// Nothing is done if it does not exist.
+type RemoveFuncDeclTransform struct {
+	Name         string // the name of the function
+	ReceiverType string // the receiver type, e.g. "*X" meaning pointer to type X, "" for a function
+	Text         string // for init, which can't be told apart by name, the one with this code is removed
+}
+
+func (t *RemoveFuncDeclTransform) xform() {}
+
+// RemoveTypeDeclTransform removes a type declaration, along with its doc comment.
+// Nothing is done if it does not exist.
+type RemoveTypeDeclTransform struct {
+	Name string // the type name
+}
+
+func (t *RemoveTypeDeclTransform) xform() {}
+
+// RemoveVarConstDeclTransform removes the var or const declaration with any of the names given.
+// As with AddVarDeclTransform, it is an error if the declaration has other names as well.
+// Nothing is done if it does not exist.
+type RemoveVarConstDeclTransform struct {
+	NameList []string // the names
+	Text     string   // if the names are all "_", the declaration with this code is removed
+}
+
+func (t *RemoveVarConstDeclTransform) xform() {}
+
+// RemoveUnusedImportsTransform removes imports that nothing in the file refers to, e.g. after
+// declarations have been removed.  An import without a local name is taken to have the last element
+// of its path as its name (without a "go-" prefix or major version), and if the file refers to a
+// package that can't be matched up with an import this way, its imports are all left alone.
+// Blank and dot imports are kept, unless the file has nothing but imports in it.
+type RemoveUnusedImportsTransform struct {
+	FilenameList []string // remove unused imports in these files, ignore if missing, nil means all
+}
+
+func (t *RemoveUnusedImportsTransform) xform() {}
+
+// RemoveUnreferencedTransform applies the Remove transforms in TransformList only if nothing else
+// in the package refers to the declarations they remove (see Package.Referenced), checked when it
+// is applied.  This way code shared by several generated types can be removed along with the last
+// of them, by transforms applied all together.  It is not one of the transforms TransformList
+// can encode.
+type RemoveUnreferencedTransform struct {
+	TransformList []Transform
+}
+
+func (t *RemoveUnreferencedTransform) xform() {}
+
+// RemoveTransforms returns transforms that remove the declarations added by the ones in trList,
+// e.g. to take generated code back out.  Imports are not included, follow with a
+// RemoveUnusedImportsTransform for that.  Init functions and declarations named only "_" are
+// removed if their code is the same as what was added.
+func RemoveTransforms(trList []Transform) []Transform {
+	var ret []Transform
+	for _, tr := range trList {
+		switch t := tr.(type) {
+		case *AddFuncDeclTransform:
+			rt := &RemoveFuncDeclTransform{Name: t.Name, ReceiverType: t.ReceiverType}
+			if isInitFunc(t.ReceiverType, t.Name) {
+				rt.Text = t.Text
+			}
+			ret = append(ret, rt)
+		case *AddTypeDeclTransform:
+			ret = append(ret, &RemoveTypeDeclTransform{Name: t.Name})
+		case *AddConstDeclTransform:
+			ret = append(ret, removeVarConst(t.NameList, t.Text))
+		case *AddVarDeclTransform:
+			ret = append(ret, removeVarConst(t.NameList, t.Text))
+		}
+	}
+	return ret
+}
+
+// removeVarConst returns the transform to remove the var or const declaration of names added with text.
+func removeVarConst(names []string, text string) Transform {
+	if allBlank(names) {
+		return &RemoveVarConstDeclTransform{NameList: names, Text: text}
+	}
+	return &RemoveVarConstDeclTransform{NameList: withoutBlank(names)}
+}
+
+func withoutBlank(names []string) []string {
+	var ret []string
+	for _, n := range names {
+		if n != "_" {
+			ret = append(ret, n)
+		}
+	}
+	return ret
+}
+
+// GofmtTransform formats the indicated files, or all, like gofmt.
 type GofmtTransform struct {
 	FilenameList []string // format these files, ignore if missing, nil means all
 }
 
 func (t *GofmtTransform) xform() {}
 
-// TODO: AddFuncLineTransform { FuncName, Line } adds a line to a function before the return - we'll need it for adding routes
+// SetReplace sets Replace to true on each transform in trList which has it, so
+// the generated declarations replace any existing ones with the same name.
+func SetReplace(trList []Transform) {
+	for _, tr := range trList {
+		switch t := tr.(type) {
+		case *AddFuncDeclTransform:
+			t.Replace = true
+		case *AddConstDeclTransform:
+			t.Replace = true
+		case *AddVarDeclTransform:
+			t.Replace = true
+		case *AddTypeDeclTransform:
+			t.Replace = true
+		case *AddStructFieldTransform:
+			t.Replace = true
+		case *AddFileTransform:
+			t.Replace = true
+		case *AppendFileSectionTransform:
+			t.Replace = true
+		}
+	}
+}
+
+// SetOwner sets Owner on each transform in trList which adds a declaration, so the declarations
+// are marked as generated by owner, e.g. "sqlcrud/TYPEInsert".  See Conflict.
+func SetOwner(trList []Transform, owner string) {
+	for _, tr := range trList {
+		switch t := tr.(type) {
+		case *AddFuncDeclTransform:
+			t.Owner = owner
+		case *AddConstDeclTransform:
+			t.Owner = owner
+		case *AddVarDeclTransform:
+			t.Owner = owner
+		case *AddTypeDeclTransform:
+			t.Owner = owner
+		}
+	}
+}
+
+// SetMerge sets Merge to true on each AddTypeDeclTransform in trList, so generated
+// structs that already exist gain any new fields without losing the ones they have.
+func SetMerge(trList []Transform) {
+	for _, tr := range trList {
+		if t, ok := tr.(*AddTypeDeclTransform); ok {
+			t.Merge = true
+		}
+	}
+}
+
+// DeclAnchor says where a new declaration is put in its file.  One that replaces an existing
+// declaration always takes its place instead.  If what it refers to isn't in the file, the
+// declaration goes at the end.
+type DeclAnchor string
+
+const (
+	DeclAtEnd        DeclAnchor = ""              // at the end of the file
+	DeclAfter        DeclAnchor = "after"         // after the declaration named in AnchorName
+	DeclBefore       DeclAnchor = "before"        // before the declaration named in AnchorName
+	DeclWithReceiver DeclAnchor = "with-receiver" // for methods, after the last method with the same receiver type, or else after the type
+)
+
+// FuncLineAnchor says where AddFuncLineTransform puts its statements.
+type FuncLineAnchor string
+
+const (
+	FuncLineBeforeReturn FuncLineAnchor = ""              // before the final return statement, or at the end if the function doesn't end with one
+	FuncLineAfterComment FuncLineAnchor = "after-comment" // on the line after the comment given in Comment
+	FuncLineAtEnd        FuncLineAnchor = "end"           // at the end of the function body
+)
+
+// AddFuncLineTransform adds one or more statements to the body of an existing function or method,
+// e.g. to register a route or a store in a setup function.  Statements that are already in the
+// body (compared after formatting) are not added again.  It is an error if the function does not exist.
+type AddFuncLineTransform struct {
+	Name         string         // the name of the function
+	ReceiverType string         // the receiver type, e.g. "*X" meaning pointer to type X, "" for a function
+	Text         string         // the statements to add, comments before a statement are kept with it
+	Anchor       FuncLineAnchor // where to add the statements
+	Comment      string         // for FuncLineAfterComment, the text of the comment, e.g. "// routes"
+}
+
+func (t *AddFuncLineTransform) xform() {}
 
 // Transformers houses a collection of transforms.  More than meets the eye, robots in disguise.
 type Transformers struct {
@@ -98,7 +344,9 @@ func (ts *Transformers) Add(tl []Transform) {
 // converted to ImportTransform, funcs are converted to AddFuncDeclTransform, and const, var and type
 // declarations are converted to AddGenDeclTransform.  Comments are preserved and included
 // in the transform where possible.  Other unsupported source elements may error or be ignored
-// (will try to make them error but no promises yet).
+// (will try to make them error but no promises yet).  There can be any number of init functions
+// and var declarations of only "_", such as `var _ I = (*T)(nil)`, since these are told apart by
+// their code instead of their name when applied.
 //
 // This is basically here to facilitate easy templating.  So you can have a template that just outputs
 // `func F() {}` or whatever and call this function and get back a slice with an AddFuncDeclTransform.
@@ -185,7 +433,7 @@ func ParseTransforms(filename, snippet string) (ret []Transform, reterr error) {
 		switch d := decl.(type) {
 		case *ast.FuncDecl:
 
-			// FIXME: init() needs special handling - maybe use a hash of the function body to determine "is this the same"
+			// init functions are told apart by their code when applied, see isInitFunc
 
 			tr := &AddFuncDeclTransform{
 				Filename: filename,
@@ -222,7 +470,7 @@ func ParseTransforms(filename, snippet string) (ret []Transform, reterr error) {
 
 			case token.VAR:
 
-				// FIXME: what about declarations with the name "_" - decide what an appropriate behavior is
+				// as are declarations named only "_", see findBlankDeclCode
 
 				tr := &AddVarDeclTransform{
 					Filename: filename,
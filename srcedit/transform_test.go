@@ -2,6 +2,7 @@ package srcedit
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -230,3 +231,36 @@ type t struct {
 	}
 
 }
+
+func TestTransformListJSON(t *testing.T) {
+
+	in := TransformList{
+		&ImportTransform{Filename: "a.go", Name: "store", Path: "example.com/store"},
+		&FixImportsTransform{FilenameList: []string{"a.go"}},
+		&AddFuncDeclTransform{Filename: "a.go", Name: "F", ReceiverType: "*X", Text: "func (x *X) F() {}", Replace: true},
+		&AddConstDeclTransform{Filename: "a.go", NameList: []string{"A", "B"}, Text: "const (\n\tA = 1\n\tB = 2\n)"},
+		&AddTypeDeclTransform{Filename: "a.go", Name: "X", Text: "type X struct{}", Merge: true},
+		&AddFuncLineTransform{Name: "F", ReceiverType: "*X", Text: "x.G()", Anchor: FuncLineAfterComment, Comment: "// here"},
+		&RemoveVarConstDeclTransform{NameList: []string{"v"}},
+		&GofmtTransform{},
+	}
+
+	b, err := in.MarshalJSON()
+	must(t, err)
+	var out TransformList
+	must(t, out.UnmarshalJSON(b))
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("round trip through %s\nexpected: %#v\ngot: %#v", b, in, out)
+	}
+
+	// every transform type can be encoded
+	for _, tt := range transformTypes {
+		_, err := TransformList{tt}.MarshalJSON()
+		must(t, err)
+	}
+
+	err = out.UnmarshalJSON([]byte(`[{"type":"Nope"}]`))
+	if err == nil || !strings.Contains(err.Error(), `unknown type "Nope"`) {
+		t.Errorf("expected unknown type error, got: %v", err)
+	}
+}